@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+
+	"github.com/h-waldschmidt/nngo/nngo"
+	"gonum.org/v1/gonum/mat"
+)
+
+func main() {
+	// same csv files as in examples/mnist
+	// source: https://www.kaggle.com/datasets/oddrationale/mnist-in-csv
+	train := readCSVToSet("mnist_train.csv")
+	test := readCSVToSet("mnist_test.csv")
+
+	// the pixels of each image are saved row by row, which is the layout of the shape {1, 28, 28}
+	// conv 3x3 -> relu -> max pool 2x2 -> conv 3x3 -> relu -> max pool 2x2 -> dense -> softmax
+	conv1, err := nngo.NewConv2D(nngo.Shape{Channels: 1, Height: 28, Width: 28}, 8, 3, 3, nngo.WithSamePadding(), nngo.WithWeightInit(nngo.HeUniform))
+	if err != nil {
+		log.Fatal(err)
+	}
+	relu1, _ := nngo.NewActivationWith(conv1.OutputShape().Size(), nngo.ReluActivation)
+	pool1, err := nngo.NewMaxPool2D(conv1.OutputShape(), 2)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	conv2, err := nngo.NewConv2D(pool1.OutputShape(), 16, 3, 3, nngo.WithSamePadding(), nngo.WithWeightInit(nngo.HeUniform))
+	if err != nil {
+		log.Fatal(err)
+	}
+	relu2, _ := nngo.NewActivationWith(conv2.OutputShape().Size(), nngo.ReluActivation)
+	pool2, err := nngo.NewMaxPool2D(conv2.OutputShape(), 2)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	flatten, _ := nngo.NewFlatten(pool2.OutputShape())
+	dense, _ := nngo.NewDense(flatten.OutputShape().Size(), 10)
+	softmax, _ := nngo.NewSoftmax(10)
+
+	network, err := nngo.NewSequential(nngo.CategoricalCrossEntropyLoss,
+		conv1, relu1, pool1,
+		conv2, relu2, pool2,
+		flatten, dense, softmax,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	adam, err := nngo.NewAdam(0.9, 0.999)
+	if err != nil {
+		log.Fatal(err)
+	}
+	network.SetOptimizer(adam)
+
+	_, err = network.Train(&train, 5, 32, nngo.ConstantRate(0.001),
+		nngo.WithValidationSplit(0.1),
+		nngo.WithMetrics(nngo.AccuracyMetric),
+		nngo.WithWorkers(runtime.NumCPU()),
+		nngo.WithShuffle(),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	accuracy, err := network.EvaluateOneHot(&test)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Accuracy on test data: %v", accuracy)
+}
+
+// reads the csv file and scales the pixels to [0, 1]
+func readCSVToSet(filePath string) nngo.Set {
+	f, err := os.Open(filePath)
+	if err != nil {
+		log.Fatal("Unable to read input file "+filePath, err)
+	}
+	defer f.Close()
+
+	csvReader := csv.NewReader(f)
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		log.Fatal("Unable to parse file as CSV for "+filePath, err)
+	}
+
+	// ignore first line
+	records = records[1:]
+	data := mat.NewDense(len(records[0])-1, len(records), nil)
+	labels := mat.NewDense(10, len(records), nil)
+	for i, record := range records {
+		for j, entry := range record {
+			num, err := strconv.Atoi(entry)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if j == 0 {
+				labels.Set(num, i, 1)
+			} else {
+				data.Set(j-1, i, float64(num)/255)
+			}
+		}
+	}
+	return nngo.Set{Data: *data, Labels: *labels}
+}
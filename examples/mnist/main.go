@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"runtime"
 	"strconv"
 
 	"github.com/h-waldschmidt/nngo/nngo"
@@ -19,18 +20,80 @@ func main() {
 	test := readCSVToSet("mnist_test.csv")
 	splitSet := nngo.SplitSet{Train: train, Test: test}
 
+	// softmax output layer with categorical cross entropy
 	network, err := nngo.NewNetwork(
-		[][]int{{28 * 28, 40, 2}, {40, 10, 2}},
-		0,
+		[]nngo.LayerSpec{{Input: 28 * 28, Output: 40, Activation: nngo.ActivationTanh}, {Input: 40, Output: 10, Activation: nngo.ActivationSoftmax}},
+		nngo.LossCategoricalCrossEntropy,
 	)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	network.Train(&splitSet.Train, 100, 0.1)
+	adam, err := nngo.NewAdam(0.9, 0.999)
+	if err != nil {
+		log.Fatal(err)
+	}
+	network.SetOptimizer(adam)
 
-	accuracy := network.EvaluateOneHot(&splitSet.Test)
+	// cosine annealing with warm restarts after 10, 30 and 70 epochs
+	scheduler, err := nngo.NewCosineAnnealing(0.001, 0.00001, 10, 2)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// keep 10% of the training data for validation and stop,
+	// when the validation loss didn't improve for 10 epochs
+	// write a checkpoint every 5 epochs and continue from the newest one,
+	// if the training was interrupted before
+	// each batch is split between all cores and the samples are shuffled each epoch
+	options := []nngo.TrainOption{
+		nngo.WithValidationSplit(0.1),
+		nngo.WithMetrics(nngo.AccuracyMetric),
+		nngo.WithEarlyStopping(10, true),
+		nngo.WithCheckpoints("checkpoints", 5),
+		nngo.WithWorkers(runtime.NumCPU()),
+		nngo.WithShuffle(),
+	}
+	if path, err := nngo.LatestCheckpoint("checkpoints"); err == nil {
+		checkpoint := readCheckpoint(path)
+		network = checkpoint.Network
+		options = append(options, nngo.WithResume(checkpoint))
+	}
+
+	if _, err := network.Train(&splitSet.Train, 100, 32, scheduler, options...); err != nil {
+		log.Fatal(err)
+	}
+
+	accuracy, err := network.EvaluateOneHot(&splitSet.Test)
+	if err != nil {
+		log.Fatal(err)
+	}
 	fmt.Printf("Accuracy on test data: %v", accuracy)
+
+	// save the trained model, it can be loaded again with nngo.Load
+	f, err := os.Create("mnist.nngo")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := network.Save(f); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readCheckpoint(filePath string) *nngo.Checkpoint {
+	f, err := os.Open(filePath)
+	if err != nil {
+		log.Fatal("Unable to read checkpoint "+filePath, err)
+	}
+	defer f.Close()
+
+	checkpoint, err := nngo.LoadCheckpoint(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return checkpoint
 }
 
 func readCSVToSet(filePath string) nngo.Set {
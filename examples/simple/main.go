@@ -22,20 +22,23 @@ func main() {
 
 	// create the network
 	network, err := nngo.NewNetwork(
-		[][]int{
-			{2, 10, 2}, // input layer with input size of 2, output size of 10 and tanh as activation function
-			{10, 2, 2}, // output layer with input size of 10, output size of 2 and tanh as activation function
+		[]nngo.LayerSpec{
+			{Input: 2, Output: 10, Activation: nngo.ActivationTanh}, // input layer with input size of 2, output size of 10 and tanh as activation function
+			{Input: 10, Output: 2, Activation: nngo.ActivationTanh}, // output layer with input size of 10, output size of 2 and tanh as activation function
 		},
-		0, // loss function is MSE
+		nngo.LossMse,
 	)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// train the network with 1000 epochs
-	network.Train(&splitSet.Train, 100, 0.1)
+	// train the network with 100 epochs, a batch size of 2 and a constant learning rate
+	network.Train(&splitSet.Train, 100, 2, nngo.ConstantRate(0.1))
 
 	// evaluate the performance of the network
-	accuracy := network.EvaluateOneHot(&splitSet.Test)
+	accuracy, err := network.EvaluateOneHot(&splitSet.Test)
+	if err != nil {
+		log.Fatal(err)
+	}
 	fmt.Printf("Accuracy on test data: %v", accuracy)
 }
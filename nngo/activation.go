@@ -3,39 +3,132 @@ package nngo
 import (
 	"fmt"
 	"math"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
 )
 
+// code of a built in activation function, see NewNetwork and NewActivation
+//
+// the codes have their own type, so they can't be mixed up with loss codes
+type ActivationCode int
+
 // Each constant represents an activation function and its derivative
 // This makes the definition of neural networks easier
+//
+// ActivationSoftmax works on the whole vector instead of each element,
+// so it creates a Softmax layer instead of an Activation layer
+//
+// parameterised functions use their default parameters:
+// LeakyRelu with alpha 0.01, PRelu with an initial alpha of 0.25 and Elu with alpha 1
+const (
+	ActivationSigmoid     ActivationCode = 0
+	ActivationRelu        ActivationCode = 1
+	ActivationTanh        ActivationCode = 2
+	ActivationSoftmax     ActivationCode = 3
+	ActivationLeakyRelu   ActivationCode = 4
+	ActivationPRelu       ActivationCode = 5
+	ActivationElu         ActivationCode = 6
+	ActivationSelu        ActivationCode = 7
+	ActivationGelu        ActivationCode = 8
+	ActivationSwish       ActivationCode = 9
+	ActivationMish        ActivationCode = 10
+	ActivationSoftplus    ActivationCode = 11
+	ActivationSoftsign    ActivationCode = 12
+	ActivationHardSigmoid ActivationCode = 13
+)
+
+// constants of selu, see https://arxiv.org/abs/1706.02515
 const (
-	ActivationSigmoid = 0
-	ActivationRelu    = 1
-	ActivationTanh    = 2
+	seluScale = 1.0507009873554804934193349852946
+	seluAlpha = 1.6732632423543772848170429916717
 )
 
+// an activation function together with its derivative
+// both are applied to each element of the input separately
+//
+// the name identifies the function, e.g. inside the registry
+// custom functions can be passed directly to NewActivationWith or registered with RegisterActivation
+type ActivationFunction interface {
+	Name() string
+	Activate(x float64) float64
+	Derivative(x float64) float64
+}
+
 // tuple of activationFunction and activationFunctionDerivative
 type activationTuple struct {
+	name                 string
 	activation           activationFunc
 	activationDerivative activationFunc
 }
 
+func (t activationTuple) Name() string {
+	return t.name
+}
+
+func (t activationTuple) Activate(x float64) float64 {
+	return t.activation(x)
+}
+
+func (t activationTuple) Derivative(x float64) float64 {
+	return t.activationDerivative(x)
+}
+
+// creates an ActivationFunction from a function and its derivative
+func NewActivationFunction(name string, activation, activationDerivative func(float64) float64) ActivationFunction {
+	return activationTuple{name, activation, activationDerivative}
+}
+
+// all element wise activation functions without parameters, the index is the specification number
+var activationTuples = []activationTuple{
+	ActivationSigmoid:     {"sigmoid", Sigmoid, SigmoidDerivative},
+	ActivationRelu:        {"relu", Relu, ReluDerivative},
+	ActivationTanh:        {"tanh", Tanh, TanhDerivative},
+	ActivationSelu:        {"selu", Selu, SeluDerivative},
+	ActivationGelu:        {"gelu", Gelu, GeluDerivative},
+	ActivationSwish:       {"swish", Swish, SwishDerivative},
+	ActivationMish:        {"mish", Mish, MishDerivative},
+	ActivationSoftplus:    {"softplus", Softplus, SoftplusDerivative},
+	ActivationSoftsign:    {"softsign", Softsign, SoftsignDerivative},
+	ActivationHardSigmoid: {"hard_sigmoid", HardSigmoid, HardSigmoidDerivative},
+}
+
+// the built in activation functions as typed values
+var (
+	SigmoidActivation     ActivationFunction = activationTuples[ActivationSigmoid]
+	ReluActivation        ActivationFunction = activationTuples[ActivationRelu]
+	TanhActivation        ActivationFunction = activationTuples[ActivationTanh]
+	SeluActivation        ActivationFunction = activationTuples[ActivationSelu]
+	GeluActivation        ActivationFunction = activationTuples[ActivationGelu]
+	SwishActivation       ActivationFunction = activationTuples[ActivationSwish]
+	MishActivation        ActivationFunction = activationTuples[ActivationMish]
+	SoftplusActivation    ActivationFunction = activationTuples[ActivationSoftplus]
+	SoftsignActivation    ActivationFunction = activationTuples[ActivationSoftsign]
+	HardSigmoidActivation ActivationFunction = activationTuples[ActivationHardSigmoid]
+)
+
 // get function tuple based on specification number
-func getActivationTuple(activationSpecs int) (activationTuple, error) {
+func getActivationTuple(activationSpecs ActivationCode) (activationTuple, error) {
 	var funcs activationTuple
-	if activationSpecs < 0 || activationSpecs > 2 {
+	if activationSpecs < 0 || int(activationSpecs) >= len(activationTuples) || activationTuples[activationSpecs].name == "" {
 		return funcs, fmt.Errorf("wrong specification")
 	}
 
-	if activationSpecs == ActivationSigmoid {
-		funcs = activationTuple{Sigmoid, SigmoidDerivative}
-		return funcs, nil
-	} else if activationSpecs == ActivationRelu {
-		funcs = activationTuple{Relu, ReluDerivative}
-		return funcs, nil
-	} else {
-		funcs = activationTuple{Tanh, TanhDerivative}
-		return funcs, nil
+	return activationTuples[activationSpecs], nil
+}
+
+// get activation function based on specification number
+// parameterised functions are created with their default parameters
+func getActivationFunction(activationSpecs ActivationCode) (ActivationFunction, error) {
+	if activationSpecs == ActivationLeakyRelu {
+		return LeakyRelu{0.01}, nil
+	} else if activationSpecs == ActivationPRelu {
+		// each layer needs its own PRelu, because the slope is learned
+		return NewPRelu(0.25), nil
+	} else if activationSpecs == ActivationElu {
+		return Elu{1}, nil
 	}
+	return getActivationTuple(activationSpecs)
 }
 
 // activationFunc is a function that takes a float as input and has float as output
@@ -69,3 +162,271 @@ func Tanh(x float64) float64 {
 func TanhDerivative(x float64) float64 {
 	return 1 - math.Pow(math.Tanh(x), 2)
 }
+
+func Selu(x float64) float64 {
+	if x > 0 {
+		return seluScale * x
+	}
+	return seluScale * seluAlpha * (math.Exp(x) - 1)
+}
+
+func SeluDerivative(x float64) float64 {
+	if x > 0 {
+		return seluScale
+	}
+	return seluScale * seluAlpha * math.Exp(x)
+}
+
+// gelu with the exact gaussian cumulative distribution function
+//
+// gelu(x) = x * Φ(x)
+func Gelu(x float64) float64 {
+	return 0.5 * x * (1 + math.Erf(x/math.Sqrt2))
+}
+
+func GeluDerivative(x float64) float64 {
+	return 0.5*(1+math.Erf(x/math.Sqrt2)) + x*math.Exp(-0.5*x*x)/math.Sqrt(2*math.Pi)
+}
+
+// swish with beta 1, also known as SiLU
+//
+// swish(x) = x * sigmoid(x)
+func Swish(x float64) float64 {
+	return x * Sigmoid(x)
+}
+
+func SwishDerivative(x float64) float64 {
+	s := Sigmoid(x)
+	return s + x*s*(1-s)
+}
+
+// mish(x) = x * tanh(softplus(x))
+func Mish(x float64) float64 {
+	return x * math.Tanh(Softplus(x))
+}
+
+func MishDerivative(x float64) float64 {
+	t := math.Tanh(Softplus(x))
+	return t + x*(1-t*t)*Sigmoid(x)
+}
+
+// softplus(x) = log(1 + exp(x))
+//
+// calculated as max(x, 0) + log(1 + exp(-|x|)) to prevent overflows
+func Softplus(x float64) float64 {
+	return math.Max(x, 0) + math.Log1p(math.Exp(-math.Abs(x)))
+}
+
+func SoftplusDerivative(x float64) float64 {
+	return Sigmoid(x)
+}
+
+// softsign(x) = x / (1 + |x|)
+func Softsign(x float64) float64 {
+	return x / (1 + math.Abs(x))
+}
+
+func SoftsignDerivative(x float64) float64 {
+	return 1 / math.Pow(1+math.Abs(x), 2)
+}
+
+// piecewise linear approximation of sigmoid
+//
+// hardSigmoid(x) = max(0, min(1, x / 6 + 0.5))
+func HardSigmoid(x float64) float64 {
+	return math.Max(0, math.Min(1, x/6+0.5))
+}
+
+func HardSigmoidDerivative(x float64) float64 {
+	if x <= -3 || x >= 3 {
+		return 0
+	}
+	return 1.0 / 6
+}
+
+// relu with a small slope alpha for negative inputs
+type LeakyRelu struct {
+	Alpha float64
+}
+
+func (f LeakyRelu) Name() string {
+	return "leaky_relu"
+}
+
+func (f LeakyRelu) Activate(x float64) float64 {
+	if x < 0 {
+		return f.Alpha * x
+	}
+	return x
+}
+
+func (f LeakyRelu) Derivative(x float64) float64 {
+	if x < 0 {
+		return f.Alpha
+	}
+	return 1
+}
+
+// exponential linear unit
+//
+// elu(x) = x for x > 0 and alpha * (exp(x) - 1) otherwise
+type Elu struct {
+	Alpha float64
+}
+
+func (f Elu) Name() string {
+	return "elu"
+}
+
+func (f Elu) Activate(x float64) float64 {
+	if x > 0 {
+		return x
+	}
+	return f.Alpha * (math.Exp(x) - 1)
+}
+
+func (f Elu) Derivative(x float64) float64 {
+	if x > 0 {
+		return 1
+	}
+	return f.Alpha * math.Exp(x)
+}
+
+// activation functions with hyperparameters
+//
+// config returns the hyperparameters and withConfig creates a new function from them,
+// this is used to save and load the function by its name
+type configurableActivation interface {
+	config() []float64
+	withConfig(config []float64) (ActivationFunction, error)
+}
+
+func (f LeakyRelu) config() []float64 {
+	return []float64{f.Alpha}
+}
+
+func (f LeakyRelu) withConfig(config []float64) (ActivationFunction, error) {
+	if len(config) != 1 {
+		return nil, fmt.Errorf("leaky_relu needs alpha as config")
+	}
+	return LeakyRelu{config[0]}, nil
+}
+
+func (f Elu) config() []float64 {
+	return []float64{f.Alpha}
+}
+
+func (f Elu) withConfig(config []float64) (ActivationFunction, error) {
+	if len(config) != 1 {
+		return nil, fmt.Errorf("elu needs alpha as config")
+	}
+	return Elu{config[0]}, nil
+}
+
+// activation functions with parameters, that are learned during training
+//
+// the Activation layer passes its input and the outputGradient to gradients
+// and hands the params to the optimizer of the network
+// replica returns a copy, that shares the parameters but has its own gradients
+type trainableActivation interface {
+	ActivationFunction
+	params() []Param
+	gradients(input, outputGradient mat.Dense)
+	replica() trainableActivation
+}
+
+// parametric relu, where the slope for negative inputs is learned
+//
+// all elements of a layer share the same slope
+// the slope is state of the function, so each layer needs its own PRelu
+// paper: https://arxiv.org/abs/1502.01852
+type PRelu struct {
+	alpha    []float64
+	gradient []float64
+}
+
+// constructor for PRelu with the initial slope alpha
+func NewPRelu(alpha float64) *PRelu {
+	return &PRelu{[]float64{alpha}, []float64{0}}
+}
+
+// returns the current slope
+func (f *PRelu) Alpha() float64 {
+	return f.alpha[0]
+}
+
+func (f *PRelu) Name() string {
+	return "prelu"
+}
+
+func (f *PRelu) Activate(x float64) float64 {
+	if x < 0 {
+		return f.alpha[0] * x
+	}
+	return x
+}
+
+func (f *PRelu) Derivative(x float64) float64 {
+	if x < 0 {
+		return f.alpha[0]
+	}
+	return 1
+}
+
+// the slope is a learned parameter and not part of the config,
+// so withConfig always creates a new PRelu with the initial slope
+func (f *PRelu) config() []float64 {
+	return nil
+}
+
+func (f *PRelu) withConfig(config []float64) (ActivationFunction, error) {
+	return NewPRelu(0.25), nil
+}
+
+func (f *PRelu) replica() trainableActivation {
+	return &PRelu{f.alpha, make([]float64, len(f.gradient))}
+}
+
+func (f *PRelu) params() []Param {
+	return []Param{{Value: f.alpha, Gradient: f.gradient}}
+}
+
+// the derivative of the output with respect to alpha is the input for negative inputs
+func (f *PRelu) gradients(input, outputGradient mat.Dense) {
+	rows, cols := input.Dims()
+	sum := 0.0
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			if x := input.At(i, j); x < 0 {
+				sum += x * outputGradient.At(i, j)
+			}
+		}
+	}
+	f.gradient[0] = sum
+}
+
+// softmax of the input, the result is written into output
+//
+// the max value is subtracted before exponentiation,
+// this doesn't change the result but prevents overflows
+func softmax(input, output []float64) {
+	maxValue := floats.Max(input)
+	sum := 0.0
+	for i, v := range input {
+		output[i] = math.Exp(v - maxValue)
+		sum += output[i]
+	}
+	floats.Scale(1/sum, output)
+}
+
+// logarithm of the sum of the exponentials of the input
+//
+// the max value is subtracted before exponentiation to prevent overflows
+func logSumExp(input []float64) float64 {
+	maxValue := floats.Max(input)
+	sum := 0.0
+	for _, v := range input {
+		sum += math.Exp(v - maxValue)
+	}
+	return maxValue + math.Log(sum)
+}
@@ -202,3 +202,105 @@ func TestTanhDerivative(t *testing.T) {
 		}
 	})
 }
+
+func TestGetActivationFunction(t *testing.T) {
+	for specs := ActivationSigmoid; specs <= ActivationHardSigmoid; specs++ {
+		function, err := getActivationFunction(specs)
+		if specs == ActivationSoftmax {
+			if err == nil {
+				t.Error("Expected error for softmax.")
+			}
+			continue
+		}
+
+		if err != nil || function == nil || function.Name() == "" {
+			t.Errorf("Expected activation function for %v, Got: %v, %v", specs, function, err)
+		}
+	}
+
+	if _, err := getActivationFunction(100); err == nil {
+		t.Error("Expected error.")
+	}
+
+	// each PRelu is a new function, because the slope is learned
+	first, _ := getActivationFunction(ActivationPRelu)
+	second, _ := getActivationFunction(ActivationPRelu)
+	if first == second {
+		t.Error("Expected different PRelu functions")
+	}
+}
+
+// compares the derivative of each activation function with the numerical derivative
+func TestActivationDerivatives(t *testing.T) {
+	const h = 1e-6
+	points := []float64{-4, -1.5, -0.3, 0.2, 0.7, 2.5, 5}
+
+	for specs := ActivationSigmoid; specs <= ActivationHardSigmoid; specs++ {
+		function, err := getActivationFunction(specs)
+		if err != nil {
+			continue
+		}
+
+		t.Run(function.Name(), func(t *testing.T) {
+			for _, x := range points {
+				numeric := (function.Activate(x+h) - function.Activate(x-h)) / (2 * h)
+				if math.Abs(function.Derivative(x)-numeric) > 1e-6 {
+					t.Errorf("x = %v: Expected: %v, Got: %v", x, numeric, function.Derivative(x))
+				}
+			}
+		})
+	}
+}
+
+func TestExtendedActivations(t *testing.T) {
+	tests := []struct {
+		name     string
+		function func(float64) float64
+		x        float64
+		expected float64
+	}{
+		{"LeakyReluNegative", LeakyRelu{0.1}.Activate, -2, -0.2},
+		{"LeakyReluPositive", LeakyRelu{0.1}.Activate, 2, 2},
+		{"EluNegative", Elu{2}.Activate, -1, 2 * (math.Exp(-1) - 1)},
+		{"EluPositive", Elu{2}.Activate, 3, 3},
+		{"SeluZero", Selu, 0, 0},
+		{"SeluPositive", Selu, 1, seluScale},
+		{"GeluZero", Gelu, 0, 0},
+		{"GeluLarge", Gelu, 10, 10},
+		{"SwishZero", Swish, 0, 0},
+		{"MishZero", Mish, 0, 0},
+		{"SoftplusZero", Softplus, 0, math.Ln2},
+		{"SoftplusLarge", Softplus, 1000, 1000},
+		{"Softsign", Softsign, 1, 0.5},
+		{"HardSigmoidZero", HardSigmoid, 0, 0.5},
+		{"HardSigmoidLarge", HardSigmoid, 10, 1},
+		{"HardSigmoidSmall", HardSigmoid, -10, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ans := test.function(test.x)
+			if math.Abs(ans-test.expected) > 1e-12 {
+				t.Errorf("Expected: %v, Got: %v", test.expected, ans)
+			}
+		})
+	}
+}
+
+func TestPRelu(t *testing.T) {
+	prelu := NewPRelu(0.25)
+
+	if prelu.Activate(-4) != -1 || prelu.Activate(4) != 4 {
+		t.Errorf("Expected: %v, Got: %v", -1, prelu.Activate(-4))
+	}
+
+	if prelu.Derivative(-4) != 0.25 || prelu.Derivative(4) != 1 {
+		t.Errorf("Expected: %v, Got: %v", 0.25, prelu.Derivative(-4))
+	}
+
+	// the slope is updated through its parameter
+	prelu.params()[0].Value[0] = 0.5
+	if prelu.Alpha() != 0.5 || prelu.Activate(-4) != -2 {
+		t.Errorf("Expected: %v, Got: %v", 0.5, prelu.Alpha())
+	}
+}
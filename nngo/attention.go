@@ -0,0 +1,775 @@
+package nngo
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// positions of the projections of MultiHeadAttention
+const (
+	attentionQuery = iota
+	attentionKey
+	attentionValue
+	attentionOutput
+)
+
+// self-attention with several heads over a sequence in the layout of Signal(features, steps)
+//
+// each step is projected to a query, a key and a value, the features are split evenly between the heads
+// each head weights the values of all steps by softmax(query * key / sqrt(features / heads))
+// the outputs of the heads are concatenated and projected back to features values for each step,
+// so the output has the same shape as the input
+//
+// WithRegularizer and WithConstraint apply to the weights of the four projections like for Dense
+// WithCausalMask only lets each step attend to itself and the earlier steps
+// WithPaddingMask treats steps, where all features are zero, as padding, e.g. of NewSequenceSet:
+// no step attends to them and their output is zero
+// paper: https://arxiv.org/abs/1706.03762
+type MultiHeadAttention struct {
+	input       Shape
+	heads       int
+	causal      bool
+	paddingMask bool
+	// projections in the order query, key, value and output
+	weights         [4]mat.Dense
+	biases          [4]mat.VecDense
+	weightsGradient [4]mat.Dense
+	biasesGradient  [4]mat.VecDense
+	regularizer     Regularizer
+	constraint      Constraint
+	// values of each sample of the last forward propagation
+	cache []attentionCache
+}
+
+// values of one sample, which backward needs
+type attentionCache struct {
+	// matrices with one row for each step
+	input, query, key, value, context mat.Dense
+	// attention weights of each head
+	attention []mat.Dense
+	padded    []bool
+}
+
+// constructor for MultiHeadAttention layer
+//
+// input needs a height of 1, see Signal, and the features need to be divisible by heads
+// the projections are initialized like the weights of Dense, see WithWeightInit and WithBiasInit
+func NewMultiHeadAttention(input Shape, heads int, opts ...Option) (*MultiHeadAttention, error) {
+	if !input.valid() {
+		return nil, fmt.Errorf("input shape %v must be positive", input)
+	}
+	if input.Height != 1 {
+		return nil, fmt.Errorf("attention layers need an input with height 1, got %v", input)
+	}
+	if heads <= 0 || input.Channels%heads != 0 {
+		return nil, fmt.Errorf("heads must be greater than 0 and divide the %v features", input.Channels)
+	}
+
+	config := newOptions(opts)
+	if err := config.regularizer.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.constraint.validate(); err != nil {
+		return nil, err
+	}
+	features := input.Channels
+	m := MultiHeadAttention{
+		input:       input,
+		heads:       heads,
+		causal:      config.causalMask,
+		paddingMask: config.paddingMask,
+		regularizer: config.regularizer,
+		constraint:  config.constraint,
+	}
+	for i := range m.weights {
+		m.weights[i] = *mat.NewDense(features, features, nil)
+		m.biases[i] = *mat.NewVecDense(features, nil)
+		m.weightsGradient[i] = *mat.NewDense(features, features, nil)
+		m.biasesGradient[i] = *mat.NewVecDense(features, nil)
+		config.weightInit(m.weights[i].RawMatrix().Data, features, features, config.random)
+		config.biasInit(m.biases[i].RawVector().Data, features, features, config.random)
+	}
+	return &m, nil
+}
+
+// returns the shape of the output of one sample, which is the shape of the input
+func (m *MultiHeadAttention) OutputShape() Shape {
+	return m.input
+}
+
+func (m *MultiHeadAttention) forward(input mat.Dense) mat.Dense {
+	output, cache := m.run(input, true)
+	m.cache = cache
+	return output
+}
+
+func (m *MultiHeadAttention) predict(input mat.Dense) mat.Dense {
+	output, _ := m.run(input, false)
+	return output
+}
+
+// attends each sample on its own, keep also returns the values of each sample for backward
+func (m *MultiHeadAttention) run(input mat.Dense, keep bool) (mat.Dense, []attentionCache) {
+	_, samples := input.Dims()
+	padded := m.padded(input)
+	ans := mat.NewDense(m.input.Size(), samples, nil)
+	var caches []attentionCache
+	for n := 0; n < samples; n++ {
+		cache := attentionCache{input: sampleSteps(input, n, m.input), padded: padded[n]}
+		output := m.attend(&cache)
+		setSampleSteps(ans, n, m.input, output)
+		if keep {
+			caches = append(caches, cache)
+		}
+	}
+	return *ans, caches
+}
+
+// returns the padded steps of each sample, which are all false without WithPaddingMask
+func (m *MultiHeadAttention) padded(input mat.Dense) [][]bool {
+	if m.paddingMask {
+		return paddedSteps(input, m.input)
+	}
+	_, samples := input.Dims()
+	padded := make([][]bool, samples)
+	for n := range padded {
+		padded[n] = make([]bool, m.input.Width)
+	}
+	return padded
+}
+
+// calculates the output of one sample and fills the cache
+func (m *MultiHeadAttention) attend(cache *attentionCache) mat.Dense {
+	steps, features := m.input.Width, m.input.Channels
+	size := features / m.heads
+	scale := 1 / math.Sqrt(float64(size))
+
+	cache.query = m.project(attentionQuery, &cache.input)
+	cache.key = m.project(attentionKey, &cache.input)
+	cache.value = m.project(attentionValue, &cache.input)
+	cache.context = *mat.NewDense(steps, features, nil)
+	cache.attention = make([]mat.Dense, m.heads)
+	for h := 0; h < m.heads; h++ {
+		query := cache.query.Slice(0, steps, h*size, (h+1)*size)
+		key := cache.key.Slice(0, steps, h*size, (h+1)*size)
+		value := cache.value.Slice(0, steps, h*size, (h+1)*size)
+
+		var attention mat.Dense
+		attention.Mul(query, key.T())
+		attention.Scale(scale, &attention)
+		for i := 0; i < steps; i++ {
+			m.softmax(attention.RawRowView(i), i, cache.padded)
+		}
+		cache.context.Slice(0, steps, h*size, (h+1)*size).(*mat.Dense).Mul(&attention, value)
+		cache.attention[h] = attention
+	}
+
+	output := m.project(attentionOutput, &cache.context)
+	for t, padded := range cache.padded {
+		if padded {
+			zero(output.RawRowView(t))
+		}
+	}
+	return output
+}
+
+// returns x * weights^T + bias of the projection i
+func (m *MultiHeadAttention) project(i int, x *mat.Dense) mat.Dense {
+	var ans mat.Dense
+	ans.Mul(x, m.weights[i].T())
+	rows, _ := ans.Dims()
+	for t := 0; t < rows; t++ {
+		floats.Add(ans.RawRowView(t), m.biases[i].RawVector().Data)
+	}
+	return ans
+}
+
+// replaces the scores of the query step with the softmax over the steps that aren't masked,
+// the masked steps get a weight of 0
+func (m *MultiHeadAttention) softmax(scores []float64, step int, padded []bool) {
+	max := math.Inf(-1)
+	for j, s := range scores {
+		if m.visible(step, j, padded) && s > max {
+			max = s
+		}
+	}
+	// all steps are masked, e.g. for padding at the beginning with a causal mask
+	if math.IsInf(max, -1) {
+		zero(scores)
+		return
+	}
+
+	sum := 0.0
+	for j, s := range scores {
+		if m.visible(step, j, padded) {
+			scores[j] = math.Exp(s - max)
+			sum += scores[j]
+		} else {
+			scores[j] = 0
+		}
+	}
+	floats.Scale(1/sum, scores)
+}
+
+// checks if the query step can attend to the key step
+func (m *MultiHeadAttention) visible(query, key int, padded []bool) bool {
+	return !(m.causal && key > query) && !padded[key]
+}
+
+// the gradient of each sample is calculated on its own, the gradients of the projections are added up
+func (m *MultiHeadAttention) backward(outputGradient mat.Dense) mat.Dense {
+	steps, features := m.input.Width, m.input.Channels
+	size := features / m.heads
+	scale := 1 / math.Sqrt(float64(size))
+	_, samples := outputGradient.Dims()
+
+	for i := range m.weightsGradient {
+		m.weightsGradient[i].Zero()
+		m.biasesGradient[i].Zero()
+	}
+
+	ans := mat.NewDense(m.input.Size(), samples, nil)
+	for n := 0; n < samples; n++ {
+		cache := m.cache[n]
+		gradient := sampleSteps(outputGradient, n, m.input)
+		for t, padded := range cache.padded {
+			if padded {
+				zero(gradient.RawRowView(t))
+			}
+		}
+
+		context := m.projectBackward(attentionOutput, &cache.context, &gradient)
+		query := mat.NewDense(steps, features, nil)
+		key := mat.NewDense(steps, features, nil)
+		value := mat.NewDense(steps, features, nil)
+		for h := 0; h < m.heads; h++ {
+			contextGradient := context.Slice(0, steps, h*size, (h+1)*size)
+			attention := &cache.attention[h]
+
+			var attentionGradient mat.Dense
+			attentionGradient.Mul(contextGradient, cache.value.Slice(0, steps, h*size, (h+1)*size).T())
+			value.Slice(0, steps, h*size, (h+1)*size).(*mat.Dense).Mul(attention.T(), contextGradient)
+
+			// gradient of the softmax of each row, multiplied by the scale of the scores
+			for i := 0; i < steps; i++ {
+				a, g := attention.RawRowView(i), attentionGradient.RawRowView(i)
+				dot := floats.Dot(a, g)
+				for j := range g {
+					g[j] = a[j] * (g[j] - dot) * scale
+				}
+			}
+			query.Slice(0, steps, h*size, (h+1)*size).(*mat.Dense).Mul(&attentionGradient, cache.key.Slice(0, steps, h*size, (h+1)*size))
+			key.Slice(0, steps, h*size, (h+1)*size).(*mat.Dense).Mul(attentionGradient.T(), cache.query.Slice(0, steps, h*size, (h+1)*size))
+		}
+
+		inputGradient := m.projectBackward(attentionQuery, &cache.input, query)
+		keyInput := m.projectBackward(attentionKey, &cache.input, key)
+		valueInput := m.projectBackward(attentionValue, &cache.input, value)
+		inputGradient.Add(&inputGradient, &keyInput)
+		inputGradient.Add(&inputGradient, &valueInput)
+		setSampleSteps(ans, n, m.input, inputGradient)
+	}
+	return *ans
+}
+
+// adds the gradients of the projection i to its gradients and returns the gradient of x
+func (m *MultiHeadAttention) projectBackward(i int, x, gradient *mat.Dense) mat.Dense {
+	addProduct(&m.weightsGradient[i], gradient.T(), x)
+	rows, _ := gradient.Dims()
+	bias := m.biasesGradient[i].RawVector().Data
+	for t := 0; t < rows; t++ {
+		floats.Add(bias, gradient.RawRowView(t))
+	}
+
+	var ans mat.Dense
+	ans.Mul(gradient, &m.weights[i])
+	return ans
+}
+
+func (m *MultiHeadAttention) state() layerState {
+	state := layerState{
+		Type:   "multi_head_attention",
+		Shape:  []int{m.input.Channels, m.input.Width, m.heads},
+		Config: []float64{boolToFloat(m.causal), boolToFloat(m.paddingMask)},
+	}
+	if m.regularizer != (Regularizer{}) || m.constraint != (Constraint{}) {
+		state.Config = append(state.Config, m.regularizer.L1, m.regularizer.L2, m.constraint.value)
+		state.Constraint = m.constraint.name
+	}
+	return state
+}
+
+func (m *MultiHeadAttention) replica() Layer {
+	features := m.input.Channels
+	r := MultiHeadAttention{
+		input:       m.input,
+		heads:       m.heads,
+		causal:      m.causal,
+		paddingMask: m.paddingMask,
+		regularizer: m.regularizer,
+		constraint:  m.constraint,
+	}
+	for i := range m.weights {
+		r.weights[i] = m.weights[i]
+		r.biases[i] = m.biases[i]
+		r.weightsGradient[i] = *mat.NewDense(features, features, nil)
+		r.biasesGradient[i] = *mat.NewVecDense(features, nil)
+	}
+	return &r
+}
+
+// returns the weights and the bias of the query, key, value and output projection together with their gradients
+func (m *MultiHeadAttention) params() []Param {
+	var params []Param
+	for i := range m.weights {
+		params = append(params,
+			Param{Value: m.weights[i].RawMatrix().Data, Gradient: m.weightsGradient[i].RawMatrix().Data},
+			Param{Value: m.biases[i].RawVector().Data, Gradient: m.biasesGradient[i].RawVector().Data},
+		)
+	}
+	return params
+}
+
+func (m *MultiHeadAttention) penalty() float64 {
+	sum := 0.0
+	for i := range m.weights {
+		sum += m.regularizer.penalty(m.weights[i].RawMatrix().Data)
+	}
+	return sum
+}
+
+func (m *MultiHeadAttention) addPenaltyGradient() {
+	for i := range m.weights {
+		m.regularizer.addGradient(m.weights[i].RawMatrix().Data, m.weightsGradient[i].RawMatrix().Data)
+	}
+}
+
+func (m *MultiHeadAttention) constrain() {
+	for i := range m.weights {
+		m.constraint.apply(&m.weights[i])
+	}
+}
+
+// adds sinusoidal position information to a sequence in the layout of Signal(features, steps)
+//
+// feature 2i of step t gets sin(t / 10000^(2i / features)) and feature 2i + 1 the cos of the same value,
+// so every step has a different pattern and nearby steps have similar patterns
+// with WithPaddingMask steps, where all features are zero, stay zero
+// the positions count from the beginning of the sequence including padding
+type PositionalEncoding struct {
+	input       Shape
+	paddingMask bool
+	encoding    []float64
+}
+
+// constructor for PositionalEncoding layer
+//
+// input needs a height of 1, see Signal
+func NewPositionalEncoding(input Shape, opts ...Option) (*PositionalEncoding, error) {
+	if !input.valid() || input.Height != 1 {
+		return nil, fmt.Errorf("positional encodings need a positive input with height 1, got %v", input)
+	}
+
+	features, steps := input.Channels, input.Width
+	encoding := make([]float64, input.Size())
+	for f := 0; f < features; f++ {
+		frequency := math.Pow(10000, -float64(f-f%2)/float64(features))
+		for t := 0; t < steps; t++ {
+			if f%2 == 0 {
+				encoding[f*steps+t] = math.Sin(float64(t) * frequency)
+			} else {
+				encoding[f*steps+t] = math.Cos(float64(t) * frequency)
+			}
+		}
+	}
+	return &PositionalEncoding{input, newOptions(opts).paddingMask, encoding}, nil
+}
+
+// returns the shape of the output of one sample, which is the shape of the input
+func (p *PositionalEncoding) OutputShape() Shape {
+	return p.input
+}
+
+func (p *PositionalEncoding) forward(input mat.Dense) mat.Dense {
+	return p.predict(input)
+}
+
+func (p *PositionalEncoding) predict(input mat.Dense) mat.Dense {
+	return addEncoding(input, p.input, p.encoding, p.paddingMask)
+}
+
+// the encoding is constant, so the gradient passes through unchanged
+func (p *PositionalEncoding) backward(outputGradient mat.Dense) mat.Dense {
+	return outputGradient
+}
+
+func (p *PositionalEncoding) state() layerState {
+	return layerState{
+		Type:   "positional_encoding",
+		Shape:  []int{p.input.Channels, p.input.Width},
+		Config: []float64{boolToFloat(p.paddingMask)},
+	}
+}
+
+func (p *PositionalEncoding) replica() Layer {
+	return &PositionalEncoding{p.input, p.paddingMask, p.encoding}
+}
+
+// adds learned position information to a sequence in the layout of Signal(features, steps)
+//
+// each value of the sequence has its own learned offset, which is the same for all samples
+// with WithPaddingMask steps, where all features are zero, stay zero
+type LearnedPositionalEncoding struct {
+	input       Shape
+	paddingMask bool
+	encoding    mat.VecDense
+	gradient    mat.VecDense
+	// padded steps of each sample of the last forward propagation
+	padded [][]bool
+}
+
+// constructor for LearnedPositionalEncoding layer
+//
+// input needs a height of 1, see Signal
+// the encoding is initialized with WithWeightInit, the steps are the fan in and the features the fan out
+func NewLearnedPositionalEncoding(input Shape, opts ...Option) (*LearnedPositionalEncoding, error) {
+	if !input.valid() || input.Height != 1 {
+		return nil, fmt.Errorf("positional encodings need a positive input with height 1, got %v", input)
+	}
+
+	config := newOptions(opts)
+	l := LearnedPositionalEncoding{
+		input:       input,
+		paddingMask: config.paddingMask,
+		encoding:    *mat.NewVecDense(input.Size(), nil),
+		gradient:    *mat.NewVecDense(input.Size(), nil),
+	}
+	config.weightInit(l.encoding.RawVector().Data, input.Width, input.Channels, config.random)
+	return &l, nil
+}
+
+// returns the shape of the output of one sample, which is the shape of the input
+func (l *LearnedPositionalEncoding) OutputShape() Shape {
+	return l.input
+}
+
+func (l *LearnedPositionalEncoding) forward(input mat.Dense) mat.Dense {
+	if l.paddingMask {
+		l.padded = paddedSteps(input, l.input)
+	}
+	return l.predict(input)
+}
+
+func (l *LearnedPositionalEncoding) predict(input mat.Dense) mat.Dense {
+	return addEncoding(input, l.input, l.encoding.RawVector().Data, l.paddingMask)
+}
+
+// the gradient of the encoding is the sum of the output gradients of all samples without the padded steps
+func (l *LearnedPositionalEncoding) backward(outputGradient mat.Dense) mat.Dense {
+	steps := l.input.Width
+	rows, samples := outputGradient.Dims()
+	gradient := l.gradient.RawVector().Data
+	for i := 0; i < rows; i++ {
+		row := outputGradient.RawRowView(i)
+		sum := 0.0
+		for n := 0; n < samples; n++ {
+			if !l.paddingMask || !l.padded[n][i%steps] {
+				sum += row[n]
+			}
+		}
+		gradient[i] = sum
+	}
+	return outputGradient
+}
+
+func (l *LearnedPositionalEncoding) state() layerState {
+	return layerState{
+		Type:   "learned_positional_encoding",
+		Shape:  []int{l.input.Channels, l.input.Width},
+		Config: []float64{boolToFloat(l.paddingMask)},
+	}
+}
+
+func (l *LearnedPositionalEncoding) replica() Layer {
+	return &LearnedPositionalEncoding{
+		input:       l.input,
+		paddingMask: l.paddingMask,
+		encoding:    l.encoding,
+		gradient:    *mat.NewVecDense(l.input.Size(), nil),
+	}
+}
+
+// returns the encoding together with its gradient
+func (l *LearnedPositionalEncoding) params() []Param {
+	return []Param{{Value: l.encoding.RawVector().Data, Gradient: l.gradient.RawVector().Data}}
+}
+
+// adds the encoding to each sample, with paddingMask the padded steps are skipped
+func addEncoding(input mat.Dense, shape Shape, encoding []float64, paddingMask bool) mat.Dense {
+	ans := mat.DenseCopyOf(&input)
+	rows, _ := ans.Dims()
+	var padded [][]bool
+	if paddingMask {
+		padded = paddedSteps(input, shape)
+	}
+	for i := 0; i < rows; i++ {
+		row := ans.RawRowView(i)
+		for n := range row {
+			if padded == nil || !padded[n][i%shape.Width] {
+				row[n] += encoding[i]
+			}
+		}
+	}
+	return *ans
+}
+
+// encoder block of a transformer, which plugs into a Network like any other layer
+//
+// self-attention and a feed-forward network, which is applied to each step on its own,
+// are each followed by a residual connection and a LayerNorm over the features of each step:
+// x = LayerNorm(x + MultiHeadAttention(x))
+// output = LayerNorm(x + Dense(Relu(Dense(x))))
+// the input and the output are sequences in the layout of Signal(features, steps)
+// paper: https://arxiv.org/abs/1706.03762
+type TransformerEncoderBlock struct {
+	attention  *MultiHeadAttention
+	firstNorm  *LayerNorm
+	hidden     *Dense
+	activation *Activation
+	output     *Dense
+	secondNorm *LayerNorm
+	// padded steps of each sample of the last forward propagation
+	padded [][]bool
+}
+
+// constructor for TransformerEncoderBlock layer
+//
+// heads is the number of attention heads and feedForward the size of the hidden layer of the feed-forward network
+// the options are passed to NewMultiHeadAttention and NewDense,
+// e.g. WithCausalMask, WithPaddingMask, WithRand or WithRegularizer and WithConstraint,
+// which apply to the attention projections and both Dense layers
+// with WithPaddingMask the output of padded steps is zero, so blocks can be stacked
+func NewTransformerEncoderBlock(input Shape, heads, feedForward int, opts ...Option) (*TransformerEncoderBlock, error) {
+	if feedForward <= 0 {
+		return nil, fmt.Errorf("feedForward must be greater than 0")
+	}
+	attention, err := NewMultiHeadAttention(input, heads, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	features := input.Channels
+	firstNorm, _ := NewLayerNorm(features, 1e-5)
+	hidden, err := NewDense(features, feedForward, opts...)
+	if err != nil {
+		return nil, err
+	}
+	activation, _ := NewActivationWith(feedForward, ReluActivation)
+	output, err := NewDense(feedForward, features, opts...)
+	if err != nil {
+		return nil, err
+	}
+	secondNorm, _ := NewLayerNorm(features, 1e-5)
+	return &TransformerEncoderBlock{
+		attention:  attention,
+		firstNorm:  firstNorm,
+		hidden:     hidden,
+		activation: activation,
+		output:     output,
+		secondNorm: secondNorm,
+	}, nil
+}
+
+// returns the shape of the output of one sample, which is the shape of the input
+func (b *TransformerEncoderBlock) OutputShape() Shape {
+	return b.attention.input
+}
+
+// returns the inner layers in the order of the forward propagation
+func (b *TransformerEncoderBlock) layers() []Layer {
+	return []Layer{b.attention, b.firstNorm, b.hidden, b.activation, b.output, b.secondNorm}
+}
+
+func (b *TransformerEncoderBlock) forward(input mat.Dense) mat.Dense {
+	b.padded = b.attention.padded(input)
+	return b.run(input, b.padded, func(layer Layer, x mat.Dense) mat.Dense {
+		return layer.forward(x)
+	})
+}
+
+func (b *TransformerEncoderBlock) predict(input mat.Dense) mat.Dense {
+	return b.run(input, b.attention.padded(input), func(layer Layer, x mat.Dense) mat.Dense {
+		return layer.predict(x)
+	})
+}
+
+// applies the inner layers with apply
+// the layers after the attention see each step of each sample as its own column
+func (b *TransformerEncoderBlock) run(input mat.Dense, padded [][]bool, apply func(layer Layer, x mat.Dense) mat.Dense) mat.Dense {
+	shape := b.attention.input
+	attention := apply(b.attention, input)
+	attention.Add(&attention, &input)
+
+	normalized := apply(b.firstNorm, stepColumns(attention, shape))
+	feedForward := apply(b.output, apply(b.activation, apply(b.hidden, normalized)))
+	feedForward.Add(&feedForward, &normalized)
+
+	ans := sequenceColumns(apply(b.secondNorm, feedForward), shape)
+	if b.attention.paddingMask {
+		zeroPadded(&ans, shape, padded)
+	}
+	return ans
+}
+
+// propagates the gradient back through both residual connections
+func (b *TransformerEncoderBlock) backward(outputGradient mat.Dense) mat.Dense {
+	shape := b.attention.input
+	gradient := *mat.DenseCopyOf(&outputGradient)
+	if b.attention.paddingMask {
+		zeroPadded(&gradient, shape, b.padded)
+	}
+
+	residual := b.secondNorm.backward(stepColumns(gradient, shape))
+	normalized := b.hidden.backward(b.activation.backward(b.output.backward(residual)))
+	normalized.Add(&normalized, &residual)
+
+	residual = sequenceColumns(b.firstNorm.backward(normalized), shape)
+	ans := b.attention.backward(residual)
+	ans.Add(&ans, &residual)
+	return ans
+}
+
+// the states of the inner layers are saved in Layers
+func (b *TransformerEncoderBlock) state() layerState {
+	state := layerState{Type: "transformer_encoder_block"}
+	for _, layer := range b.layers() {
+		state.Layers = append(state.Layers, layer.state())
+	}
+	return state
+}
+
+func (b *TransformerEncoderBlock) replica() Layer {
+	return &TransformerEncoderBlock{
+		attention:  b.attention.replica().(*MultiHeadAttention),
+		firstNorm:  b.firstNorm.replica().(*LayerNorm),
+		hidden:     b.hidden.replica().(*Dense),
+		activation: b.activation.replica().(*Activation),
+		output:     b.output.replica().(*Dense),
+		secondNorm: b.secondNorm.replica().(*LayerNorm),
+	}
+}
+
+// returns the parameters of the inner layers in the order of the forward propagation
+func (b *TransformerEncoderBlock) params() []Param {
+	return layerParams(b.layers())
+}
+
+// returns the penalties of the attention and the feed-forward network
+func (b *TransformerEncoderBlock) penalty() float64 {
+	return b.attention.penalty() + b.hidden.penalty() + b.output.penalty()
+}
+
+func (b *TransformerEncoderBlock) addPenaltyGradient() {
+	b.attention.addPenaltyGradient()
+	b.hidden.addPenaltyGradient()
+	b.output.addPenaltyGradient()
+}
+
+func (b *TransformerEncoderBlock) constrain() {
+	b.attention.constrain()
+	b.hidden.constrain()
+	b.output.constrain()
+}
+
+// returns the steps of sample n as a matrix with one row for each step
+func sampleSteps(input mat.Dense, n int, shape Shape) mat.Dense {
+	steps := shape.Width
+	ans := mat.NewDense(steps, shape.Channels, nil)
+	for f := 0; f < shape.Channels; f++ {
+		for t := 0; t < steps; t++ {
+			ans.Set(t, f, input.At(f*steps+t, n))
+		}
+	}
+	return *ans
+}
+
+// writes the steps of sample n back into the layout of Signal(features, steps), see sampleSteps
+func setSampleSteps(dst *mat.Dense, n int, shape Shape, values mat.Dense) {
+	steps := shape.Width
+	for f := 0; f < shape.Channels; f++ {
+		for t := 0; t < steps; t++ {
+			dst.Set(f*steps+t, n, values.At(t, f))
+		}
+	}
+}
+
+// reorders a batch of sequences, so that each step of each sample is its own column n * steps + t
+// layers for single vectors, e.g. Dense or LayerNorm, can then be applied to each step
+func stepColumns(input mat.Dense, shape Shape) mat.Dense {
+	steps := shape.Width
+	_, samples := input.Dims()
+	ans := mat.NewDense(shape.Channels, steps*samples, nil)
+	for f := 0; f < shape.Channels; f++ {
+		row := ans.RawRowView(f)
+		for t := 0; t < steps; t++ {
+			for n := 0; n < samples; n++ {
+				row[n*steps+t] = input.At(f*steps+t, n)
+			}
+		}
+	}
+	return *ans
+}
+
+// reverses stepColumns
+func sequenceColumns(input mat.Dense, shape Shape) mat.Dense {
+	steps := shape.Width
+	_, cols := input.Dims()
+	samples := cols / steps
+	ans := mat.NewDense(shape.Size(), samples, nil)
+	for f := 0; f < shape.Channels; f++ {
+		row := input.RawRowView(f)
+		for t := 0; t < steps; t++ {
+			for n := 0; n < samples; n++ {
+				ans.Set(f*steps+t, n, row[n*steps+t])
+			}
+		}
+	}
+	return *ans
+}
+
+// returns for each step of each sample, if all its features are zero
+func paddedSteps(input mat.Dense, shape Shape) [][]bool {
+	steps := shape.Width
+	_, samples := input.Dims()
+	padded := make([][]bool, samples)
+	for n := range padded {
+		padded[n] = make([]bool, steps)
+		for t := range padded[n] {
+			padded[n][t] = true
+			for f := 0; f < shape.Channels; f++ {
+				if input.At(f*steps+t, n) != 0 {
+					padded[n][t] = false
+					break
+				}
+			}
+		}
+	}
+	return padded
+}
+
+// sets the values of the padded steps to zero
+func zeroPadded(m *mat.Dense, shape Shape, padded [][]bool) {
+	steps := shape.Width
+	for n := range padded {
+		for t, p := range padded[n] {
+			if p {
+				for f := 0; f < shape.Channels; f++ {
+					m.Set(f*steps+t, n, 0)
+				}
+			}
+		}
+	}
+}
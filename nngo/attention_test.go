@@ -0,0 +1,293 @@
+package nngo
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestNewMultiHeadAttentionError(t *testing.T) {
+	if _, err := NewMultiHeadAttention(Shape{4, 2, 3}, 2); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewMultiHeadAttention(Signal(4, 3), 3); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewTransformerEncoderBlock(Signal(4, 3), 2, 0); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewPositionalEncoding(Shape{4, 2, 3}); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestMultiHeadAttentionGradients(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	input := normalMatrix(4*3, 2, random)
+	for _, opts := range [][]Option{nil, {WithCausalMask()}} {
+		attention, err := NewMultiHeadAttention(Signal(4, 3), 2, append(opts, WithRand(random), WithBiasInit(LeCunNormal))...)
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		checkGradients(t, attention, *input)
+	}
+}
+
+func TestMultiHeadAttentionCausal(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	attention, _ := NewMultiHeadAttention(Signal(2, 3), 1, WithCausalMask(), WithRand(random))
+	input := normalMatrix(2*3, 1, random)
+	output := attention.predict(*input)
+
+	// the last step doesn't change the output of the earlier steps
+	changed := mat.DenseCopyOf(input)
+	changed.Set(2, 0, 5)
+	changed.Set(5, 0, -5)
+	ans := attention.predict(*changed)
+	for _, row := range []int{0, 1, 3, 4} {
+		if math.Abs(output.At(row, 0)-ans.At(row, 0)) > 1e-12 {
+			t.Errorf("Expected: %v, Got: %v", output.At(row, 0), ans.At(row, 0))
+		}
+	}
+	if output.At(2, 0) == ans.At(2, 0) {
+		t.Errorf("Expected the last step to change, Got: %v", ans.At(2, 0))
+	}
+}
+
+func TestMultiHeadAttentionPadding(t *testing.T) {
+	// the projections don't depend on the number of steps, so both layers have the same weights
+	padded, _ := NewMultiHeadAttention(Signal(2, 3), 2, WithPaddingMask(), WithRand(rand.New(rand.NewSource(3))))
+	plain, _ := NewMultiHeadAttention(Signal(2, 2), 2, WithRand(rand.New(rand.NewSource(3))))
+
+	input := mat.NewDense(6, 1, []float64{0, 1, 2, 0, -1, 0.5})
+	output := padded.predict(*input)
+	expected := plain.predict(*mat.NewDense(4, 1, []float64{1, 2, -1, 0.5}))
+
+	ans := []float64{output.At(1, 0), output.At(2, 0), output.At(4, 0), output.At(5, 0)}
+	if diff := cmp.Diff(expected.RawMatrix().Data, ans, cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+		t.Errorf("Output mismatch (-want +got):\n%s", diff)
+	}
+	if output.At(0, 0) != 0 || output.At(3, 0) != 0 {
+		t.Errorf("Expected: %v, Got: %v", 0, output.RawMatrix().Data)
+	}
+}
+
+func TestPositionalEncoding(t *testing.T) {
+	encoding, _ := NewPositionalEncoding(Signal(4, 3), WithPaddingMask())
+	input := mat.NewDense(12, 2, nil)
+	input.Set(0, 1, 1)
+	output := encoding.forward(*input)
+
+	// the first sample only has padding, the second one at the last two steps
+	// step 0 gets sin(0) for the even and cos(0) for the odd features
+	expected := []float64{
+		1, 0, 0,
+		1, 0, 0,
+		0, 0, 0,
+		1, 0, 0,
+	}
+	if diff := cmp.Diff(expected, mat.Col(nil, 1, &output), cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+		t.Errorf("Encoding mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(make([]float64, 12), mat.Col(nil, 0, &output)); diff != "" {
+		t.Errorf("Encoding mismatch (-want +got):\n%s", diff)
+	}
+
+	plain, _ := NewPositionalEncoding(Signal(4, 3))
+	output = plain.predict(*mat.NewDense(12, 1, nil))
+	// feature 2 of step 2 is sin(2 / 10000^(2 / 4))
+	if ans, want := output.At(2*3+2, 0), math.Sin(2/100.0); math.Abs(ans-want) > 1e-12 {
+		t.Errorf("Expected: %v, Got: %v", want, ans)
+	}
+	if ans, want := output.At(1*3+1, 0), math.Cos(1); math.Abs(ans-want) > 1e-12 {
+		t.Errorf("Expected: %v, Got: %v", want, ans)
+	}
+}
+
+func TestLearnedPositionalEncodingGradients(t *testing.T) {
+	random := rand.New(rand.NewSource(4))
+	encoding, _ := NewLearnedPositionalEncoding(Signal(2, 3), WithRand(random))
+	checkGradients(t, encoding, *normalMatrix(6, 2, random))
+}
+
+func TestTransformerEncoderBlockGradients(t *testing.T) {
+	random := rand.New(rand.NewSource(5))
+	input := normalMatrix(4*3, 2, random)
+	for _, opts := range [][]Option{nil, {WithCausalMask()}} {
+		block, err := NewTransformerEncoderBlock(Signal(4, 3), 2, 5, append(opts, WithRand(random), WithBiasInit(LeCunNormal))...)
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		checkGradients(t, block, *input)
+	}
+}
+
+func TestTransformerEncoderBlockPadding(t *testing.T) {
+	random := rand.New(rand.NewSource(6))
+	block, _ := NewTransformerEncoderBlock(Signal(2, 3), 1, 4, WithPaddingMask(), WithRand(random))
+	input := normalMatrix(6, 2, random)
+	input.Set(0, 1, 0)
+	input.Set(3, 1, 0)
+
+	output := block.forward(*input)
+	if output.At(0, 1) != 0 || output.At(3, 1) != 0 {
+		t.Errorf("Expected: %v, Got: %v", 0, mat.Col(nil, 1, &output))
+	}
+	if output.At(0, 0) == 0 {
+		t.Errorf("Expected the first sample without padding, Got: %v", mat.Col(nil, 0, &output))
+	}
+}
+
+func TestTransformerEncoderBlockRegularizer(t *testing.T) {
+	random := rand.New(rand.NewSource(10))
+	set := Set{Data: *normalMatrix(2*3, 4, random), Labels: *normalMatrix(2*3, 4, random)}
+	newNetwork := func(opts ...Option) (*Network, *TransformerEncoderBlock) {
+		block, err := NewTransformerEncoderBlock(Signal(2, 3), 2, 4, append(opts, WithRand(rand.New(rand.NewSource(11))))...)
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		network, _ := NewSequential(MseLoss, block)
+		return network, block
+	}
+
+	// without updates the reported losses differ exactly by the penalty of all projections
+	plain, _ := newNetwork()
+	regularized, block := newNetwork(WithRegularizer(L2(0.1)))
+	plainHistory, _ := plain.Train(&set, 1, 4, ConstantRate(0), WithWriter(nil))
+	history, _ := regularized.Train(&set, 1, 4, ConstantRate(0), WithWriter(nil))
+
+	penalty := 0.0
+	for _, weights := range [][]float64{block.hidden.weights.RawMatrix().Data, block.output.weights.RawMatrix().Data} {
+		penalty += 0.1 * floats.Dot(weights, weights)
+	}
+	for i := range block.attention.weights {
+		weights := block.attention.weights[i].RawMatrix().Data
+		penalty += 0.1 * floats.Dot(weights, weights)
+	}
+	if ans := history.Loss()[0] - plainHistory.Loss()[0]; penalty == 0 || math.Abs(ans-penalty) > 1e-9 {
+		t.Errorf("Expected: %v, Got: %v", penalty, ans)
+	}
+
+	constrained, block := newNetwork(WithConstraint(MaxNorm(0.1)))
+	if _, err := constrained.Train(&set, 2, 2, ConstantRate(0.1), WithWriter(nil)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	weights := []*mat.Dense{&block.hidden.weights, &block.output.weights}
+	for i := range block.attention.weights {
+		weights = append(weights, &block.attention.weights[i])
+	}
+	for _, w := range weights {
+		rows, _ := w.Dims()
+		for i := 0; i < rows; i++ {
+			if norm := floats.Norm(w.RawRowView(i), 2); norm > 0.1+1e-12 {
+				t.Errorf("Expected norm at most %v, Got: %v", 0.1, norm)
+			}
+		}
+	}
+
+	// the regularizer and the constraint of the attention are saved
+	var buffer bytes.Buffer
+	if err := constrained.Save(&buffer); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	loaded, err := Load(&buffer)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if diff := cmp.Diff(constrained.layers[0].state(), loaded.layers[0].state()); diff != "" {
+		t.Errorf("State mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := NewMultiHeadAttention(Signal(2, 3), 2, WithRegularizer(L2(-1))); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+// a network that classifies sequences of tokens
+func transformerNetwork(random *rand.Rand) *Network {
+	embedding, _ := NewEmbedding(6, 4, 5, WithPaddingMask(), WithRand(random))
+	encoding, _ := NewPositionalEncoding(embedding.OutputShape(), WithPaddingMask())
+	block, _ := NewTransformerEncoderBlock(encoding.OutputShape(), 2, 8, WithPaddingMask(), WithRand(random))
+	pooling, _ := NewGlobalAveragePooling(block.OutputShape())
+	dense, _ := NewDense(4, 2, WithRand(random))
+	softmax, _ := NewSoftmax(2)
+	network, _ := NewSequential(CategoricalCrossEntropyLoss, embedding, encoding, block, pooling, dense, softmax)
+	return network
+}
+
+// sequences of the tokens 1 to 5 with padding 0 at the beginning, the label is whether token 5 appears
+func transformerSet(random *rand.Rand) *Set {
+	data := mat.NewDense(5, 64, nil)
+	labels := mat.NewDense(2, 64, nil)
+	for n := 0; n < 64; n++ {
+		length := 2 + random.Intn(4)
+		found := 0
+		for t := 5 - length; t < 5; t++ {
+			token := 1 + random.Intn(5)
+			data.Set(t, n, float64(token))
+			if token == 5 {
+				found = 1
+			}
+		}
+		labels.Set(found, n, 1)
+	}
+	return &Set{Data: *data, Labels: *labels}
+}
+
+func TestTransformerTrain(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+	set := transformerSet(random)
+	network := transformerNetwork(random)
+	adam, _ := NewAdam(0.9, 0.999)
+	network.SetOptimizer(adam)
+
+	history, err := network.Train(set, 40, 16, ConstantRate(0.01), WithSeed(8), WithWorkers(2), WithWriter(nil))
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	loss := history.Loss()
+	if loss[len(loss)-1] > loss[0]/2 {
+		t.Errorf("Expected the loss to decrease from %v, Got: %v", loss[0], loss[len(loss)-1])
+	}
+	// the padding vector is not trained
+	if diff := cmp.Diff([]float64{0, 0, 0, 0}, network.layers[0].(*Embedding).Vector(0)); diff != "" {
+		t.Errorf("Vector mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTransformerSaveLoad(t *testing.T) {
+	random := rand.New(rand.NewSource(9))
+	network := transformerNetwork(random)
+	learned, _ := NewLearnedPositionalEncoding(Signal(2, 3), WithPaddingMask(), WithRand(random))
+	attention, _ := NewMultiHeadAttention(Signal(2, 3), 2, WithCausalMask(), WithRand(random))
+	other, _ := NewSequential(MseLoss, learned, attention)
+
+	for _, test := range []struct {
+		network *Network
+		data    mat.Dense
+	}{
+		{network, transformerSet(random).Data},
+		{other, *normalMatrix(6, 3, random)},
+	} {
+		var buffer bytes.Buffer
+		if err := test.network.Save(&buffer); err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		loaded, err := Load(&buffer)
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		checkSamePredictions(t, test.network, loaded, test.data)
+		for i, layer := range test.network.layers {
+			if diff := cmp.Diff(layer.state(), loaded.layers[i].state()); diff != "" {
+				t.Errorf("State mismatch (-want +got):\n%s", diff)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package nngo
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// a callback gets notified about the progress of Train, see WithCallbacks
+//
+// epochs and batches are counted from 0 like in Scheduler,
+// batch is the index of the batch inside the current epoch
+// all hooks are called on the goroutine that runs Train
+type Callback interface {
+	OnTrainBegin(network *Network)
+	OnTrainEnd(history *History)
+	OnEpochBegin(epoch int)
+	OnEpochEnd(result EpochResult)
+	OnBatchBegin(epoch, batch int)
+	OnBatchEnd(epoch, batch int, loss float64)
+}
+
+// implements all hooks of Callback without doing anything
+// embed it to implement only some of the hooks
+type BaseCallback struct{}
+
+func (BaseCallback) OnTrainBegin(network *Network)             {}
+func (BaseCallback) OnTrainEnd(history *History)               {}
+func (BaseCallback) OnEpochBegin(epoch int)                    {}
+func (BaseCallback) OnEpochEnd(result EpochResult)             {}
+func (BaseCallback) OnBatchBegin(epoch, batch int)             {}
+func (BaseCallback) OnBatchEnd(epoch, batch int, loss float64) {}
+
+// statistics of one finished epoch
+//
+// Loss is the average training loss over all batches of the epoch
+// ValidationLoss is only set, if Validation is true
+// Metrics maps the names of the metrics to their values, see WithMetrics
+type EpochResult struct {
+	Epoch          int
+	Loss           float64
+	Validation     bool
+	ValidationLoss float64
+	Metrics        map[string]float64
+	Duration       time.Duration
+}
+
+// results of all epochs that were trained by one call of Train
+//
+// when the training is resumed from a checkpoint, only the epochs after the checkpoint are included
+type History struct {
+	Epochs []EpochResult
+}
+
+// returns the training loss of each epoch
+func (history *History) Loss() []float64 {
+	values := make([]float64, len(history.Epochs))
+	for i, result := range history.Epochs {
+		values[i] = result.Loss
+	}
+	return values
+}
+
+// returns the validation loss of each epoch, if a validation set was used
+func (history *History) ValidationLoss() []float64 {
+	var values []float64
+	for _, result := range history.Epochs {
+		if result.Validation {
+			values = append(values, result.ValidationLoss)
+		}
+	}
+	return values
+}
+
+// returns the values of the metric with the given name for each epoch
+func (history *History) Metric(name string) []float64 {
+	var values []float64
+	for _, result := range history.Epochs {
+		if value, ok := result.Metrics[name]; ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// writes one line for each finished epoch, see WithWriter
+type progressWriter struct {
+	BaseCallback
+	w io.Writer
+}
+
+func (p progressWriter) OnEpochEnd(result EpochResult) {
+	line := fmt.Sprintf("Epoch = %v, Error = %v", result.Epoch+1, result.Loss)
+	if result.Validation {
+		line += fmt.Sprintf(", Validation Error = %v", result.ValidationLoss)
+	}
+
+	names := make([]string, 0, len(result.Metrics))
+	for name := range result.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		line += fmt.Sprintf(", %v = %v", name, result.Metrics[name])
+	}
+	fmt.Fprintf(p.w, "%v \n", line)
+}
@@ -0,0 +1,125 @@
+package nngo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gonum.org/v1/gonum/mat"
+)
+
+// records the calls of all hooks
+type recordingCallback struct {
+	calls   []string
+	results []EpochResult
+	history *History
+}
+
+func (c *recordingCallback) OnTrainBegin(network *Network) {
+	c.calls = append(c.calls, "train begin")
+}
+
+func (c *recordingCallback) OnTrainEnd(history *History) {
+	c.calls = append(c.calls, "train end")
+	c.history = history
+}
+
+func (c *recordingCallback) OnEpochBegin(epoch int) {
+	c.calls = append(c.calls, "epoch begin")
+}
+
+func (c *recordingCallback) OnEpochEnd(result EpochResult) {
+	c.calls = append(c.calls, "epoch end")
+	c.results = append(c.results, result)
+}
+
+func (c *recordingCallback) OnBatchBegin(epoch, batch int) {
+	c.calls = append(c.calls, "batch begin")
+}
+
+func (c *recordingCallback) OnBatchEnd(epoch, batch int, loss float64) {
+	c.calls = append(c.calls, "batch end")
+}
+
+// counts the finished batches
+type batchCounter struct {
+	BaseCallback
+	batches []int
+}
+
+func (c *batchCounter) OnBatchEnd(epoch, batch int, loss float64) {
+	c.batches = append(c.batches, batch)
+}
+
+func TestTrainCallbacks(t *testing.T) {
+	data := mat.NewDense(2, 3, []float64{0, 1, 1, 1, 0, 1})
+	set := Set{*data, *data}
+
+	network, err := NewNetwork([]LayerSpec{{2, 2, ActivationSigmoid}}, LossMse)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	recorder := &recordingCallback{}
+	counter := &batchCounter{}
+	var output bytes.Buffer
+	history, err := network.Train(&set, 2, 2, ConstantRate(0.1),
+		WithCallbacks(recorder, counter),
+		WithMetrics(MaeMetric),
+		WithWriter(&output),
+	)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	expectedCalls := []string{
+		"train begin",
+		"epoch begin", "batch begin", "batch end", "batch begin", "batch end", "epoch end",
+		"epoch begin", "batch begin", "batch end", "batch begin", "batch end", "epoch end",
+		"train end",
+	}
+	if diff := cmp.Diff(expectedCalls, recorder.calls); diff != "" {
+		t.Errorf("Calls mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{0, 1, 0, 1}, counter.batches); diff != "" {
+		t.Errorf("Batches mismatch (-want +got):\n%s", diff)
+	}
+
+	if recorder.history != history || len(history.Epochs) != 2 {
+		t.Fatalf("Expected the returned history with 2 epochs, Got: %v", recorder.history)
+	}
+	for i, result := range history.Epochs {
+		if result.Epoch != i || result.Validation {
+			t.Errorf("Expected epoch %v without validation, Got: %+v", i, result)
+		}
+		if _, ok := result.Metrics["mae"]; !ok {
+			t.Errorf("Expected mae metric, Got: %v", result.Metrics)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "Epoch = 2, Error = ") || !strings.Contains(lines[1], "mae = ") {
+		t.Errorf("Unexpected output: %q", output.String())
+	}
+}
+
+func TestHistory(t *testing.T) {
+	history := History{[]EpochResult{
+		{Epoch: 0, Loss: 3, Validation: true, ValidationLoss: 4, Metrics: map[string]float64{"accuracy": 0.5}},
+		{Epoch: 1, Loss: 2, Validation: true, ValidationLoss: 3, Metrics: map[string]float64{"accuracy": 0.75}},
+	}}
+
+	if diff := cmp.Diff([]float64{3, 2}, history.Loss()); diff != "" {
+		t.Errorf("Loss mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]float64{4, 3}, history.ValidationLoss()); diff != "" {
+		t.Errorf("Validation loss mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]float64{0.5, 0.75}, history.Metric("accuracy")); diff != "" {
+		t.Errorf("Metric mismatch (-want +got):\n%s", diff)
+	}
+	if ans := history.Metric("mae"); ans != nil {
+		t.Errorf("Expected: %v, Got: %v", nil, ans)
+	}
+}
@@ -0,0 +1,258 @@
+package nngo
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// checkpoints use the same layout as saved models, but with their own magic bytes
+var checkpointMagic = [4]byte{'n', 'n', 'c', 'k'}
+
+const checkpointVersion uint16 = 1
+
+// serialised form of a checkpoint
+//
+// Scheduler is only set for schedulers with state, e.g. ReduceOnPlateau
+// the other fields are the trainProgress of the training
+type checkpointState struct {
+	Model      modelState
+	Optimizer  optimizerSnapshot
+	Scheduler  []float64
+	Random     uint64
+	Epoch      int
+	Step       int
+	Best       float64
+	Wait       int
+	BestParams [][]float64
+}
+
+// training state that was written during Train, see WithCheckpoints and WithBestCheckpoint
+//
+// Network is restored with its weights, its optimizer and its random state
+// to continue the training, call Train of Network with WithResume
+type Checkpoint struct {
+	Network *Network
+	// number of finished epochs
+	Epoch int
+
+	scheduler []float64
+	progress  trainProgress
+}
+
+// creates an optimizer with its state from its serialised form
+var optimizerLoaders = map[string]func(snapshot optimizerSnapshot) (Optimizer, error){
+	"sgd":     loadSGD,
+	"adagrad": loadAdagrad,
+	"rmsprop": loadRMSprop,
+	"adam":    loadAdam,
+	"adamw":   loadAdamW,
+}
+
+// reads a checkpoint that was written during Train
+//
+// like for Load, custom losses and activation functions need to be registered first
+func LoadCheckpoint(r io.Reader) (*Checkpoint, error) {
+	var state checkpointState
+	if err := readGob(r, checkpointMagic, checkpointVersion, "checkpoint", &state); err != nil {
+		return nil, err
+	}
+
+	network, err := networkFromState(state.Model)
+	if err != nil {
+		return nil, err
+	}
+	loader, ok := optimizerLoaders[state.Optimizer.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown optimizer type %q", state.Optimizer.Type)
+	}
+	network.optimizer, err = loader(state.Optimizer)
+	if err != nil {
+		return nil, err
+	}
+	network.random.state = state.Random
+
+	return &Checkpoint{
+		Network:   network,
+		Epoch:     state.Epoch,
+		scheduler: state.Scheduler,
+		progress: trainProgress{
+			epoch:      state.Epoch,
+			step:       state.Step,
+			best:       state.Best,
+			wait:       state.Wait,
+			bestParams: state.BestParams,
+		},
+	}, nil
+}
+
+// returns the path of the checkpoint with the highest epoch in dir,
+// that was written with WithCheckpoints
+//
+// the returned error wraps fs.ErrNotExist, if dir contains no checkpoint
+func LatestCheckpoint(dir string) (string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "checkpoint-*.nngo"))
+	if err != nil {
+		return "", err
+	}
+
+	latest := ""
+	latestEpoch := -1
+	for _, path := range paths {
+		var epoch int
+		if _, err := fmt.Sscanf(filepath.Base(path), "checkpoint-%d.nngo", &epoch); err != nil {
+			continue
+		}
+		if epoch > latestEpoch {
+			latest = path
+			latestEpoch = epoch
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no checkpoint in %v: %w", dir, fs.ErrNotExist)
+	}
+	return latest, nil
+}
+
+// name of the periodic checkpoint after the given number of epochs
+func checkpointName(epoch int) string {
+	return fmt.Sprintf("checkpoint-%06d.nngo", epoch)
+}
+
+// collects the serialised training state
+func (dense *Network) checkpointState(scheduler Scheduler, progress trainProgress) (checkpointState, error) {
+	model, err := dense.state()
+	if err != nil {
+		return checkpointState{}, err
+	}
+	optimizer, ok := dense.optimizer.(snapshotOptimizer)
+	if !ok {
+		return checkpointState{}, fmt.Errorf("optimizer %T doesn't support checkpoints", dense.optimizer)
+	}
+
+	state := checkpointState{
+		Model:      model,
+		Optimizer:  optimizer.snapshot(),
+		Random:     dense.random.state,
+		Epoch:      progress.epoch,
+		Step:       progress.step,
+		Best:       progress.best,
+		Wait:       progress.wait,
+		BestParams: progress.bestParams,
+	}
+	if s, ok := scheduler.(snapshotScheduler); ok {
+		state.Scheduler = s.snapshot()
+	}
+	return state, nil
+}
+
+// writes the checkpoint to a temporary file next to path and renames it afterwards,
+// so an interrupted write never replaces a complete checkpoint with a broken one
+func writeCheckpoint(path string, state checkpointState) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(dir, ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if err := writeGob(file, checkpointMagic, checkpointVersion, state); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// restores the scheduler state of the checkpoint before the training continues
+func (dense *Network) resume(checkpoint *Checkpoint, scheduler Scheduler) error {
+	if checkpoint.Network != dense {
+		return fmt.Errorf("checkpoint belongs to a different network, use the network of the checkpoint")
+	}
+	if checkpoint.scheduler == nil {
+		return nil
+	}
+
+	s, ok := scheduler.(snapshotScheduler)
+	if !ok {
+		return fmt.Errorf("scheduler %T can't restore the state of the checkpoint", scheduler)
+	}
+	return s.restore(checkpoint.scheduler)
+}
+
+// checks that the snapshot has the expected number of hyperparameters and state slots
+func checkSnapshot(snapshot optimizerSnapshot, config, slots int) error {
+	if len(snapshot.Config) != config || len(snapshot.Slots) != slots {
+		return fmt.Errorf("%v optimizer needs %v config values and %v slots, got %v and %v",
+			snapshot.Type, config, slots, len(snapshot.Config), len(snapshot.Slots))
+	}
+	return nil
+}
+
+func loadSGD(snapshot optimizerSnapshot) (Optimizer, error) {
+	if err := checkSnapshot(snapshot, 2, 1); err != nil {
+		return nil, err
+	}
+	opt, err := NewMomentum(snapshot.Config[0])
+	if err != nil {
+		return nil, err
+	}
+	opt.nesterov = snapshot.Config[1] != 0
+	opt.velocity = snapshot.Slots[0]
+	return opt, nil
+}
+
+func loadAdagrad(snapshot optimizerSnapshot) (Optimizer, error) {
+	if err := checkSnapshot(snapshot, 0, 1); err != nil {
+		return nil, err
+	}
+	opt := NewAdagrad()
+	opt.sum = snapshot.Slots[0]
+	return opt, nil
+}
+
+func loadRMSprop(snapshot optimizerSnapshot) (Optimizer, error) {
+	if err := checkSnapshot(snapshot, 1, 1); err != nil {
+		return nil, err
+	}
+	opt, err := NewRMSprop(snapshot.Config[0])
+	if err != nil {
+		return nil, err
+	}
+	opt.average = snapshot.Slots[0]
+	return opt, nil
+}
+
+func loadAdam(snapshot optimizerSnapshot) (Optimizer, error) {
+	if err := checkSnapshot(snapshot, 2, 2); err != nil {
+		return nil, err
+	}
+	opt, err := NewAdam(snapshot.Config[0], snapshot.Config[1])
+	if err != nil {
+		return nil, err
+	}
+	opt.step = snapshot.Step
+	opt.m, opt.v = snapshot.Slots[0], snapshot.Slots[1]
+	return opt, nil
+}
+
+func loadAdamW(snapshot optimizerSnapshot) (Optimizer, error) {
+	if err := checkSnapshot(snapshot, 3, 2); err != nil {
+		return nil, err
+	}
+	opt, err := NewAdamW(snapshot.Config[0], snapshot.Config[1], snapshot.Config[2])
+	if err != nil {
+		return nil, err
+	}
+	opt.step = snapshot.Step
+	opt.m, opt.v = snapshot.Slots[0], snapshot.Slots[1]
+	return opt, nil
+}
@@ -0,0 +1,204 @@
+package nngo
+
+import (
+	"errors"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gonum.org/v1/gonum/mat"
+)
+
+// opens and loads the checkpoint at path
+func loadCheckpointFile(t *testing.T, path string) *Checkpoint {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	defer file.Close()
+
+	checkpoint, err := LoadCheckpoint(file)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	return checkpoint
+}
+
+func TestCheckpointResume(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	labels := mat.NewDense(2, 4, []float64{1, 0, 0, 0, 0, 1, 1, 1})
+	set := Set{*data, *labels}
+	dir := t.TempDir()
+
+	network, err := NewNetwork([]LayerSpec{{2, 4, ActivationPRelu}, {4, 2, ActivationSoftmax}}, LossCategoricalCrossEntropy)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	adam, _ := NewAdam(0.9, 0.999)
+	network.SetOptimizer(adam)
+	scheduler, _ := NewReduceOnPlateau(0.01, 0.5, 1, 0.0001)
+
+	if _, err := network.Train(&set, 6, 3, scheduler, WithCheckpoints(dir, 3), WithShuffle()); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	checkpoint := loadCheckpointFile(t, filepath.Join(dir, checkpointName(3)))
+	if checkpoint.Epoch != 3 || checkpoint.progress.step != 6 {
+		t.Errorf("Expected: epoch 3 and step 6, Got: epoch %v and step %v", checkpoint.Epoch, checkpoint.progress.step)
+	}
+
+	resumedScheduler, _ := NewReduceOnPlateau(0.01, 0.5, 1, 0.0001)
+	resumed := checkpoint.Network
+	if _, err := resumed.Train(&set, 6, 3, resumedScheduler, WithResume(checkpoint), WithShuffle()); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	checkSamePredictions(t, network, resumed, *data)
+	if diff := cmp.Diff(adam.snapshot(), resumed.optimizer.(*Adam).snapshot()); diff != "" {
+		t.Errorf("Optimizer state mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(scheduler.snapshot(), resumedScheduler.snapshot()); diff != "" {
+		t.Errorf("Scheduler state mismatch (-want +got):\n%s", diff)
+	}
+	if network.random.state != resumed.random.state {
+		t.Errorf("Expected: %v, Got: %v", network.random.state, resumed.random.state)
+	}
+}
+
+func TestCheckpointResumeWarmup(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	labels := mat.NewDense(2, 4, []float64{1, 0, 0, 0, 0, 1, 1, 1})
+	set := Set{*data, *labels}
+	dir := t.TempDir()
+
+	network, _ := NewNetwork([]LayerSpec{{2, 4, ActivationPRelu}, {4, 2, ActivationSoftmax}}, LossCategoricalCrossEntropy)
+	plateau, _ := NewReduceOnPlateau(0.01, 0.5, 1, 0.0001)
+	scheduler, _ := NewLinearWarmup(3, plateau)
+	if _, err := network.Train(&set, 6, 3, scheduler, WithCheckpoints(dir, 3)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	// the wrapped scheduler observed the losses
+	if math.IsInf(plateau.best, 1) {
+		t.Errorf("Expected the plateau scheduler to observe the loss, Got: %v", plateau.best)
+	}
+
+	checkpoint := loadCheckpointFile(t, filepath.Join(dir, checkpointName(3)))
+	if checkpoint.scheduler == nil {
+		t.Fatal("Expected the scheduler state in the checkpoint.")
+	}
+	resumedPlateau, _ := NewReduceOnPlateau(0.01, 0.5, 1, 0.0001)
+	resumedScheduler, _ := NewLinearWarmup(3, resumedPlateau)
+	if _, err := checkpoint.Network.Train(&set, 6, 3, resumedScheduler, WithResume(checkpoint)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if diff := cmp.Diff(plateau.snapshot(), resumedPlateau.snapshot()); diff != "" {
+		t.Errorf("Scheduler state mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBestCheckpoint(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	set := Set{*data, *data}
+	path := filepath.Join(t.TempDir(), "best.nngo")
+
+	network, err := NewNetwork([]LayerSpec{{2, 2, ActivationTanh}}, LossMse)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if _, err := network.Train(&set, 5, 4, ConstantRate(0.01), WithBestCheckpoint(path)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	checkpoint := loadCheckpointFile(t, path)
+	if checkpoint.Epoch < 1 || checkpoint.Epoch > 5 {
+		t.Errorf("Expected epoch between 1 and 5, Got: %v", checkpoint.Epoch)
+	}
+
+	loss, _, _ := checkpoint.Network.batchLoss(set.Labels, checkpoint.Network.forward(set.Data))
+	loss /= 4
+	if loss > checkpoint.progress.best+1e-9 {
+		t.Errorf("Expected loss of the best weights to be at most %v, Got: %v", checkpoint.progress.best, loss)
+	}
+}
+
+func TestLatestCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LatestCheckpoint(dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected: %v, Got: %v", fs.ErrNotExist, err)
+	}
+
+	for _, name := range []string{checkpointName(2), checkpointName(10), "checkpoint-last.nngo", checkpointName(4)} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+	}
+
+	ans, err := LatestCheckpoint(dir)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+	if expected := filepath.Join(dir, checkpointName(10)); ans != expected {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+}
+
+func TestOptimizerSnapshot(t *testing.T) {
+	momentum, _ := NewMomentum(0.9)
+	nesterov, _ := NewNesterov(0.5)
+	rmsprop, _ := NewRMSprop(0.9)
+	adam, _ := NewAdam(0.9, 0.999)
+	adamW, _ := NewAdamW(0.9, 0.99, 0.01)
+
+	for _, opt := range []snapshotOptimizer{NewSGD(), momentum, nesterov, NewAdagrad(), rmsprop, adam, adamW} {
+		opt.Update(testParams(), 0.1)
+		expected := opt.snapshot()
+
+		loaded, err := optimizerLoaders[expected.Type](expected)
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		if diff := cmp.Diff(expected, loaded.(snapshotOptimizer).snapshot()); diff != "" {
+			t.Errorf("%v mismatch (-want +got):\n%s", expected.Type, diff)
+		}
+	}
+
+	if _, err := loadAdam(optimizerSnapshot{Type: "adam", Config: []float64{0.9}}); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+// optimizer without snapshot support
+type customOptimizer struct{}
+
+func (customOptimizer) Update(params []Param, learningRate float64) {}
+
+func TestCheckpointError(t *testing.T) {
+	data := mat.NewDense(2, 2, []float64{0, 1, 1, 0})
+	set := Set{*data, *data}
+	dir := t.TempDir()
+
+	network, _ := NewNetwork([]LayerSpec{{2, 2, ActivationSigmoid}}, LossMse)
+	if _, err := network.Train(&set, 1, 2, ConstantRate(0.1), WithCheckpoints(dir, 0)); err == nil {
+		t.Error("Expected error.")
+	}
+
+	network.SetOptimizer(customOptimizer{})
+	if _, err := network.Train(&set, 1, 2, ConstantRate(0.1), WithCheckpoints(dir, 1)); err == nil {
+		t.Error("Expected error.")
+	}
+
+	network.SetOptimizer(NewSGD())
+	if _, err := network.Train(&set, 1, 2, ConstantRate(0.1), WithCheckpoints(dir, 1)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	checkpoint := loadCheckpointFile(t, filepath.Join(dir, checkpointName(1)))
+
+	other, _ := NewNetwork([]LayerSpec{{2, 2, ActivationSigmoid}}, LossMse)
+	if _, err := other.Train(&set, 2, 2, ConstantRate(0.1), WithResume(checkpoint)); err == nil {
+		t.Error("Expected error.")
+	}
+}
@@ -0,0 +1,393 @@
+package nngo
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// shape of the image of one sample
+//
+// the images are saved in the columns of the batch like all other samples,
+// the values are ordered by channel, then by row and then by column,
+// i.e. the value at (c, h, w) has the index (c * Height + h) * Width + w
+// this is the same order as in the usual image files, e.g. 28x28 MNIST images have the shape {1, 28, 28}
+type Shape struct {
+	Channels int
+	Height   int
+	Width    int
+}
+
+// returns the number of values of one sample
+func (s Shape) Size() int {
+	return s.Channels * s.Height * s.Width
+}
+
+func (s Shape) valid() bool {
+	return s.Channels > 0 && s.Height > 0 && s.Width > 0
+}
+
+// positions of a sliding window over the image, used by the convolution and the pooling layers
+//
+// the padding can be different on each side, e.g. for same padding with even kernels
+type convGeometry struct {
+	input          Shape
+	kernelHeight   int
+	kernelWidth    int
+	strideHeight   int
+	strideWidth    int
+	dilationHeight int
+	dilationWidth  int
+	padTop         int
+	padBottom      int
+	padLeft        int
+	padRight       int
+}
+
+// creates the geometry from the options, the stride is defaultStride, if it isn't set with WithStride
+func newGeometry(input Shape, kernelHeight, kernelWidth, defaultStride int, config options) (convGeometry, error) {
+	if config.causalPadding {
+		return convGeometry{}, fmt.Errorf("causal padding is only supported by 1D layers")
+	}
+	stride := config.stride
+	if stride == 0 {
+		stride = defaultStride
+	}
+	dilation := config.dilation
+	if dilation == 0 {
+		dilation = 1
+	}
+
+	g := convGeometry{
+		input:          input,
+		kernelHeight:   kernelHeight,
+		kernelWidth:    kernelWidth,
+		strideHeight:   stride,
+		strideWidth:    stride,
+		dilationHeight: dilation,
+		dilationWidth:  dilation,
+		padTop:         config.padding,
+		padBottom:      config.padding,
+		padLeft:        config.padding,
+		padRight:       config.padding,
+	}
+	if config.samePadding {
+		g.padTop, g.padBottom = samePadding(input.Height, kernelHeight, stride, dilation)
+		g.padLeft, g.padRight = samePadding(input.Width, kernelWidth, stride, dilation)
+	}
+	return g, g.validate()
+}
+
+// returns the padding before and after, so that the output has ceil(size / stride) positions
+func samePadding(size, kernel, stride, dilation int) (int, int) {
+	out := (size + stride - 1) / stride
+	total := (out-1)*stride + dilation*(kernel-1) + 1 - size
+	if total < 0 {
+		total = 0
+	}
+	return total / 2, total - total/2
+}
+
+// geometry from the sizes saved in the state of a layer
+func geometryFromState(sizes []int) convGeometry {
+	return convGeometry{
+		input:          Shape{sizes[0], sizes[1], sizes[2]},
+		kernelHeight:   sizes[3],
+		kernelWidth:    sizes[4],
+		strideHeight:   sizes[5],
+		strideWidth:    sizes[6],
+		dilationHeight: sizes[7],
+		dilationWidth:  sizes[8],
+		padTop:         sizes[9],
+		padBottom:      sizes[10],
+		padLeft:        sizes[11],
+		padRight:       sizes[12],
+	}
+}
+
+// number of sizes of geometryFromState
+const geometrySizes = 13
+
+func (g convGeometry) sizes() []int {
+	return []int{
+		g.input.Channels, g.input.Height, g.input.Width,
+		g.kernelHeight, g.kernelWidth,
+		g.strideHeight, g.strideWidth,
+		g.dilationHeight, g.dilationWidth,
+		g.padTop, g.padBottom, g.padLeft, g.padRight,
+	}
+}
+
+func (g convGeometry) validate() error {
+	if !g.input.valid() {
+		return fmt.Errorf("input shape %v must be positive", g.input)
+	}
+	if g.kernelHeight <= 0 || g.kernelWidth <= 0 {
+		return fmt.Errorf("kernel size must be greater than 0")
+	}
+	if g.strideHeight <= 0 || g.strideWidth <= 0 || g.dilationHeight <= 0 || g.dilationWidth <= 0 {
+		return fmt.Errorf("stride and dilation must be greater than 0")
+	}
+	if g.padTop < 0 || g.padBottom < 0 || g.padLeft < 0 || g.padRight < 0 {
+		return fmt.Errorf("padding must not be negative")
+	}
+	if g.dilationHeight*(g.kernelHeight-1)+1 > g.input.Height+g.padTop+g.padBottom ||
+		g.dilationWidth*(g.kernelWidth-1)+1 > g.input.Width+g.padLeft+g.padRight {
+		return fmt.Errorf("kernel doesn't fit into the padded input %v", g.input)
+	}
+	return nil
+}
+
+// returns the shape of the output with the given number of channels
+func (g convGeometry) output(channels int) Shape {
+	height := (g.input.Height+g.padTop+g.padBottom-g.dilationHeight*(g.kernelHeight-1)-1)/g.strideHeight + 1
+	width := (g.input.Width+g.padLeft+g.padRight-g.dilationWidth*(g.kernelWidth-1)-1)/g.strideWidth + 1
+	return Shape{channels, height, width}
+}
+
+// returns for each kernel position k and output position p the index h * Width + w
+// of the input in one channel at k * positions + p, or -1 if the position lies in the padding
+func (g convGeometry) indices() []int {
+	out := g.output(1)
+	positions := out.Height * out.Width
+	indices := make([]int, g.kernelHeight*g.kernelWidth*positions)
+	for ki := 0; ki < g.kernelHeight; ki++ {
+		for kj := 0; kj < g.kernelWidth; kj++ {
+			k := ki*g.kernelWidth + kj
+			for oh := 0; oh < out.Height; oh++ {
+				for ow := 0; ow < out.Width; ow++ {
+					h := oh*g.strideHeight - g.padTop + ki*g.dilationHeight
+					w := ow*g.strideWidth - g.padLeft + kj*g.dilationWidth
+					index := -1
+					if h >= 0 && h < g.input.Height && w >= 0 && w < g.input.Width {
+						index = h*g.input.Width + w
+					}
+					indices[k*positions+oh*out.Width+ow] = index
+				}
+			}
+		}
+	}
+	return indices
+}
+
+// 2D convolution of the image with a number of filters
+//
+// each filter covers all input channels and creates one output channel
+// the output has the shape {filters, outHeight, outWidth}, see OutputShape
+//
+// internally the image patches are copied into the columns of a matrix (im2col),
+// so the convolution of the whole batch is a single matrix multiplication
+type Conv2D struct {
+	geometry       convGeometry
+	indices        []int
+	kernel         mat.Dense
+	bias           mat.VecDense
+	kernelGradient mat.Dense
+	biasGradient   mat.VecDense
+	regularizer    Regularizer
+	constraint     Constraint
+	// patches of the last forward propagation
+	patches mat.Dense
+}
+
+// constructor for Conv2D layer
+//
+// the kernel has the size kernelHeight x kernelWidth
+// WithStride, WithPadding, WithSamePadding and WithDilation change the sliding window,
+// the default is stride 1, no padding and no dilation
+// the kernel is initialized like the weights of Dense, see WithWeightInit and WithBiasInit,
+// WithRegularizer and WithConstraint regularize the kernel
+func NewConv2D(input Shape, filters, kernelHeight, kernelWidth int, opts ...Option) (*Conv2D, error) {
+	config := newOptions(opts)
+	geometry, err := newGeometry(input, kernelHeight, kernelWidth, 1, config)
+	if err != nil {
+		return nil, err
+	}
+	return newConv2D(geometry, filters, config)
+}
+
+func newConv2D(geometry convGeometry, filters int, config options) (*Conv2D, error) {
+	if filters <= 0 {
+		return nil, fmt.Errorf("filters must be greater than 0")
+	}
+	if err := geometry.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.regularizer.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.constraint.validate(); err != nil {
+		return nil, err
+	}
+
+	area := geometry.kernelHeight * geometry.kernelWidth
+	fanIn := geometry.input.Channels * area
+	c := Conv2D{
+		geometry:       geometry,
+		indices:        geometry.indices(),
+		kernel:         *mat.NewDense(filters, fanIn, nil),
+		bias:           *mat.NewVecDense(filters, nil),
+		kernelGradient: *mat.NewDense(filters, fanIn, nil),
+		biasGradient:   *mat.NewVecDense(filters, nil),
+		regularizer:    config.regularizer,
+		constraint:     config.constraint,
+	}
+	config.weightInit(c.kernel.RawMatrix().Data, fanIn, filters*area, config.random)
+	config.biasInit(c.bias.RawVector().Data, fanIn, filters*area, config.random)
+	return &c, nil
+}
+
+// returns the shape of the output of one sample
+func (c *Conv2D) OutputShape() Shape {
+	filters, _ := c.kernel.Dims()
+	return c.geometry.output(filters)
+}
+
+func (c *Conv2D) forward(input mat.Dense) mat.Dense {
+	c.patches = im2col(input, c.geometry, c.indices)
+	return c.convolve(c.patches)
+}
+
+func (c *Conv2D) predict(input mat.Dense) mat.Dense {
+	return c.convolve(im2col(input, c.geometry, c.indices))
+}
+
+// multiplies the kernel with the patches and reorders the result into one column per sample
+func (c *Conv2D) convolve(patches mat.Dense) mat.Dense {
+	var product mat.Dense
+	product.Mul(&c.kernel, &patches)
+
+	filters, _ := c.kernel.Dims()
+	positions := len(c.indices) / (c.geometry.kernelHeight * c.geometry.kernelWidth)
+	_, cols := patches.Dims()
+	samples := cols / positions
+	ans := mat.NewDense(filters*positions, samples, nil)
+	for f := 0; f < filters; f++ {
+		row := product.RawRowView(f)
+		bias := c.bias.AtVec(f)
+		for n := 0; n < samples; n++ {
+			for p := 0; p < positions; p++ {
+				ans.Set(f*positions+p, n, row[n*positions+p]+bias)
+			}
+		}
+	}
+	return *ans
+}
+
+// backward propagation calculates the gradients of the kernel and the bias
+// and adds the gradients of the overlapping patches up for the input (col2im)
+func (c *Conv2D) backward(outputGradient mat.Dense) mat.Dense {
+	filters, _ := c.kernel.Dims()
+	positions := len(c.indices) / (c.geometry.kernelHeight * c.geometry.kernelWidth)
+	_, samples := outputGradient.Dims()
+
+	// same order as the product of convolve
+	gradient := mat.NewDense(filters, samples*positions, nil)
+	for f := 0; f < filters; f++ {
+		row := gradient.RawRowView(f)
+		for n := 0; n < samples; n++ {
+			for p := 0; p < positions; p++ {
+				row[n*positions+p] = outputGradient.At(f*positions+p, n)
+			}
+		}
+		c.biasGradient.SetVec(f, floats.Sum(row))
+	}
+	c.kernelGradient.Mul(gradient, c.patches.T())
+
+	var patchGradient mat.Dense
+	patchGradient.Mul(c.kernel.T(), gradient)
+	return col2im(patchGradient, c.geometry, c.indices, samples)
+}
+
+func (c *Conv2D) state() layerState {
+	filters, _ := c.kernel.Dims()
+	state := layerState{Type: "conv2d", Shape: append(c.geometry.sizes(), filters)}
+	if c.regularizer != (Regularizer{}) || c.constraint != (Constraint{}) {
+		state.Config = []float64{c.regularizer.L1, c.regularizer.L2, c.constraint.value}
+		state.Constraint = c.constraint.name
+	}
+	return state
+}
+
+func (c *Conv2D) replica() Layer {
+	rows, cols := c.kernel.Dims()
+	return &Conv2D{
+		geometry:       c.geometry,
+		indices:        c.indices,
+		kernel:         c.kernel,
+		bias:           c.bias,
+		kernelGradient: *mat.NewDense(rows, cols, nil),
+		biasGradient:   *mat.NewVecDense(rows, nil),
+		regularizer:    c.regularizer,
+		constraint:     c.constraint,
+	}
+}
+
+// returns the kernel and the bias together with their gradients
+func (c *Conv2D) params() []Param {
+	return []Param{
+		{Value: c.kernel.RawMatrix().Data, Gradient: c.kernelGradient.RawMatrix().Data},
+		{Value: c.bias.RawVector().Data, Gradient: c.biasGradient.RawVector().Data},
+	}
+}
+
+func (c *Conv2D) penalty() float64 {
+	return c.regularizer.penalty(c.kernel.RawMatrix().Data)
+}
+
+func (c *Conv2D) addPenaltyGradient() {
+	c.regularizer.addGradient(c.kernel.RawMatrix().Data, c.kernelGradient.RawMatrix().Data)
+}
+
+// the norms are calculated for each filter
+func (c *Conv2D) constrain() {
+	c.constraint.apply(&c.kernel)
+}
+
+// copies the patches of all samples into the columns of a matrix
+//
+// the row c * kernelArea + k holds channel c at the kernel position k,
+// the column n * positions + p the output position p of sample n
+func im2col(input mat.Dense, g convGeometry, indices []int) mat.Dense {
+	_, samples := input.Dims()
+	area := g.kernelHeight * g.kernelWidth
+	positions := len(indices) / area
+	channelSize := g.input.Height * g.input.Width
+	patches := mat.NewDense(g.input.Channels*area, samples*positions, nil)
+	for ch := 0; ch < g.input.Channels; ch++ {
+		for k := 0; k < area; k++ {
+			row := patches.RawRowView(ch*area + k)
+			for n := 0; n < samples; n++ {
+				for p := 0; p < positions; p++ {
+					if index := indices[k*positions+p]; index >= 0 {
+						row[n*positions+p] = input.At(ch*channelSize+index, n)
+					}
+				}
+			}
+		}
+	}
+	return *patches
+}
+
+// adds the values of the patches back to their position in the input, the reverse of im2col
+func col2im(patches mat.Dense, g convGeometry, indices []int, samples int) mat.Dense {
+	area := g.kernelHeight * g.kernelWidth
+	positions := len(indices) / area
+	channelSize := g.input.Height * g.input.Width
+	ans := mat.NewDense(g.input.Size(), samples, nil)
+	for ch := 0; ch < g.input.Channels; ch++ {
+		for k := 0; k < area; k++ {
+			row := patches.RawRowView(ch*area + k)
+			for n := 0; n < samples; n++ {
+				for p := 0; p < positions; p++ {
+					if index := indices[k*positions+p]; index >= 0 {
+						i := ch*channelSize + index
+						ans.Set(i, n, ans.At(i, n)+row[n*positions+p])
+					}
+				}
+			}
+		}
+	}
+	return *ans
+}
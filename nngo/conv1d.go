@@ -0,0 +1,203 @@
+package nngo
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// returns the shape of a signal with the given number of channels and positions
+//
+// signals are images with a height of 1,
+// so the values are ordered by channel and then by position like in Shape
+func Signal(channels, length int) Shape {
+	return Shape{channels, 1, length}
+}
+
+// creates the geometry of a 1D layer from the options, see newGeometry
+func newGeometry1D(input Shape, kernel, defaultStride int, config options) (convGeometry, error) {
+	if input.Height != 1 {
+		return convGeometry{}, fmt.Errorf("1D layers need an input with height 1, got %v", input)
+	}
+	if config.causalPadding && (config.samePadding || config.padding != 0) {
+		return convGeometry{}, fmt.Errorf("causal padding can't be combined with other padding")
+	}
+	causal := config.causalPadding
+	config.causalPadding = false
+
+	geometry, err := newGeometry(input, 1, kernel, defaultStride, config)
+	if err != nil {
+		return convGeometry{}, err
+	}
+	geometry.strideHeight = 1
+	geometry.dilationHeight = 1
+	geometry.padTop, geometry.padBottom = 0, 0
+	if causal {
+		geometry.padLeft, geometry.padRight = geometry.dilationWidth*(kernel-1), 0
+	}
+	return geometry, geometry.validate()
+}
+
+// 1D convolution of a signal with a number of filters, e.g. for time series
+//
+// each filter covers all input channels and creates one output channel
+// the output has the shape Signal(filters, outLength), see OutputShape
+// it works like Conv2D on signals with a height of 1
+type Conv1D struct {
+	conv *Conv2D
+}
+
+// constructor for Conv1D layer
+//
+// input needs a height of 1, see Signal
+// WithStride, WithPadding, WithSamePadding, WithCausalPadding and WithDilation change the sliding window,
+// the default is stride 1, no padding and no dilation
+// the kernel is initialized like the weights of Dense, see WithWeightInit and WithBiasInit,
+// WithRegularizer and WithConstraint regularize the kernel
+func NewConv1D(input Shape, filters, kernelSize int, opts ...Option) (*Conv1D, error) {
+	config := newOptions(opts)
+	geometry, err := newGeometry1D(input, kernelSize, 1, config)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := newConv2D(geometry, filters, config)
+	if err != nil {
+		return nil, err
+	}
+	return &Conv1D{conv}, nil
+}
+
+// returns the shape of the output of one sample
+func (c *Conv1D) OutputShape() Shape {
+	return c.conv.OutputShape()
+}
+
+func (c *Conv1D) forward(input mat.Dense) mat.Dense {
+	return c.conv.forward(input)
+}
+
+func (c *Conv1D) predict(input mat.Dense) mat.Dense {
+	return c.conv.predict(input)
+}
+
+func (c *Conv1D) backward(outputGradient mat.Dense) mat.Dense {
+	return c.conv.backward(outputGradient)
+}
+
+func (c *Conv1D) state() layerState {
+	state := c.conv.state()
+	state.Type = "conv1d"
+	return state
+}
+
+func (c *Conv1D) replica() Layer {
+	return &Conv1D{c.conv.replica().(*Conv2D)}
+}
+
+func (c *Conv1D) params() []Param {
+	return c.conv.params()
+}
+
+func (c *Conv1D) penalty() float64 {
+	return c.conv.penalty()
+}
+
+func (c *Conv1D) addPenaltyGradient() {
+	c.conv.addPenaltyGradient()
+}
+
+func (c *Conv1D) constrain() {
+	c.conv.constrain()
+}
+
+// takes the maximum of each window in each channel of a signal
+// it works like MaxPool2D on signals with a height of 1
+type MaxPool1D struct {
+	pool *MaxPool2D
+}
+
+// constructor for MaxPool1D layer
+//
+// input needs a height of 1, see Signal
+// the stride is the size of the window unless set with WithStride
+// WithPadding, WithSamePadding, WithCausalPadding and WithDilation work like for Conv1D
+func NewMaxPool1D(input Shape, size int, opts ...Option) (*MaxPool1D, error) {
+	geometry, err := newGeometry1D(input, size, size, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return &MaxPool1D{&MaxPool2D{geometry: geometry, indices: geometry.indices()}}, nil
+}
+
+// returns the shape of the output of one sample
+func (m *MaxPool1D) OutputShape() Shape {
+	return m.pool.OutputShape()
+}
+
+func (m *MaxPool1D) forward(input mat.Dense) mat.Dense {
+	return m.pool.forward(input)
+}
+
+func (m *MaxPool1D) predict(input mat.Dense) mat.Dense {
+	return m.pool.predict(input)
+}
+
+func (m *MaxPool1D) backward(outputGradient mat.Dense) mat.Dense {
+	return m.pool.backward(outputGradient)
+}
+
+func (m *MaxPool1D) state() layerState {
+	state := m.pool.state()
+	state.Type = "max_pool1d"
+	return state
+}
+
+func (m *MaxPool1D) replica() Layer {
+	return &MaxPool1D{m.pool.replica().(*MaxPool2D)}
+}
+
+// takes the average of each window in each channel of a signal
+// it works like AvgPool2D on signals with a height of 1
+type AvgPool1D struct {
+	pool *AvgPool2D
+}
+
+// constructor for AvgPool1D layer
+//
+// input needs a height of 1, see Signal
+// the stride is the size of the window unless set with WithStride
+// WithPadding, WithSamePadding, WithCausalPadding and WithDilation work like for Conv1D
+func NewAvgPool1D(input Shape, size int, opts ...Option) (*AvgPool1D, error) {
+	geometry, err := newGeometry1D(input, size, size, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return &AvgPool1D{&AvgPool2D{geometry: geometry, indices: geometry.indices()}}, nil
+}
+
+// returns the shape of the output of one sample
+func (a *AvgPool1D) OutputShape() Shape {
+	return a.pool.OutputShape()
+}
+
+func (a *AvgPool1D) forward(input mat.Dense) mat.Dense {
+	return a.pool.forward(input)
+}
+
+func (a *AvgPool1D) predict(input mat.Dense) mat.Dense {
+	return a.pool.predict(input)
+}
+
+func (a *AvgPool1D) backward(outputGradient mat.Dense) mat.Dense {
+	return a.pool.backward(outputGradient)
+}
+
+func (a *AvgPool1D) state() layerState {
+	state := a.pool.state()
+	state.Type = "avg_pool1d"
+	return state
+}
+
+func (a *AvgPool1D) replica() Layer {
+	return &AvgPool1D{a.pool.replica().(*AvgPool2D)}
+}
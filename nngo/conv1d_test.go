@@ -0,0 +1,131 @@
+package nngo
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestConv1DOutputShape(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected Shape
+	}{
+		{"Valid", nil, Signal(4, 8)},
+		{"Causal", []Option{WithCausalPadding()}, Signal(4, 10)},
+		{"CausalDilation", []Option{WithCausalPadding(), WithDilation(4)}, Signal(4, 10)},
+		{"Same", []Option{WithSamePadding(), WithStride(3)}, Signal(4, 4)},
+		{"Dilation", []Option{WithDilation(2)}, Signal(4, 6)},
+	}
+
+	for _, test := range tests {
+		conv, err := NewConv1D(Signal(2, 10), 4, 3, test.opts...)
+		if err != nil {
+			t.Fatalf("%v: Didn't expect error. Got: %v", test.name, err)
+		}
+		if ans := conv.OutputShape(); ans != test.expected {
+			t.Errorf("%v Expected: %v, Got: %v", test.name, test.expected, ans)
+		}
+	}
+}
+
+func TestNewConv1DError(t *testing.T) {
+	if _, err := NewConv1D(Shape{1, 2, 10}, 1, 3); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewConv1D(Signal(1, 10), 1, 3, WithCausalPadding(), WithSamePadding()); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewConv2D(Shape{1, 3, 3}, 1, 2, 2, WithCausalPadding()); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewMaxPool1D(Signal(1, 3), 4); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestConv1DCausal(t *testing.T) {
+	conv, _ := NewConv1D(Signal(1, 5), 1, 2, WithCausalPadding(), WithDilation(2))
+	copy(conv.conv.kernel.RawMatrix().Data, []float64{1, 10})
+
+	// output[t] = input[t-2] + 10 * input[t]
+	output := conv.forward(*countingBatch(5, 1))
+	expected := mat.NewDense(5, 1, []float64{10, 20, 1 + 30, 2 + 40, 3 + 50})
+	if !mat.Equal(expected, &output) {
+		t.Errorf("Expected: %v, Got: %v", expected.RawMatrix().Data, output.RawMatrix().Data)
+	}
+
+	// changing a later position doesn't change the earlier outputs
+	input := countingBatch(5, 1)
+	input.Set(4, 0, 100)
+	changed := conv.predict(*input)
+	for i := 0; i < 4; i++ {
+		if changed.At(i, 0) != output.At(i, 0) {
+			t.Errorf("Expected: %v, Got: %v", output.At(i, 0), changed.At(i, 0))
+		}
+	}
+}
+
+func TestPool1D(t *testing.T) {
+	maxPool, _ := NewMaxPool1D(Signal(2, 4), 2)
+	output := maxPool.forward(*countingBatch(8, 1))
+	if expected := mat.NewDense(4, 1, []float64{2, 4, 6, 8}); !mat.Equal(expected, &output) {
+		t.Errorf("Expected: %v, Got: %v", expected.RawMatrix().Data, output.RawMatrix().Data)
+	}
+
+	avgPool, _ := NewAvgPool1D(Signal(1, 4), 2, WithStride(1), WithCausalPadding())
+	output = avgPool.forward(*countingBatch(4, 1))
+	if expected := mat.NewDense(4, 1, []float64{1, 1.5, 2.5, 3.5}); !mat.Equal(expected, &output) {
+		t.Errorf("Expected: %v, Got: %v", expected.RawMatrix().Data, output.RawMatrix().Data)
+	}
+}
+
+func TestConv1DGradients(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	conv, _ := NewConv1D(Signal(2, 9), 3, 3, WithCausalPadding(), WithDilation(2), WithRand(random), WithBiasInit(StandardNormal))
+	maxPool, _ := NewMaxPool1D(Signal(2, 9), 3, WithStride(2))
+	avgPool, _ := NewAvgPool1D(Signal(2, 9), 2, WithSamePadding())
+
+	for _, layer := range []Layer{conv, maxPool, avgPool} {
+		checkGradients(t, layer, *normalMatrix(18, 2, random))
+	}
+}
+
+func TestTemporalConvNet(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	conv, _ := NewConv1D(Signal(1, 16), 4, 3, WithCausalPadding(), WithRand(random))
+	relu, _ := NewActivation(conv.OutputShape().Size(), ActivationRelu)
+	dilated, _ := NewConv1D(conv.OutputShape(), 4, 3, WithCausalPadding(), WithDilation(2), WithRand(random))
+	pool, _ := NewAvgPool1D(dilated.OutputShape(), 4)
+	dense, _ := NewDense(pool.OutputShape().Size(), 1, WithRand(random))
+	network, err := NewSequential(MseLoss, conv, relu, dilated, pool, dense)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	data := normalMatrix(16, 8, random)
+	labels := mat.NewDense(1, 8, nil)
+	set := Set{*data, *labels}
+	if _, err := network.Train(&set, 2, 4, ConstantRate(0.01), WithWriter(nil)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := network.Save(&buffer); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	loaded, err := Load(&buffer)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	checkSamePredictions(t, network, loaded, *data)
+	for i, layer := range network.layers {
+		if diff := cmp.Diff(layer.state(), loaded.layers[i].state()); diff != "" {
+			t.Errorf("Layer %v mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package nngo
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gonum.org/v1/gonum/mat"
+)
+
+// creates a batch with the values 1, 2, 3, ... in each sample, the samples are shifted by 10
+func countingBatch(size, samples int) *mat.Dense {
+	batch := mat.NewDense(size, samples, nil)
+	batch.Apply(func(i, j int, v float64) float64 { return float64(i+1) + 10*float64(j) }, batch)
+	return batch
+}
+
+func TestConvOutputShape(t *testing.T) {
+	input := Shape{2, 7, 6}
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected Shape
+	}{
+		{"Valid", nil, Shape{4, 5, 4}},
+		{"Padding", []Option{WithPadding(1)}, Shape{4, 7, 6}},
+		{"Stride", []Option{WithStride(2)}, Shape{4, 3, 2}},
+		{"Dilation", []Option{WithDilation(2)}, Shape{4, 3, 2}},
+		{"Same", []Option{WithSamePadding()}, Shape{4, 7, 6}},
+		{"SameStride", []Option{WithSamePadding(), WithStride(2)}, Shape{4, 4, 3}},
+	}
+
+	for _, test := range tests {
+		conv, err := NewConv2D(input, 4, 3, 3, test.opts...)
+		if err != nil {
+			t.Fatalf("%v: Didn't expect error. Got: %v", test.name, err)
+		}
+		if ans := conv.OutputShape(); ans != test.expected {
+			t.Errorf("%v Expected: %v, Got: %v", test.name, test.expected, ans)
+		}
+	}
+}
+
+func TestNewConv2DError(t *testing.T) {
+	if _, err := NewConv2D(Shape{1, 0, 3}, 1, 1, 1); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewConv2D(Shape{1, 3, 3}, 0, 1, 1); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewConv2D(Shape{1, 3, 3}, 1, 4, 1); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewConv2D(Shape{1, 3, 3}, 1, 2, 2, WithStride(-1)); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewConv2D(Shape{1, 3, 3}, 1, 2, 2, WithPadding(-1)); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestConv2DForward(t *testing.T) {
+	conv, _ := NewConv2D(Shape{1, 3, 3}, 1, 2, 2, WithPadding(1), WithStride(2))
+	copy(conv.kernel.RawMatrix().Data, []float64{1, 2, 3, 4})
+	conv.bias.SetVec(0, 0.5)
+
+	// padded input:
+	// 0 0 0 0 0
+	// 0 1 2 3 0
+	// 0 4 5 6 0
+	// 0 7 8 9 0
+	// 0 0 0 0 0
+	input := countingBatch(9, 1)
+	output := conv.forward(*input)
+	expected := mat.NewDense(4, 1, []float64{4*1 + 0.5, 3*2 + 4*3 + 0.5, 2*4 + 4*7 + 0.5, 1*5 + 2*6 + 3*8 + 4*9 + 0.5})
+	if !mat.Equal(expected, &output) {
+		t.Errorf("Expected: %v, Got: %v", mat.Formatted(expected), mat.Formatted(&output))
+	}
+
+	predicted := conv.predict(*input)
+	if !mat.Equal(&output, &predicted) {
+		t.Errorf("Expected: %v, Got: %v", output, predicted)
+	}
+}
+
+func TestConv2DGradients(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"Valid", nil},
+		{"Padding", []Option{WithPadding(1)}},
+		{"Stride", []Option{WithStride(2), WithPadding(1)}},
+		{"Dilation", []Option{WithDilation(2)}},
+		{"Same", []Option{WithSamePadding()}},
+	}
+
+	for _, test := range tests {
+		opts := append(test.opts, WithRand(random), WithBiasInit(StandardNormal))
+		conv, err := NewConv2D(Shape{2, 5, 4}, 3, 3, 2, opts...)
+		if err != nil {
+			t.Fatalf("%v: Didn't expect error. Got: %v", test.name, err)
+		}
+		checkGradients(t, conv, *normalMatrix(40, 2, random))
+	}
+}
+
+func TestConv2DSaveLoad(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	conv, _ := NewConv2D(Shape{1, 6, 6}, 2, 3, 3, WithSamePadding(), WithStride(2), WithRegularizer(L2(0.01)), WithRand(random))
+	pool, _ := NewMaxPool2D(conv.OutputShape(), 2, WithSamePadding())
+	avg, _ := NewAvgPool2D(pool.OutputShape(), 2, WithStride(1), WithPadding(1))
+	global, _ := NewGlobalAveragePooling(avg.OutputShape())
+	flatten, _ := NewFlatten(global.OutputShape())
+	dense, _ := NewDense(flatten.OutputShape().Size(), 2, WithRand(random))
+	network, err := NewSequential(MseLoss, conv, pool, avg, global, flatten, dense)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := network.Save(&buffer); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	loaded, err := Load(&buffer)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	checkSamePredictions(t, network, loaded, *normalMatrix(36, 3, random))
+	for i, layer := range network.layers {
+		if diff := cmp.Diff(layer.state(), loaded.layers[i].state()); diff != "" {
+			t.Errorf("Layer %v mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+}
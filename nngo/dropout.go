@@ -0,0 +1,222 @@
+package nngo
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// layers that draw random numbers in training mode, e.g. Dropout
+//
+// the training seeds them from the random source of the network before each batch,
+// so the random numbers can be reproduced with WithSeed and don't depend on the scheduling of the workers
+type randomLayer interface {
+	seed(seed int64)
+}
+
+// random numbers of a layer
+type layerRandom struct {
+	source *randSource
+	random *rand.Rand
+}
+
+// the first seed is taken from the source of WithRand
+func newLayerRandom(opts []Option) layerRandom {
+	return newLayerRandomSeed(newOptions(opts).random.Int63())
+}
+
+func newLayerRandomSeed(seed int64) layerRandom {
+	source := newRandSource(seed)
+	return layerRandom{source, rand.New(source)}
+}
+
+func (r layerRandom) seed(seed int64) {
+	r.source.Seed(seed)
+}
+
+// sets a fraction of the inputs to zero during training
+//
+// the remaining inputs are scaled by 1 / (1 - rate), so the expected sum stays the same
+// in inference mode the layer passes the input through unchanged
+// paper: https://jmlr.org/papers/v15/srivastava14a.html
+type Dropout struct {
+	layerRandom
+	size int
+	rate float64
+	mask mat.Dense
+}
+
+// constructor for Dropout layer
+//
+// rate is the fraction of the inputs that are dropped and should be in [0, 1)
+// the random numbers come from the source of WithRand, until the layer is trained by a network
+func NewDropout(size int, rate float64, opts ...Option) (*Dropout, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("size must be greater than 0")
+	}
+	if rate < 0 || rate >= 1 {
+		return nil, fmt.Errorf("rate should be a value in [0, 1)")
+	}
+	return &Dropout{layerRandom: newLayerRandom(opts), size: size, rate: rate}, nil
+}
+
+// multiplies the input with a new random mask
+func (d *Dropout) forward(input mat.Dense) mat.Dense {
+	rows, cols := input.Dims()
+	d.mask = *mat.NewDense(rows, cols, nil)
+	scale := 1 / (1 - d.rate)
+	mask := d.mask.RawMatrix().Data
+	for i := range mask {
+		if d.random.Float64() >= d.rate {
+			mask[i] = scale
+		}
+	}
+
+	var ans mat.Dense
+	ans.MulElem(&input, &d.mask)
+	return ans
+}
+
+func (d *Dropout) predict(input mat.Dense) mat.Dense {
+	return input
+}
+
+// only the inputs that were kept get a gradient
+func (d *Dropout) backward(outputGradient mat.Dense) mat.Dense {
+	var ans mat.Dense
+	ans.MulElem(&outputGradient, &d.mask)
+	return ans
+}
+
+func (d *Dropout) state() layerState {
+	return layerState{Type: "dropout", Shape: []int{d.size}, Config: []float64{d.rate}}
+}
+
+func (d *Dropout) replica() Layer {
+	return &Dropout{layerRandom: newLayerRandomSeed(0), size: d.size, rate: d.rate}
+}
+
+// dropout for self normalizing networks with selu activations
+//
+// the dropped inputs are set to the negative saturation value of selu instead of zero,
+// afterwards an affine transformation restores the mean and the variance of the input
+// in inference mode the layer passes the input through unchanged
+// paper: https://arxiv.org/abs/1706.02515
+type AlphaDropout struct {
+	layerRandom
+	size int
+	rate float64
+	mask mat.Dense
+	// coefficients of the affine transformation
+	a, b float64
+}
+
+// constructor for AlphaDropout layer
+//
+// rate is the fraction of the inputs that are dropped and should be in [0, 1)
+// the random numbers come from the source of WithRand, until the layer is trained by a network
+func NewAlphaDropout(size int, rate float64, opts ...Option) (*AlphaDropout, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("size must be greater than 0")
+	}
+	if rate < 0 || rate >= 1 {
+		return nil, fmt.Errorf("rate should be a value in [0, 1)")
+	}
+
+	d := AlphaDropout{layerRandom: newLayerRandom(opts), size: size, rate: rate}
+	saturation := -seluScale * seluAlpha
+	d.a = 1 / math.Sqrt((1-rate)*(1+rate*saturation*saturation))
+	d.b = -d.a * saturation * rate
+	return &d, nil
+}
+
+// output = a * (input * mask + saturation * (1 - mask)) + b
+func (d *AlphaDropout) forward(input mat.Dense) mat.Dense {
+	rows, cols := input.Dims()
+	d.mask = *mat.NewDense(rows, cols, nil)
+	mask := d.mask.RawMatrix().Data
+	for i := range mask {
+		if d.random.Float64() >= d.rate {
+			mask[i] = 1
+		}
+	}
+
+	saturation := -seluScale * seluAlpha
+	var ans mat.Dense
+	ans.Apply(func(i, j int, v float64) float64 {
+		if d.mask.At(i, j) == 0 {
+			v = saturation
+		}
+		return d.a*v + d.b
+	}, &input)
+	return ans
+}
+
+func (d *AlphaDropout) predict(input mat.Dense) mat.Dense {
+	return input
+}
+
+func (d *AlphaDropout) backward(outputGradient mat.Dense) mat.Dense {
+	var ans mat.Dense
+	ans.Apply(func(i, j int, v float64) float64 {
+		return d.a * d.mask.At(i, j) * v
+	}, &outputGradient)
+	return ans
+}
+
+func (d *AlphaDropout) state() layerState {
+	return layerState{Type: "alpha_dropout", Shape: []int{d.size}, Config: []float64{d.rate}}
+}
+
+func (d *AlphaDropout) replica() Layer {
+	return &AlphaDropout{layerRandom: newLayerRandomSeed(0), size: d.size, rate: d.rate, a: d.a, b: d.b}
+}
+
+// adds normal distributed noise with mean 0 to the input during training
+// in inference mode the layer passes the input through unchanged
+type GaussianNoise struct {
+	layerRandom
+	size   int
+	stdDev float64
+}
+
+// constructor for GaussianNoise layer
+//
+// stdDev is the standard deviation of the noise and must not be negative
+// the random numbers come from the source of WithRand, until the layer is trained by a network
+func NewGaussianNoise(size int, stdDev float64, opts ...Option) (*GaussianNoise, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("size must be greater than 0")
+	}
+	if stdDev < 0 {
+		return nil, fmt.Errorf("stdDev must not be negative")
+	}
+	return &GaussianNoise{layerRandom: newLayerRandom(opts), size: size, stdDev: stdDev}, nil
+}
+
+func (g *GaussianNoise) forward(input mat.Dense) mat.Dense {
+	var ans mat.Dense
+	ans.Apply(func(i, j int, v float64) float64 {
+		return v + g.random.NormFloat64()*g.stdDev
+	}, &input)
+	return ans
+}
+
+func (g *GaussianNoise) predict(input mat.Dense) mat.Dense {
+	return input
+}
+
+// the noise doesn't depend on the input, so the gradient is passed through
+func (g *GaussianNoise) backward(outputGradient mat.Dense) mat.Dense {
+	return outputGradient
+}
+
+func (g *GaussianNoise) state() layerState {
+	return layerState{Type: "gaussian_noise", Shape: []int{g.size}, Config: []float64{g.stdDev}}
+}
+
+func (g *GaussianNoise) replica() Layer {
+	return &GaussianNoise{layerRandom: newLayerRandomSeed(0), size: g.size, stdDev: g.stdDev}
+}
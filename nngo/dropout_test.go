@@ -0,0 +1,166 @@
+package nngo
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// reseeds the layer before each forward propagation, so every call draws the same random numbers
+type fixedSeed struct {
+	Layer
+}
+
+func (f fixedSeed) forward(input mat.Dense) mat.Dense {
+	f.Layer.(randomLayer).seed(1)
+	return f.Layer.forward(input)
+}
+
+// creates a matrix with standard normal values
+func normalMatrix(rows, cols int, random *rand.Rand) *mat.Dense {
+	values := make([]float64, rows*cols)
+	normal(values, 1, random)
+	return mat.NewDense(rows, cols, values)
+}
+
+// returns the mean and the variance of all values
+func meanVariance(m mat.Dense) (float64, float64) {
+	values := m.RawMatrix().Data
+	mean, variance := 0.0, 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, variance / float64(len(values))
+}
+
+func TestNewDropoutError(t *testing.T) {
+	if _, err := NewDropout(0, 0.5); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewDropout(2, 1); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewAlphaDropout(2, -0.1); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewGaussianNoise(2, -1); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestDropoutForward(t *testing.T) {
+	dropout, _ := NewDropout(100, 0.25, WithRand(rand.New(rand.NewSource(1))))
+	input := mat.NewDense(100, 100, nil)
+	input.Apply(func(i, j int, v float64) float64 { return 1 }, input)
+
+	output := dropout.forward(*input)
+	dropped := 0
+	for _, v := range output.RawMatrix().Data {
+		switch v {
+		case 0:
+			dropped++
+		case 1 / 0.75:
+		default:
+			t.Fatalf("Expected: 0 or %v, Got: %v", 1/0.75, v)
+		}
+	}
+	if fraction := float64(dropped) / 10000; math.Abs(fraction-0.25) > 0.02 {
+		t.Errorf("Expected: %v, Got: %v", 0.25, fraction)
+	}
+
+	predicted := dropout.predict(*input)
+	if !mat.Equal(input, &predicted) {
+		t.Errorf("Expected: %v, Got: %v", input, predicted)
+	}
+}
+
+func TestAlphaDropoutStatistics(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	dropout, _ := NewAlphaDropout(100, 0.2, WithRand(random))
+	input := normalMatrix(100, 1000, random)
+
+	mean, variance := meanVariance(dropout.forward(*input))
+	if math.Abs(mean) > 0.02 || math.Abs(variance-1) > 0.02 {
+		t.Errorf("Expected: mean 0 and variance 1, Got: mean %v and variance %v", mean, variance)
+	}
+}
+
+func TestGaussianNoise(t *testing.T) {
+	noise, _ := NewGaussianNoise(100, 0.5, WithRand(rand.New(rand.NewSource(1))))
+	input := mat.NewDense(100, 1000, nil)
+
+	mean, variance := meanVariance(noise.forward(*input))
+	if math.Abs(mean) > 0.01 || math.Abs(variance-0.25) > 0.01 {
+		t.Errorf("Expected: mean 0 and variance 0.25, Got: mean %v and variance %v", mean, variance)
+	}
+
+	predicted := noise.predict(*input)
+	if !mat.Equal(input, &predicted) {
+		t.Errorf("Expected: %v, Got: %v", input, predicted)
+	}
+}
+
+func TestDropoutGradients(t *testing.T) {
+	dropout, _ := NewDropout(3, 0.5)
+	alpha, _ := NewAlphaDropout(3, 0.5)
+	noise, _ := NewGaussianNoise(3, 1)
+	input := mat.NewDense(3, 4, []float64{0.5, -1, 2, 0, 1.5, -0.3, 0.7, 1, -2, 0.1, 0.4, -0.8})
+
+	for _, layer := range []Layer{dropout, alpha, noise} {
+		checkGradients(t, fixedSeed{layer}, *input)
+	}
+}
+
+func TestTrainDropout(t *testing.T) {
+	data := mat.NewDense(2, 5, []float64{0, 0, 1, 1, 0.5, 0, 1, 0, 1, 0.5})
+	labels := mat.NewDense(2, 5, []float64{1, 0, 0, 0, 1, 0, 1, 1, 1, 0})
+	set := Set{*data, *labels}
+
+	var networks []*Network
+	for i := 0; i < 2; i++ {
+		random := rand.New(rand.NewSource(42))
+		dense, _ := NewDense(2, 8, WithRand(random))
+		activation, _ := NewActivation(8, ActivationRelu)
+		dropout, _ := NewDropout(8, 0.5)
+		noise, _ := NewGaussianNoise(8, 0.1)
+		output, _ := NewDense(8, 2, WithRand(random))
+		softmax, _ := NewSoftmax(2)
+		network, err := NewSequential(CategoricalCrossEntropyLoss, dense, activation, dropout, noise, output, softmax)
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		if _, err := network.Train(&set, 3, 2, ConstantRate(0.1), WithSeed(7), WithWorkers(2), WithWriter(nil)); err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		networks = append(networks, network)
+	}
+	checkSamePredictions(t, networks[0], networks[1], *data)
+
+	// the prediction skips the dropout and the noise
+	network := networks[0]
+	expected := forwardLayers([]Layer{network.layers[0], network.layers[1], network.layers[4], network.layers[5]}, *data)
+	ans := predictBatch(t, network, *data)
+	if !mat.Equal(&expected, &ans) {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+
+	var buffer bytes.Buffer
+	if err := network.Save(&buffer); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	loaded, err := Load(&buffer)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	checkSamePredictions(t, network, loaded, *data)
+	if rate := loaded.layers[2].(*Dropout).rate; rate != 0.5 {
+		t.Errorf("Expected: %v, Got: %v", 0.5, rate)
+	}
+}
@@ -0,0 +1,278 @@
+package nngo
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// layers with sparse gradients, see Param
+//
+// the layer adds the gradient of its replica itself, so that only the rows with a gradient are touched
+type sparse interface {
+	addGradient(replica Layer)
+}
+
+// layers that can only handle some input values, e.g. the ids of Embedding
+//
+// Train and PredictBatch check the input of the first layer up front and return the error,
+// training is false for the prediction
+type checkedInput interface {
+	checkInput(input mat.Dense, training bool) error
+}
+
+// maps integer ids, e.g. tokens or the values of a categorical feature, to learned vectors
+//
+// the input of each sample are length ids, which are saved as floats in the data of the set,
+// so a categorical feature needs one row instead of one row for each value like with one hot encoding
+// the output has the shape Signal(dimension, length), so the vectors can be used by recurrent and 1D layers
+// or by Dense layers as one long vector
+//
+// only the vectors of the ids in the batch get a gradient and are updated by the optimizer,
+// so the cost of a batch doesn't depend on the size of the vocabulary
+// Train and PredictBatch return an error for a wrong number of ids,
+// Train also for ids outside of [0, vocabulary),
+// during the prediction they are unknown ids and get a zero vector, like the padding of WithPaddingMask
+type Embedding struct {
+	// one row for each id
+	vectors  mat.Dense
+	gradient mat.Dense
+	length   int
+	frozen   bool
+	// id 0 is padding, see WithPaddingMask
+	paddingMask bool
+	// rows of the gradient that are not zero
+	rows []int
+	// ids of the last forward propagation, sample by sample, unknown ids are -1
+	ids []int
+}
+
+// constructor for Embedding layer
+//
+// vocabulary is the number of ids, dimension the size of each vector and length the number of ids of each sample
+// the vectors are initialized with WithWeightInit, the vocabulary is the fan in and the dimension the fan out
+// with WithPaddingMask id 0 is padding and always has a zero vector
+func NewEmbedding(vocabulary, dimension, length int, opts ...Option) (*Embedding, error) {
+	if vocabulary <= 0 || dimension <= 0 || length <= 0 {
+		return nil, fmt.Errorf("vocabulary, dimension and length must be greater than 0")
+	}
+
+	config := newOptions(opts)
+	e := Embedding{
+		vectors:     *mat.NewDense(vocabulary, dimension, nil),
+		gradient:    *mat.NewDense(vocabulary, dimension, nil),
+		length:      length,
+		paddingMask: config.paddingMask,
+	}
+	config.weightInit(e.vectors.RawMatrix().Data, vocabulary, dimension, config.random)
+	if e.paddingMask {
+		zero(e.vectors.RawRowView(0))
+	}
+	return &e, nil
+}
+
+// constructor for Embedding layer with pretrained vectors, e.g. word vectors
+//
+// vectors holds the vector of each id, all vectors need the same size
+// frozen vectors are not trained any further, they are saved with the network anyway
+func NewPretrainedEmbedding(vectors [][]float64, length int, frozen bool) (*Embedding, error) {
+	if len(vectors) == 0 || len(vectors[0]) == 0 {
+		return nil, fmt.Errorf("vectors must not be empty")
+	}
+	e, err := NewEmbedding(len(vectors), len(vectors[0]), length, WithWeightInit(Zeros))
+	if err != nil {
+		return nil, err
+	}
+	for id, vector := range vectors {
+		if len(vector) != len(vectors[0]) {
+			return nil, fmt.Errorf("vector %v has size %v, expected %v", id, len(vector), len(vectors[0]))
+		}
+		e.vectors.SetRow(id, vector)
+	}
+	e.frozen = frozen
+	return e, nil
+}
+
+// returns the shape of the output of one sample
+func (e *Embedding) OutputShape() Shape {
+	_, dimension := e.vectors.Dims()
+	return Signal(dimension, e.length)
+}
+
+// returns a copy of the vector of id
+func (e *Embedding) Vector(id int) []float64 {
+	return mat.Row(nil, id, &e.vectors)
+}
+
+func (e *Embedding) forward(input mat.Dense) mat.Dense {
+	e.ids = e.readIDs(input)
+	return e.lookup(e.ids)
+}
+
+func (e *Embedding) predict(input mat.Dense) mat.Dense {
+	return e.lookup(e.readIDs(input))
+}
+
+// rounds the input to ids, ids outside of the vocabulary are -1
+//
+// the shape is checked by checkInput, missing ids are also -1 and additional ones are ignored
+func (e *Embedding) readIDs(input mat.Dense) []int {
+	rows, samples := input.Dims()
+	ids := make([]int, e.length*samples)
+	for n := 0; n < samples; n++ {
+		for t := 0; t < e.length; t++ {
+			ids[n*e.length+t] = -1
+			if t < rows {
+				ids[n*e.length+t] = e.id(input.At(t, n))
+			}
+		}
+	}
+	return ids
+}
+
+// returns the id of the value or -1, if it is outside of the vocabulary
+func (e *Embedding) id(value float64) int {
+	vocabulary, _ := e.vectors.Dims()
+	id := math.Round(value)
+	// also catches NaN
+	if !(id >= 0 && id < float64(vocabulary)) {
+		return -1
+	}
+	return int(id)
+}
+
+// checks that each sample has length ids and during training that they are in the vocabulary
+func (e *Embedding) checkInput(input mat.Dense, training bool) error {
+	rows, samples := input.Dims()
+	if rows != e.length {
+		return fmt.Errorf("embedding expects %v ids for each sample, got %v", e.length, rows)
+	}
+	if !training {
+		return nil
+	}
+	vocabulary, _ := e.vectors.Dims()
+	for n := 0; n < samples; n++ {
+		for t := 0; t < rows; t++ {
+			if e.id(input.At(t, n)) < 0 {
+				return fmt.Errorf("embedding id %v of sample %v is outside of the vocabulary [0, %v)", input.At(t, n), n, vocabulary)
+			}
+		}
+	}
+	return nil
+}
+
+// returns the vectors of the ids in the layout of OutputShape, unknown ids stay zero
+func (e *Embedding) lookup(ids []int) mat.Dense {
+	_, dimension := e.vectors.Dims()
+	samples := len(ids) / e.length
+	ans := mat.NewDense(dimension*e.length, samples, nil)
+	for n := 0; n < samples; n++ {
+		for t := 0; t < e.length; t++ {
+			if ids[n*e.length+t] < 0 {
+				continue
+			}
+			vector := e.vectors.RawRowView(ids[n*e.length+t])
+			for d, v := range vector {
+				ans.Set(d*e.length+t, n, v)
+			}
+		}
+	}
+	return *ans
+}
+
+// adds the gradient of each output to the row of its id,
+// only the rows of the last batch are reset
+//
+// the ids are not differentiable, so the input gradient is zero
+func (e *Embedding) backward(outputGradient mat.Dense) mat.Dense {
+	_, samples := outputGradient.Dims()
+	if e.frozen {
+		return *mat.NewDense(e.length, samples, nil)
+	}
+
+	for _, row := range e.rows {
+		zero(e.gradient.RawRowView(row))
+	}
+	e.rows = nil
+	seen := make(map[int]bool)
+	for n := 0; n < samples; n++ {
+		for t := 0; t < e.length; t++ {
+			id := e.ids[n*e.length+t]
+			if id < 0 || (e.paddingMask && id == 0) {
+				continue
+			}
+			if !seen[id] {
+				seen[id] = true
+				e.rows = append(e.rows, id)
+			}
+			gradient := e.gradient.RawRowView(id)
+			for d := range gradient {
+				gradient[d] += outputGradient.At(d*e.length+t, n)
+			}
+		}
+	}
+	return *mat.NewDense(e.length, samples, nil)
+}
+
+// adds the rows of the replica to the gradient
+func (e *Embedding) addGradient(replica Layer) {
+	r := replica.(*Embedding)
+	seen := make(map[int]bool, len(e.rows))
+	for _, row := range e.rows {
+		seen[row] = true
+	}
+	for _, row := range r.rows {
+		if !seen[row] {
+			seen[row] = true
+			e.rows = append(e.rows, row)
+		}
+		floats.Add(e.gradient.RawRowView(row), r.gradient.RawRowView(row))
+	}
+}
+
+func (e *Embedding) state() layerState {
+	vocabulary, dimension := e.vectors.Dims()
+	return layerState{Type: "embedding", Shape: []int{vocabulary, dimension, e.length}, Config: []float64{boolToFloat(e.frozen), boolToFloat(e.paddingMask)}}
+}
+
+func (e *Embedding) replica() Layer {
+	vocabulary, dimension := e.vectors.Dims()
+	return &Embedding{
+		vectors:     e.vectors,
+		gradient:    *mat.NewDense(vocabulary, dimension, nil),
+		length:      e.length,
+		frozen:      e.frozen,
+		paddingMask: e.paddingMask,
+	}
+}
+
+// returns the vectors with their sparse gradient, frozen vectors have no parameters
+func (e *Embedding) params() []Param {
+	if e.frozen {
+		return nil
+	}
+	_, dimension := e.vectors.Dims()
+	return []Param{{
+		Value:    e.vectors.RawMatrix().Data,
+		Gradient: e.gradient.RawMatrix().Data,
+		Rows:     e.rows,
+		RowSize:  dimension,
+	}}
+}
+
+// frozen vectors are saved as buffer, because they are no parameters
+func (e *Embedding) buffers() [][]float64 {
+	if !e.frozen {
+		return nil
+	}
+	return [][]float64{e.vectors.RawMatrix().Data}
+}
+
+// sets all values to 0
+func zero(values []float64) {
+	for i := range values {
+		values[i] = 0
+	}
+}
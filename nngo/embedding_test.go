@@ -0,0 +1,252 @@
+package nngo
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestNewEmbeddingError(t *testing.T) {
+	if _, err := NewEmbedding(0, 2, 1); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewPretrainedEmbedding(nil, 1, false); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewPretrainedEmbedding([][]float64{{1, 2}, {3}}, 1, false); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestEmbeddingForward(t *testing.T) {
+	embedding, _ := NewPretrainedEmbedding([][]float64{{1, 10}, {2, 20}, {3, 30}}, 3, false)
+	if ans := embedding.OutputShape(); ans != Signal(2, 3) {
+		t.Errorf("Expected: %v, Got: %v", Signal(2, 3), ans)
+	}
+
+	input := mat.NewDense(3, 2, []float64{
+		2, 0,
+		0, 1,
+		1, 1,
+	})
+	expected := mat.NewDense(6, 2, []float64{
+		3, 1,
+		1, 2,
+		2, 2,
+		30, 10,
+		10, 20,
+		20, 20,
+	})
+	output := embedding.forward(*input)
+	if !mat.Equal(expected, &output) {
+		t.Errorf("Expected: %v, Got: %v", expected, output)
+	}
+	if diff := cmp.Diff([]float64{2, 20}, embedding.Vector(1)); diff != "" {
+		t.Errorf("Vector mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestEmbeddingInvalidID(t *testing.T) {
+	embedding, _ := NewPretrainedEmbedding([][]float64{{1, 10}, {2, 20}, {3, 30}}, 2, false)
+	network, _ := NewSequential(MseLoss, embedding)
+
+	// unknown ids get a zero vector during the prediction
+	output := predictBatch(t, network, *mat.NewDense(2, 2, []float64{3, 1, -1, math.NaN()}))
+	expected := mat.NewDense(4, 2, []float64{0, 2, 0, 0, 0, 20, 0, 0})
+	if !mat.Equal(expected, &output) {
+		t.Errorf("Expected: %v, Got: %v", expected, output)
+	}
+
+	// the training checks the ids up front
+	for _, data := range []*mat.Dense{
+		mat.NewDense(2, 2, []float64{0, 1, 2, 3}),
+		mat.NewDense(2, 2, []float64{0, 1, 2, -1}),
+		mat.NewDense(1, 2, []float64{0, 1}),
+	} {
+		_, samples := data.Dims()
+		set := Set{Data: *data, Labels: *mat.NewDense(4, samples, nil)}
+		if _, err := network.Train(&set, 1, 2, ConstantRate(0.1), WithWriter(nil)); err == nil {
+			t.Error("Expected error.")
+		}
+		valid := Set{Data: *mat.NewDense(2, 2, nil), Labels: *mat.NewDense(4, 2, nil)}
+		if _, err := network.Train(&valid, 1, 2, ConstantRate(0.1), WithValidation(&set), WithWriter(nil)); err == nil {
+			t.Error("Expected error.")
+		}
+	}
+}
+
+func TestEmbeddingPredictInvalidLength(t *testing.T) {
+	embedding, _ := NewPretrainedEmbedding([][]float64{{1, 10}, {2, 20}, {3, 30}}, 2, false)
+	network, _ := NewSequential(MseLoss, embedding)
+
+	// a wrong number of ids returns an error instead of panicking
+	for _, length := range []int{1, 3} {
+		if _, err := network.Predict(*mat.NewVecDense(length, nil)); err == nil {
+			t.Error("Expected error.")
+		}
+		if _, err := network.PredictBatch(*mat.NewDense(length, 2, nil)); err == nil {
+			t.Error("Expected error.")
+		}
+		set := Set{Data: *mat.NewDense(length, 2, nil), Labels: *mat.NewDense(4, 2, nil)}
+		if _, err := network.EvaluateOneHot(&set); err == nil {
+			t.Error("Expected error.")
+		}
+	}
+}
+
+func TestEmbeddingSparseGradient(t *testing.T) {
+	embedding, _ := NewEmbedding(5, 2, 2)
+	embedding.forward(*mat.NewDense(2, 2, []float64{3, 1, 3, 4}))
+	embedding.backward(*mat.NewDense(4, 2, []float64{1, 2, 3, 4, 5, 6, 7, 8}))
+
+	// id 3 appears twice, so its gradients are added up
+	expected := []float64{
+		0, 0,
+		2, 6,
+		0, 0,
+		4, 12,
+		4, 8,
+	}
+	p := embedding.params()[0]
+	if diff := cmp.Diff(expected, p.Gradient); diff != "" {
+		t.Errorf("Gradient mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{3, 1, 4}, p.Rows); diff != "" {
+		t.Errorf("Rows mismatch (-want +got):\n%s", diff)
+	}
+
+	// the rows of the last batch are reset
+	embedding.forward(*mat.NewDense(2, 1, []float64{0, 0}))
+	embedding.backward(*mat.NewDense(4, 1, []float64{1, 1, 1, 1}))
+	expected = []float64{2, 2, 0, 0, 0, 0, 0, 0, 0, 0}
+	p = embedding.params()[0]
+	if diff := cmp.Diff(expected, p.Gradient); diff != "" {
+		t.Errorf("Gradient mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]int{0}, p.Rows); diff != "" {
+		t.Errorf("Rows mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestEmbeddingGradients(t *testing.T) {
+	embedding, _ := NewEmbedding(4, 3, 2, WithRand(rand.New(rand.NewSource(1))))
+	checkGradients(t, embedding, *mat.NewDense(2, 3, []float64{0, 3, 3, 2, 0, 1}))
+}
+
+func TestSparseUpdate(t *testing.T) {
+	momentum, _ := NewMomentum(0.9)
+	rmsprop, _ := NewRMSprop(0.9)
+	adam, _ := NewAdam(0.9, 0.999)
+	for _, optimizer := range []Optimizer{NewSGD(), momentum, NewAdagrad(), rmsprop, adam} {
+		values := []float64{1, 1, 1, 1, 1, 1}
+		gradient := []float64{0.5, 0.5, 0, 0, 0.5, 0.5}
+		params := []Param{{Value: values, Gradient: gradient, Rows: []int{0}, RowSize: 2}}
+		optimizer.Update(params, 0.1)
+
+		// the gradient of the last row is ignored, because the row is not in Rows
+		if values[0] >= 1 || values[1] >= 1 {
+			t.Errorf("Expected the first row to change, Got: %v", values)
+		}
+		if diff := cmp.Diff([]float64{1, 1, 1, 1}, values[2:]); diff != "" {
+			t.Errorf("Values mismatch (-want +got):\n%s", diff)
+		}
+	}
+}
+
+// builds an embedding network with the same initial values for every call
+func embeddingNetwork(frozen bool) *Network {
+	random := rand.New(rand.NewSource(2))
+	embedding, _ := NewEmbedding(20, 3, 2, WithRand(random))
+	if frozen {
+		vectors := make([][]float64, 20)
+		for id := range vectors {
+			vectors[id] = embedding.Vector(id)
+		}
+		embedding, _ = NewPretrainedEmbedding(vectors, 2, true)
+	}
+	dense, _ := NewDense(6, 1, WithRand(random))
+	network, _ := NewSequential(MseLoss, embedding, dense)
+	return network
+}
+
+// ids of two categorical features and a label that depends on both
+func embeddingSet() *Set {
+	random := rand.New(rand.NewSource(3))
+	data := mat.NewDense(2, 40, nil)
+	labels := mat.NewDense(1, 40, nil)
+	for n := 0; n < 40; n++ {
+		first, second := random.Intn(10), 10+random.Intn(10)
+		data.Set(0, n, float64(first))
+		data.Set(1, n, float64(second))
+		labels.Set(0, n, float64(first%3)-float64(second%2))
+	}
+	return &Set{Data: *data, Labels: *labels}
+}
+
+func TestEmbeddingTrainWorkers(t *testing.T) {
+	set := embeddingSet()
+
+	// the sparse gradients of the workers are added up like dense gradients
+	var networks []*Network
+	for _, workers := range []int{1, 3} {
+		network := embeddingNetwork(false)
+		adam, _ := NewAdam(0.9, 0.999)
+		network.SetOptimizer(adam)
+		history, err := network.Train(set, 30, 8, ConstantRate(0.05), WithWorkers(workers), WithWriter(nil))
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		loss := history.Loss()
+		if loss[len(loss)-1] > loss[0]/2 {
+			t.Errorf("Expected the loss to decrease from %v, Got: %v", loss[0], loss[len(loss)-1])
+		}
+		networks = append(networks, network)
+	}
+
+	expected := predictBatch(t, networks[0], set.Data)
+	ans := predictBatch(t, networks[1], set.Data)
+	if !mat.EqualApprox(&expected, &ans, 1e-9) {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+}
+
+func TestEmbeddingFrozen(t *testing.T) {
+	network := embeddingNetwork(true)
+	embedding := network.layers[0].(*Embedding)
+	before := mat.DenseCopyOf(&embedding.vectors)
+
+	if _, err := network.Train(embeddingSet(), 3, 8, ConstantRate(0.1), WithWorkers(2), WithWriter(nil)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if !mat.Equal(before, &embedding.vectors) {
+		t.Errorf("Expected: %v, Got: %v", before, embedding.vectors)
+	}
+}
+
+func TestEmbeddingSaveLoad(t *testing.T) {
+	set := embeddingSet()
+	for _, frozen := range []bool{false, true} {
+		network := embeddingNetwork(frozen)
+		var buffer bytes.Buffer
+		if err := network.Save(&buffer); err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		loaded, err := Load(&buffer)
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		checkSamePredictions(t, network, loaded, set.Data)
+
+		ans := loaded.layers[0].(*Embedding)
+		if diff := cmp.Diff(network.layers[0].(*Embedding).Vector(5), ans.Vector(5)); diff != "" {
+			t.Errorf("Vector mismatch (-want +got):\n%s", diff)
+		}
+		if ans.frozen != frozen {
+			t.Errorf("Expected: %v, Got: %v", frozen, ans.frozen)
+		}
+	}
+}
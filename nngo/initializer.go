@@ -0,0 +1,132 @@
+package nngo
+
+import (
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// an initializer fills a parameter with its initial values
+//
+// fanIn is the number of inputs and fanOut the number of outputs of the layer,
+// for a dense layer values holds the weight matrix with fanOut rows and fanIn columns
+// all random numbers should be taken from random
+//
+// custom functions with the same signature can be used as initializers
+type Initializer func(values []float64, fanIn, fanOut int, random *rand.Rand)
+
+// uniform distribution in [-limit, limit] with limit = sqrt(6 / (fanIn + fanOut))
+// the default for the weights of dense layers
+// paper: https://proceedings.mlr.press/v9/glorot10a.html
+func GlorotUniform(values []float64, fanIn, fanOut int, random *rand.Rand) {
+	uniform(values, math.Sqrt(6/float64(fanIn+fanOut)), random)
+}
+
+// normal distribution with standard deviation sqrt(2 / (fanIn + fanOut))
+func GlorotNormal(values []float64, fanIn, fanOut int, random *rand.Rand) {
+	normal(values, math.Sqrt(2/float64(fanIn+fanOut)), random)
+}
+
+// uniform distribution in [-limit, limit] with limit = sqrt(6 / fanIn)
+// suited for relu activations
+// paper: https://arxiv.org/abs/1502.01852
+func HeUniform(values []float64, fanIn, fanOut int, random *rand.Rand) {
+	uniform(values, math.Sqrt(6/float64(fanIn)), random)
+}
+
+// normal distribution with standard deviation sqrt(2 / fanIn)
+func HeNormal(values []float64, fanIn, fanOut int, random *rand.Rand) {
+	normal(values, math.Sqrt(2/float64(fanIn)), random)
+}
+
+// uniform distribution in [-limit, limit] with limit = sqrt(3 / fanIn)
+// suited for selu activations
+func LeCunUniform(values []float64, fanIn, fanOut int, random *rand.Rand) {
+	uniform(values, math.Sqrt(3/float64(fanIn)), random)
+}
+
+// normal distribution with standard deviation sqrt(1 / fanIn)
+func LeCunNormal(values []float64, fanIn, fanOut int, random *rand.Rand) {
+	normal(values, math.Sqrt(1/float64(fanIn)), random)
+}
+
+// standard normal distribution without scaling
+func StandardNormal(values []float64, fanIn, fanOut int, random *rand.Rand) {
+	normal(values, 1, random)
+}
+
+// sets all values to 0, the default for biases
+func Zeros(values []float64, fanIn, fanOut int, random *rand.Rand) {
+	for i := range values {
+		values[i] = 0
+	}
+}
+
+// returns an initializer, that sets all values to value
+func Constant(value float64) Initializer {
+	return func(values []float64, fanIn, fanOut int, random *rand.Rand) {
+		for i := range values {
+			values[i] = value
+		}
+	}
+}
+
+// random orthogonal matrix with len(values) / fanIn rows and fanIn columns
+//
+// the rows are orthonormal, if there are less rows than columns, otherwise the columns
+// the matrix is the Q of the QR decomposition of a standard normal matrix
+// paper: https://arxiv.org/abs/1312.6120
+func Orthogonal(values []float64, fanIn, fanOut int, random *rand.Rand) {
+	rows := len(values) / fanIn
+	cols := fanIn
+
+	// QR needs at least as many rows as columns, so wide matrices are created transposed
+	m, n := rows, cols
+	transposed := rows < cols
+	if transposed {
+		m, n = cols, rows
+	}
+
+	a := mat.NewDense(m, n, nil)
+	for i := 0; i < m; i++ {
+		for j := 0; j < n; j++ {
+			a.Set(i, j, random.NormFloat64())
+		}
+	}
+
+	var qr mat.QR
+	qr.Factorize(a)
+	var q, r mat.Dense
+	qr.QTo(&q)
+	qr.RTo(&r)
+
+	for i := 0; i < m; i++ {
+		for j := 0; j < n; j++ {
+			// the signs of the diagonal of r make the distribution uniform
+			v := q.At(i, j)
+			if r.At(j, j) < 0 {
+				v = -v
+			}
+			if transposed {
+				values[j*cols+i] = v
+			} else {
+				values[i*cols+j] = v
+			}
+		}
+	}
+}
+
+// fills values uniformly from [-limit, limit]
+func uniform(values []float64, limit float64, random *rand.Rand) {
+	for i := range values {
+		values[i] = (2*random.Float64() - 1) * limit
+	}
+}
+
+// fills values from a normal distribution with mean 0
+func normal(values []float64, std float64, random *rand.Rand) {
+	for i := range values {
+		values[i] = random.NormFloat64() * std
+	}
+}
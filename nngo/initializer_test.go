@@ -0,0 +1,128 @@
+package nngo
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestInitializerLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		init  Initializer
+		limit float64
+	}{
+		{"GlorotUniform", GlorotUniform, math.Sqrt(6.0 / 30)},
+		{"HeUniform", HeUniform, math.Sqrt(6.0 / 20)},
+		{"LeCunUniform", LeCunUniform, math.Sqrt(3.0 / 20)},
+	}
+
+	random := rand.New(rand.NewSource(1))
+	for _, test := range tests {
+		values := make([]float64, 200)
+		test.init(values, 20, 10, random)
+		for _, v := range values {
+			if math.Abs(v) > test.limit {
+				t.Errorf("%v Expected values in [-%v, %v], Got: %v", test.name, test.limit, test.limit, v)
+			}
+		}
+	}
+}
+
+func TestInitializerStd(t *testing.T) {
+	tests := []struct {
+		name string
+		init Initializer
+		std  float64
+	}{
+		{"GlorotNormal", GlorotNormal, math.Sqrt(2.0 / 300)},
+		{"HeNormal", HeNormal, math.Sqrt(2.0 / 200)},
+		{"LeCunNormal", LeCunNormal, math.Sqrt(1.0 / 200)},
+		{"StandardNormal", StandardNormal, 1},
+	}
+
+	random := rand.New(rand.NewSource(1))
+	for _, test := range tests {
+		values := make([]float64, 20000)
+		test.init(values, 200, 100, random)
+
+		sum := 0.0
+		for _, v := range values {
+			sum += v * v
+		}
+		std := math.Sqrt(sum / float64(len(values)))
+		if math.Abs(std-test.std) > 0.05*test.std {
+			t.Errorf("%v Expected std: %v, Got: %v", test.name, test.std, std)
+		}
+	}
+}
+
+func TestConstantInitializer(t *testing.T) {
+	values := []float64{1, 2, 3}
+	Zeros(values, 3, 1, nil)
+	for _, v := range values {
+		if v != 0 {
+			t.Errorf("Expected: %v, Got: %v", 0, v)
+		}
+	}
+
+	Constant(0.5)(values, 3, 1, nil)
+	for _, v := range values {
+		if v != 0.5 {
+			t.Errorf("Expected: %v, Got: %v", 0.5, v)
+		}
+	}
+}
+
+func TestOrthogonal(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for _, shape := range [][2]int{{4, 4}, {6, 3}, {3, 6}} {
+		rows, cols := shape[0], shape[1]
+		values := make([]float64, rows*cols)
+		Orthogonal(values, cols, rows, random)
+		w := mat.NewDense(rows, cols, values)
+
+		// the smaller dimension is orthonormal
+		var product mat.Dense
+		size := cols
+		if rows < cols {
+			product.Mul(w, w.T())
+			size = rows
+		} else {
+			product.Mul(w.T(), w)
+		}
+
+		identity := mat.NewDiagDense(size, nil)
+		for i := 0; i < size; i++ {
+			identity.SetDiag(i, 1)
+		}
+		if !mat.EqualApprox(&product, identity, 1e-12) {
+			t.Errorf("Expected orthonormal matrix for shape %v, Got: %v", shape, mat.Formatted(&product))
+		}
+	}
+}
+
+func TestNewDenseInitializers(t *testing.T) {
+	dense, err := NewDense(3, 2)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if !mat.Equal(&dense.bias, mat.NewVecDense(2, nil)) {
+		t.Errorf("Expected zero bias, Got: %v", dense.bias)
+	}
+
+	custom := func(values []float64, fanIn, fanOut int, random *rand.Rand) {
+		for i := range values {
+			values[i] = float64(fanIn*10 + fanOut)
+		}
+	}
+	dense, err = NewDense(3, 2, WithWeightInit(custom), WithBiasInit(Constant(0.1)))
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if dense.weights.At(1, 2) != 32 || dense.bias.AtVec(1) != 0.1 {
+		t.Errorf("Expected: 32 and 0.1, Got: %v and %v", dense.weights.At(1, 2), dense.bias.AtVec(1))
+	}
+}
@@ -2,67 +2,110 @@ package nngo
 
 import (
 	"fmt"
-	"log"
-	"math/rand"
 
+	"gonum.org/v1/gonum/floats"
 	"gonum.org/v1/gonum/mat"
 )
 
 // default methods that have to be implemented by each layer
 //
 // a layer needs an forward and backward propagation method
+// both methods work on batches, each column of the matrix is one sample
+//
+// forward runs the layer in training mode and saves what backward needs inside the layer,
+// predict runs the layer in inference mode without changing the layer,
+// so it can be called from many goroutines at once
+// the network uses forward only in Train, e.g. Dropout drops inputs only there,
+// Predict, PredictBatch and EvaluateOneHot use predict and are deterministic
+//
+// state returns the serialised form of the layer without the learned parameters,
+// which are saved with params()
+//
+// replica returns a copy of the layer for another worker of the training,
+// the copy shares the parameters but has its own buffers and gradients
 type Layer interface {
-	forward(input mat.VecDense) mat.VecDense
-	backward(outputGradient mat.VecDense, learningRate float64) mat.VecDense
+	forward(input mat.Dense) mat.Dense
+	predict(input mat.Dense) mat.Dense
+	backward(outputGradient mat.Dense) mat.Dense
+	state() layerState
+	replica() Layer
+}
+
+// layers with parameters, that are learned during training
+//
+// backward saves the gradients of the parameters inside the layer
+// afterwards the optimizer of the network updates them
+type trainable interface {
+	params() []Param
 }
 
-// basic layer which consists of input and output vector
+// layers with a penalty on their parameters, e.g. Dense with WithRegularizer
+//
+// penalty returns the value, that is added to the loss,
+// addPenaltyGradient adds its gradient to the gradients of the parameters after backward
+type regularized interface {
+	penalty() float64
+	addPenaltyGradient()
+}
+
+// layers that restrict their parameters after each update, e.g. Dense with WithConstraint
+type constrained interface {
+	constrain()
+}
+
+// basic layer which consists of input and output matrix
 type Base struct {
-	input  mat.VecDense
-	output mat.VecDense
+	input  mat.Dense
+	output mat.Dense
 }
 
 // Dense layer consists of a base layer with a weight matrix, bias vector
+// and the gradients of both from the last backward propagation
+//
+// the regularizer and the constraint only affect the weights, not the bias
 type Dense struct {
-	base    Base
-	weights mat.Dense
-	bias    mat.VecDense
+	base            Base
+	weights         mat.Dense
+	bias            mat.VecDense
+	weightsGradient mat.Dense
+	biasGradient    mat.VecDense
+	regularizer     Regularizer
+	constraint      Constraint
 }
 
 // constructor for DenseLayer
 //
-// creates a new dense layer with random values for the vectors and matrices with the given data
+// creates a new dense layer, the weights are initialized with GlorotUniform and the bias with zeros
+// WithWeightInit and WithBiasInit select other initializers,
+// the random numbers come from the source of WithRand
+// WithRegularizer and WithConstraint regularize the weights during training
 //
 // inputSize and outputSize need to be positive
-func NewDense(inputSize, outputSize int) (*Dense, error) {
+func NewDense(inputSize, outputSize int, opts ...Option) (*Dense, error) {
 	if inputSize <= 0 || outputSize <= 0 {
 		return nil, fmt.Errorf("inputSize and outputSize must be greater than 0")
 	}
-	var dense Dense
-
-	input := mat.NewVecDense(inputSize, make([]float64, inputSize))
-
-	for i := 0; i < inputSize; i++ {
-		input.SetVec(i, rand.NormFloat64())
+	config := newOptions(opts)
+	if err := config.regularizer.validate(); err != nil {
+		return nil, err
 	}
+	if err := config.constraint.validate(); err != nil {
+		return nil, err
+	}
+	dense := Dense{regularizer: config.regularizer, constraint: config.constraint}
 
-	dense.base.input = *input
+	dense.base.input = *mat.NewDense(inputSize, 1, nil)
+	dense.base.output = *mat.NewDense(outputSize, 1, nil)
 
-	output := mat.NewVecDense(outputSize, make([]float64, outputSize))
 	bias := mat.NewVecDense(outputSize, make([]float64, outputSize))
 	weights := mat.NewDense(outputSize, inputSize, make([]float64, outputSize*inputSize))
+	config.weightInit(weights.RawMatrix().Data, inputSize, outputSize, config.random)
+	config.biasInit(bias.RawVector().Data, inputSize, outputSize, config.random)
 
-	for i := 0; i < outputSize; i++ {
-		output.SetVec(i, rand.NormFloat64())
-		bias.SetVec(i, rand.NormFloat64())
-		for j := 0; j < inputSize; j++ {
-			weights.Set(i, j, rand.NormFloat64())
-		}
-	}
-
-	dense.base.output = *output
 	dense.weights = *weights
 	dense.bias = *bias
+	dense.weightsGradient = *mat.NewDense(outputSize, inputSize, nil)
+	dense.biasGradient = *mat.NewVecDense(outputSize, nil)
 
 	return &dense, nil
 }
@@ -70,120 +113,248 @@ func NewDense(inputSize, outputSize int) (*Dense, error) {
 // forward propagation with the following formula:
 //
 // ans = weights * input + bias
-func (d *Dense) forward(input mat.VecDense) mat.VecDense {
+//
+// the bias is added to every column of the batch
+func (d *Dense) forward(input mat.Dense) mat.Dense {
 	d.base.input = input
-	var ans mat.VecDense
-	ans.MulVec(&d.weights, &input)
-	ans.AddVec(&ans, &d.bias)
+	return d.predict(input)
+}
+
+func (d *Dense) predict(input mat.Dense) mat.Dense {
+	var ans mat.Dense
+	ans.Mul(&d.weights, &input)
+	ans.Apply(func(i, j int, v float64) float64 {
+		return v + d.bias.AtVec(i)
+	}, &ans)
 	return ans
 }
 
-// backward propagation by using gradient descent
+// backward propagation calculates the gradients of the weights and the bias
+// the parameters are updated afterwards by the optimizer of the network
+//
+// the outputGradient is expected to be already averaged over the batch,
+// so the gradients of the single samples are summed up
 //
 // nice explanation can be found here: https://www.youtube.com/watch?v=Ilg3gGewQ5U
-func (d *Dense) backward(outputGradient mat.VecDense, learningRate float64) mat.VecDense {
+func (d *Dense) backward(outputGradient mat.Dense) mat.Dense {
 	// calculate the outputGradient for the next layer
-	weightsTranspose := mat.NewDense(
-		d.weights.RawMatrix().Rows,
-		d.weights.RawMatrix().Cols,
-		nil,
-	)
-	weightsTranspose.Copy(&d.weights)
-	var inputGradient mat.VecDense
-	inputGradient.MulVec(weightsTranspose.T(), &outputGradient)
-
-	// update weights
-	var weightsGradient mat.Dense
-	transpose := mat.NewDense(d.base.input.Len(), 1, nil)
-	transpose.Copy(&d.base.input)
-	weightsGradient.Mul(&outputGradient, transpose.T())
-	weightsGradient.Scale(learningRate, &weightsGradient)
-	d.weights.Sub(&d.weights, &weightsGradient)
-
-	// update bias
-	outputGradient.ScaleVec(learningRate, &outputGradient)
-	d.bias.SubVec(&d.bias, &outputGradient)
+	var inputGradient mat.Dense
+	inputGradient.Mul(d.weights.T(), &outputGradient)
+
+	// gradient of the weights
+	d.weightsGradient.Mul(&outputGradient, d.base.input.T())
+
+	// gradient of the bias
+	rows, _ := outputGradient.Dims()
+	for i := 0; i < rows; i++ {
+		d.biasGradient.SetVec(i, floats.Sum(outputGradient.RawRowView(i)))
+	}
 
 	return inputGradient
 }
 
+func (d *Dense) state() layerState {
+	rows, cols := d.weights.Dims()
+	state := layerState{Type: "dense", Shape: []int{cols, rows}}
+	if d.regularizer != (Regularizer{}) || d.constraint != (Constraint{}) {
+		state.Config = []float64{d.regularizer.L1, d.regularizer.L2, d.constraint.value}
+		state.Constraint = d.constraint.name
+	}
+	return state
+}
+
+func (d *Dense) replica() Layer {
+	rows, cols := d.weights.Dims()
+	return &Dense{
+		base:            d.base,
+		weights:         d.weights,
+		bias:            d.bias,
+		weightsGradient: *mat.NewDense(rows, cols, nil),
+		biasGradient:    *mat.NewVecDense(rows, nil),
+		regularizer:     d.regularizer,
+		constraint:      d.constraint,
+	}
+}
+
+// returns the weights and the bias together with their gradients
+func (d *Dense) params() []Param {
+	return []Param{
+		{Value: d.weights.RawMatrix().Data, Gradient: d.weightsGradient.RawMatrix().Data},
+		{Value: d.bias.RawVector().Data, Gradient: d.biasGradient.RawVector().Data},
+	}
+}
+
+func (d *Dense) penalty() float64 {
+	return d.regularizer.penalty(d.weights.RawMatrix().Data)
+}
+
+func (d *Dense) addPenaltyGradient() {
+	d.regularizer.addGradient(d.weights.RawMatrix().Data, d.weightsGradient.RawMatrix().Data)
+}
+
+func (d *Dense) constrain() {
+	d.constraint.apply(&d.weights)
+}
+
 // consists of a base layer and an activation function
 // this layer just applies the activation function to the output of a dense layer
 type Activation struct {
-	base                 Base
-	activation           activationFunc
-	activationDerivative activationFunc
+	base     Base
+	function ActivationFunction
 }
 
 // constructor for Activation layer
 //
-// creates a new activation layer with random values for the input and output with the given data
-//
 // inputSize and outputSize need to be positive
-func NewActivation(size, activationSpecs int) (*Activation, error) {
-	if size <= 0 {
-		return nil, fmt.Errorf("inputSize and outputSize must be greater than 0")
-	}
-
-	funcTuple, err := getActivationTuple(activationSpecs)
+func NewActivation(size int, activationSpecs ActivationCode) (*Activation, error) {
+	function, err := getActivationFunction(activationSpecs)
 	if err != nil {
 		return nil, err
 	}
 
-	var activation Activation
-	activation.activation = funcTuple.activation
-	activation.activationDerivative = funcTuple.activationDerivative
-
-	input := mat.NewVecDense(size, make([]float64, size))
-	output := mat.NewVecDense(size, make([]float64, size))
+	return NewActivationWith(size, function)
+}
 
-	for i := 0; i < size; i++ {
-		input.SetVec(i, rand.NormFloat64())
-		output.SetVec(i, rand.NormFloat64())
+// constructor for Activation layer with any activation function,
+// e.g. SigmoidActivation or a custom function
+//
+// size needs to be positive
+func NewActivationWith(size int, function ActivationFunction) (*Activation, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("inputSize and outputSize must be greater than 0")
+	}
+	if function == nil {
+		return nil, fmt.Errorf("activation function must not be nil")
 	}
 
-	activation.base.input = *input
-	activation.base.output = *output
+	var activation Activation
+	activation.function = function
+	activation.base.input = *mat.NewDense(size, 1, nil)
+	activation.base.output = *mat.NewDense(size, 1, nil)
 
 	return &activation, nil
 }
 
 // just applies the activation function to the input
-func (act *Activation) forward(input mat.VecDense) mat.VecDense {
+func (act *Activation) forward(input mat.Dense) mat.Dense {
 	act.base.input = input
-	return activationVector(input, act.activation)
+	return act.predict(input)
+}
+
+func (act *Activation) predict(input mat.Dense) mat.Dense {
+	return activationMatrix(input, act.function.Activate)
 }
 
 // applies the activation derivative to the input and returns it
-func (act *Activation) backward(outputGradient mat.VecDense, learningRate float64) mat.VecDense {
-	cache := activationVector(act.base.input, act.activationDerivative)
-	outputGradient, err := componentWise(outputGradient, cache)
-	if err != nil {
-		log.Fatal(err)
+//
+// if the activation function has learned parameters, their gradients are calculated as well
+func (act *Activation) backward(outputGradient mat.Dense) mat.Dense {
+	if function, ok := act.function.(trainableActivation); ok {
+		function.gradients(act.base.input, outputGradient)
 	}
-	return outputGradient
+
+	ans := activationMatrix(act.base.input, act.function.Derivative)
+	ans.MulElem(&ans, &outputGradient)
+	return ans
 }
 
-// applies the given activation function on each element of the vector
-func activationVector(vector mat.VecDense, activation activationFunc) mat.VecDense {
-	ans := mat.NewVecDense(vector.Len(), nil)
-	ans.CopyVec(&vector)
-	for i := 0; i < ans.Len(); i++ {
-		ans.SetVec(i, activation(ans.AtVec(i)))
+func (act *Activation) state() layerState {
+	rows, _ := act.base.input.Dims()
+	state := layerState{Type: "activation", Shape: []int{rows}, Activation: act.function.Name()}
+	if c, ok := act.function.(configurableActivation); ok {
+		state.Config = c.config()
 	}
+	return state
+}
 
-	return *ans
+func (act *Activation) replica() Layer {
+	function := act.function
+	if f, ok := function.(trainableActivation); ok {
+		function = f.replica()
+	}
+	return &Activation{base: act.base, function: function}
+}
+
+// returns the learned parameters of the activation function, e.g. the slope of PRelu
+func (act *Activation) params() []Param {
+	if function, ok := act.function.(trainableActivation); ok {
+		return function.params()
+	}
+	return nil
+}
+
+// applies the softmax function on each sample
+//
+// unlike the Activation layer, softmax depends on all elements of the vector
+// the output of each sample is a probability distribution
+type Softmax struct {
+	base Base
 }
 
-// multiply each component with the corresponding component of the other vector
-func componentWise(a, b mat.VecDense) (mat.VecDense, error) {
-	var ans mat.VecDense
-	if a.Len() != b.Len() {
-		return ans, fmt.Errorf("vectors need to have the same length")
+// constructor for Softmax layer
+//
+// size needs to be positive
+func NewSoftmax(size int) (*Softmax, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("size must be greater than 0")
 	}
-	ans = a
-	for i := 0; i < a.Len(); i++ {
-		ans.SetVec(i, a.AtVec(i)*b.AtVec(i))
+
+	var s Softmax
+	s.base.input = *mat.NewDense(size, 1, nil)
+	s.base.output = *mat.NewDense(size, 1, nil)
+	return &s, nil
+}
+
+// applies softmax to each column of the input
+func (s *Softmax) forward(input mat.Dense) mat.Dense {
+	s.base.input = input
+	s.base.output = s.predict(input)
+	return s.base.output
+}
+
+func (s *Softmax) predict(input mat.Dense) mat.Dense {
+	rows, cols := input.Dims()
+	ans := mat.NewDense(rows, cols, nil)
+	column := make([]float64, rows)
+	for j := 0; j < cols; j++ {
+		softmax(mat.Col(column, j, &input), column)
+		ans.SetCol(j, column)
 	}
-	return ans, nil
+	return *ans
+}
+
+func (s *Softmax) state() layerState {
+	rows, _ := s.base.output.Dims()
+	return layerState{Type: "softmax", Shape: []int{rows}}
+}
+
+func (s *Softmax) replica() Layer {
+	return &Softmax{base: s.base}
+}
+
+// multiplies the outputGradient with the jacobian of softmax
+//
+// for each sample: inputGradient = output * (outputGradient - dot(outputGradient, output))
+func (s *Softmax) backward(outputGradient mat.Dense) mat.Dense {
+	rows, cols := outputGradient.Dims()
+	ans := mat.NewDense(rows, cols, nil)
+	for j := 0; j < cols; j++ {
+		output := mat.Col(nil, j, &s.base.output)
+		gradient := mat.Col(nil, j, &outputGradient)
+		dot := floats.Dot(output, gradient)
+		for i := range gradient {
+			ans.Set(i, j, output[i]*(gradient[i]-dot))
+		}
+	}
+	return *ans
+}
+
+// applies the given activation function on each element of the matrix
+func activationMatrix(matrix mat.Dense, activation activationFunc) mat.Dense {
+	var ans mat.Dense
+	ans.Apply(func(i, j int, v float64) float64 {
+		return activation(v)
+	}, &matrix)
+
+	return ans
 }
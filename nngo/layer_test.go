@@ -1,6 +1,7 @@
 package nngo
 
 import (
+	"math"
 	"math/rand"
 	"testing"
 
@@ -15,7 +16,9 @@ func TestNewDenseNormal(t *testing.T) {
 		t.Errorf("Didn't expect this error: %v", err)
 	}
 
-	if dense.base.input.Len() != 4 || dense.base.output.Len() != 2 {
+	inputRows, _ := dense.base.input.Dims()
+	outputRows, _ := dense.base.output.Dims()
+	if inputRows != 4 || outputRows != 2 {
 		t.Error("Input and output have not expected dimensions")
 	}
 
@@ -44,13 +47,38 @@ func TestDenseForwardNormal(t *testing.T) {
 		t.Errorf("Didn't expect this error: %v", err)
 	}
 
-	input := mat.NewVecDense(4, []float64{1, 2, 3, 4})
+	input := mat.NewDense(4, 1, []float64{1, 2, 3, 4})
 	output := dense.forward(*input)
-	if output.Len() != 2 {
+	rows, cols := output.Dims()
+	if rows != 2 || cols != 1 {
 		t.Error("Input and output have not expected dimensions")
 	}
 }
 
+func TestDenseForwardBatch(t *testing.T) {
+	dense, err := NewDense(2, 2)
+
+	if err != nil {
+		t.Errorf("Didn't expect this error: %v", err)
+	}
+
+	dense.weights = *mat.NewDense(2, 2, []float64{1, 2, 3, 4})
+	dense.bias = *mat.NewVecDense(2, []float64{1, -1})
+
+	input := mat.NewDense(2, 3, []float64{
+		1, 0, 2,
+		0, 1, 2,
+	})
+	output := dense.forward(*input)
+	expected := mat.NewDense(2, 3, []float64{
+		2, 3, 7,
+		2, 3, 13,
+	})
+	if !mat.Equal(&output, expected) {
+		t.Errorf("Expected: %v, Got: %v", expected, output)
+	}
+}
+
 func TestDenseBackwardNormal(t *testing.T) {
 	dense, err := NewDense(4, 2)
 
@@ -58,8 +86,8 @@ func TestDenseBackwardNormal(t *testing.T) {
 		t.Errorf("Didn't expect this error: %v", err)
 	}
 
-	input := mat.NewVecDense(2, []float64{1, 2})
-	output := dense.backward(*input, 1)
+	input := mat.NewDense(2, 1, []float64{1, 2})
+	output := dense.backward(*input)
 
 	rows, cols := output.Dims()
 	if rows != 4 || cols != 1 {
@@ -67,6 +95,127 @@ func TestDenseBackwardNormal(t *testing.T) {
 	}
 }
 
+func TestDenseBackwardBatch(t *testing.T) {
+	dense, err := NewDense(2, 1)
+
+	if err != nil {
+		t.Errorf("Didn't expect this error: %v", err)
+	}
+
+	dense.weights = *mat.NewDense(1, 2, []float64{1, 1})
+	dense.bias = *mat.NewVecDense(1, []float64{0})
+
+	_ = dense.forward(*mat.NewDense(2, 2, []float64{
+		1, 3,
+		2, 4,
+	}))
+
+	// the gradient is already averaged, so the gradients are summed over the batch
+	output := dense.backward(*mat.NewDense(1, 2, []float64{0.5, 0.5}))
+
+	expectedGradient := mat.NewDense(1, 2, []float64{2, 3})
+	if !mat.Equal(&dense.weightsGradient, expectedGradient) {
+		t.Errorf("Expected: %v, Got: %v", expectedGradient, dense.weightsGradient)
+	}
+
+	if dense.biasGradient.AtVec(0) != 1 {
+		t.Errorf("Expected: %v, Got: %v", 1, dense.biasGradient.AtVec(0))
+	}
+
+	// the parameters are updated by the optimizer
+	NewSGD().Update(dense.params(), 1)
+
+	expectedWeights := mat.NewDense(1, 2, []float64{-1, -2})
+	if !mat.Equal(&dense.weights, expectedWeights) {
+		t.Errorf("Expected: %v, Got: %v", expectedWeights, dense.weights)
+	}
+
+	if dense.bias.AtVec(0) != -1 {
+		t.Errorf("Expected: %v, Got: %v", -1, dense.bias.AtVec(0))
+	}
+
+	rows, cols := output.Dims()
+	if rows != 2 || cols != 2 {
+		t.Error("Input gradient doesn't have expected dimensions")
+	}
+}
+
+// compares the gradients of backward with numerical gradients
+//
+// the objective is the sum of the output multiplied elementwise with fixed weights,
+// so the outputGradient of backward is exactly these weights
+func checkGradients(t *testing.T, layer Layer, input mat.Dense) {
+	t.Helper()
+	const h = 1e-6
+
+	output := layer.forward(input)
+	rows, cols := output.Dims()
+	weights := mat.NewDense(rows, cols, nil)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			weights.Set(i, j, math.Sin(float64(i*cols+j+1)))
+		}
+	}
+
+	objective := func(x mat.Dense) float64 {
+		out := layer.forward(x)
+		var product mat.Dense
+		product.MulElem(&out, weights)
+		return mat.Sum(&product)
+	}
+
+	inputGradient := layer.backward(*mat.DenseCopyOf(weights))
+	var paramGradients [][]float64
+	if tr, ok := layer.(trainable); ok {
+		for _, p := range tr.params() {
+			paramGradients = append(paramGradients, append([]float64(nil), p.Gradient...))
+		}
+	}
+
+	compare := func(name string, analytic, numeric float64) {
+		if math.Abs(analytic-numeric) > 1e-4*math.Max(1, math.Abs(numeric)) {
+			t.Errorf("%v: Expected: %v, Got: %v", name, numeric, analytic)
+		}
+	}
+
+	x := mat.DenseCopyOf(&input)
+	inputRows, inputCols := x.Dims()
+	for i := 0; i < inputRows; i++ {
+		for j := 0; j < inputCols; j++ {
+			value := x.At(i, j)
+			x.Set(i, j, value+h)
+			plus := objective(*x)
+			x.Set(i, j, value-h)
+			minus := objective(*x)
+			x.Set(i, j, value)
+			compare("input gradient", inputGradient.At(i, j), (plus-minus)/(2*h))
+		}
+	}
+
+	if tr, ok := layer.(trainable); ok {
+		for k, p := range tr.params() {
+			for l := range p.Value {
+				value := p.Value[l]
+				p.Value[l] = value + h
+				plus := objective(input)
+				p.Value[l] = value - h
+				minus := objective(input)
+				p.Value[l] = value
+				compare("parameter gradient", paramGradients[k][l], (plus-minus)/(2*h))
+			}
+		}
+	}
+}
+
+func TestDenseGradients(t *testing.T) {
+	dense, err := NewDense(3, 2)
+	if err != nil {
+		t.Errorf("Didn't expect this error: %v", err)
+	}
+
+	checkGradients(t, dense, *mat.NewDense(3, 2, []float64{1, -2, 0.5, 3, -1, 2}))
+}
+
 // TODO: add test and error handling for wrong inputs in forward and backward
 // TODO: add testing for right range of values, e.g. gradient of backward
 // TODO: add testing for helper functions
@@ -78,18 +227,20 @@ func TestNewActivationNormal(t *testing.T) {
 		t.Errorf("Didn't expect this error: %v", err)
 	}
 
-	if activation.base.input.Len() != 4 || activation.base.output.Len() != 4 {
+	inputRows, _ := activation.base.input.Dims()
+	outputRows, _ := activation.base.output.Dims()
+	if inputRows != 4 || outputRows != 4 {
 		t.Error("Input and output have not expected dimensions")
 	}
 
 	r := rand.Float64()
-	if activation.activation(r) != Sigmoid(r) || activation.activationDerivative(r) != SigmoidDerivative(r) {
-		t.Errorf("Expected: %v, Got: %v", Sigmoid(r), activation.activation(r))
+	if activation.function.Activate(r) != Sigmoid(r) || activation.function.Derivative(r) != SigmoidDerivative(r) {
+		t.Errorf("Expected: %v, Got: %v", Sigmoid(r), activation.function.Activate(r))
 	}
 
 	r = rand.Float64()
-	if activation.activationDerivative(r) != SigmoidDerivative(r) {
-		t.Errorf("Expected: %v, Got: %v", SigmoidDerivative(r), activation.activationDerivative(r))
+	if activation.function.Derivative(r) != SigmoidDerivative(r) {
+		t.Errorf("Expected: %v, Got: %v", SigmoidDerivative(r), activation.function.Derivative(r))
 	}
 }
 
@@ -116,10 +267,10 @@ func TestActivationForwardNormal(t *testing.T) {
 		t.Errorf("Didn't expect this error: %v", err)
 	}
 
-	input := mat.NewVecDense(4, []float64{1, 2, 3, 4})
+	input := mat.NewDense(4, 1, []float64{1, 2, 3, 4})
 	output := activation.forward(*input)
-	expected := activationVector(*input, Tanh)
-	if !cmp.Equal(output, expected, cmp.AllowUnexported(mat.VecDense{})) {
+	expected := activationMatrix(*input, Tanh)
+	if !cmp.Equal(output, expected, cmp.AllowUnexported(mat.Dense{})) {
 		t.Errorf("Expected: %v, Got: %v", expected, output)
 	}
 }
@@ -131,13 +282,140 @@ func TestActivationBackwardNormal(t *testing.T) {
 		t.Errorf("Didn't expect this error: %v", err)
 	}
 
-	input := mat.NewVecDense(2, []float64{1, 2})
+	input := mat.NewDense(2, 1, []float64{1, 2})
 	_ = activation.forward(*input)
 
-	output := activation.backward(*input, 1)
-	expected := activationVector(*mat.NewVecDense(2, []float64{1, 2}), TanhDerivative)
-	expected.SetVec(1, expected.AtVec(1)*2)
-	if !cmp.Equal(output, expected, cmp.AllowUnexported(mat.VecDense{})) {
+	output := activation.backward(*input)
+	expected := activationMatrix(*mat.NewDense(2, 1, []float64{1, 2}), TanhDerivative)
+	expected.Set(1, 0, expected.At(1, 0)*2)
+	if !cmp.Equal(output, expected, cmp.AllowUnexported(mat.Dense{})) {
 		t.Errorf("Expected: %v, Got: %v", expected, output)
 	}
 }
+
+func TestActivationPReluGradients(t *testing.T) {
+	activation, err := NewActivation(3, ActivationPRelu)
+	if err != nil {
+		t.Errorf("Didn't expect this error: %v", err)
+	}
+
+	if len(activation.params()) != 1 {
+		t.Errorf("Expected the slope as parameter, Got: %v", activation.params())
+	}
+
+	checkGradients(t, activation, *mat.NewDense(3, 2, []float64{1, -2, -0.5, 3, -1, 2}))
+}
+
+func TestActivationWithoutParams(t *testing.T) {
+	activation, err := NewActivation(3, ActivationRelu)
+	if err != nil {
+		t.Errorf("Didn't expect this error: %v", err)
+	}
+
+	if len(activation.params()) != 0 {
+		t.Errorf("Didn't expect parameters, Got: %v", activation.params())
+	}
+}
+
+func TestNewSoftmax(t *testing.T) {
+	softmax, err := NewSoftmax(3)
+	if err != nil {
+		t.Errorf("Didn't expect this error: %v", err)
+	}
+
+	if rows, _ := softmax.base.input.Dims(); rows != 3 {
+		t.Error("Input has not expected dimensions")
+	}
+
+	if _, err := NewSoftmax(0); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestSoftmaxForward(t *testing.T) {
+	softmax, err := NewSoftmax(3)
+	if err != nil {
+		t.Errorf("Didn't expect this error: %v", err)
+	}
+
+	// the second sample would overflow without subtracting the max value
+	input := mat.NewDense(3, 2, []float64{
+		1, 1000,
+		2, 1000,
+		3, 1000,
+	})
+	output := softmax.forward(*input)
+
+	sum := math.Exp(1) + math.Exp(2) + math.Exp(3)
+	expected := mat.NewDense(3, 2, []float64{
+		math.Exp(1) / sum, 1.0 / 3,
+		math.Exp(2) / sum, 1.0 / 3,
+		math.Exp(3) / sum, 1.0 / 3,
+	})
+	if !mat.EqualApprox(&output, expected, 1e-12) {
+		t.Errorf("Expected: %v, Got: %v", expected, output)
+	}
+}
+
+func TestSoftmaxGradients(t *testing.T) {
+	softmax, err := NewSoftmax(4)
+	if err != nil {
+		t.Errorf("Didn't expect this error: %v", err)
+	}
+
+	checkGradients(t, softmax, *mat.NewDense(4, 2, []float64{1, -2, 0.5, 3, -1, 2, 0, 0.1}))
+}
+
+func TestReplica(t *testing.T) {
+	dense, _ := NewDense(2, 3)
+	prelu, _ := NewActivation(3, ActivationPRelu)
+	softmax, _ := NewSoftmax(3)
+	dropout, _ := NewDropout(3, 0.5)
+	batchNorm, _ := NewBatchNorm(3, 0.9, 1e-5)
+	layerNorm, _ := NewLayerNorm(3, 1e-5)
+	conv, _ := NewConv2D(Shape{1, 3, 3}, 2, 2, 2)
+	pool, _ := NewMaxPool2D(Shape{1, 3, 3}, 2)
+	lstm, _ := NewLSTM(Signal(2, 3), 2)
+	gru, _ := NewGRU(Signal(2, 3), 2)
+	bidirectional, _ := NewBidirectional(lstm, gru)
+	embedding, _ := NewEmbedding(4, 2, 3)
+	encoding, _ := NewLearnedPositionalEncoding(Signal(2, 3))
+	block, _ := NewTransformerEncoderBlock(Signal(2, 3), 2, 4)
+
+	layers := []Layer{dense, prelu, softmax, dropout, batchNorm, layerNorm, conv, pool, lstm, bidirectional, embedding, encoding, block}
+	for _, layer := range layers {
+		replica := layer.replica()
+		if replica == layer {
+			t.Errorf("Expected a new layer, Got: %v", replica)
+		}
+
+		var params, replicaParams []Param
+		if t, ok := layer.(trainable); ok {
+			params = t.params()
+			replicaParams = replica.(trainable).params()
+		}
+		for i := range params {
+			// the values are shared, the gradients are not
+			params[i].Value[0] = 42
+			if replicaParams[i].Value[0] != 42 {
+				t.Errorf("Expected: %v, Got: %v", 42, replicaParams[i].Value[0])
+			}
+			if &params[i].Gradient[0] == &replicaParams[i].Gradient[0] {
+				t.Error("Expected separate gradients")
+			}
+		}
+	}
+}
+
+func TestNewDenseRand(t *testing.T) {
+	first, _ := NewDense(3, 2, WithRand(rand.New(rand.NewSource(1))))
+	second, _ := NewDense(3, 2, WithRand(rand.New(rand.NewSource(1))))
+	other, _ := NewDense(3, 2, WithRand(rand.New(rand.NewSource(2))))
+
+	if !mat.Equal(&first.weights, &second.weights) || !mat.Equal(&first.bias, &second.bias) {
+		t.Errorf("Expected: %v, Got: %v", first.weights, second.weights)
+	}
+	if mat.Equal(&first.weights, &other.weights) {
+		t.Error("Expected different weights for different seeds")
+	}
+}
@@ -7,33 +7,112 @@ import (
 	"gonum.org/v1/gonum/mat"
 )
 
+// code of a built in loss function, see NewNetwork
+//
+// the codes have their own type, so they can't be mixed up with activation codes
+type LossCode int
+
 // Each constant represents an loss function and its derivative
 // This makes the definition of neural networks easier
 const (
-	LossMse = 0
-	LossMae = 1
+	LossMse                          LossCode = 0
+	LossMae                          LossCode = 1
+	LossCategoricalCrossEntropy      LossCode = 2
+	LossBinaryCrossEntropy           LossCode = 3
+	LossBinaryCrossEntropyFromLogits LossCode = 4
+	LossHuber                        LossCode = 5
+	LossHinge                        LossCode = 6
+	LossSquaredHinge                 LossCode = 7
+	LossLogCosh                      LossCode = 8
+	LossPoisson                      LossCode = 9
+	LossKLDivergence                 LossCode = 10
 )
 
+// predictions are clipped by this value before the logarithm is applied
+const lossEpsilon = 1e-12
+
+// errors smaller than this value are squared by the huber loss, larger errors are linear
+const huberDelta = 1.0
+
+// a loss function together with its derivative
+//
+// the name identifies the loss, e.g. inside the registry
+// custom losses can be passed directly to NewSequential or registered with RegisterLoss
+type Loss interface {
+	Name() string
+	Loss(yTrue, yPred mat.VecDense) (float64, error)
+	Derivative(yTrue, yPred mat.VecDense) (mat.VecDense, error)
+}
+
 // tuple of lossFunction and lossFunctionDerivative
 type lossTuple struct {
+	name           string
 	loss           lossFunc
 	lossDerivative lossFuncDerivative
 }
 
+func (t lossTuple) Name() string {
+	return t.name
+}
+
+func (t lossTuple) Loss(yTrue, yPred mat.VecDense) (float64, error) {
+	return t.loss(yTrue, yPred)
+}
+
+func (t lossTuple) Derivative(yTrue, yPred mat.VecDense) (mat.VecDense, error) {
+	return t.lossDerivative(yTrue, yPred)
+}
+
+// creates a Loss from a loss function and its derivative
+func NewLoss(
+	name string,
+	loss func(yTrue, yPred mat.VecDense) (float64, error),
+	lossDerivative func(yTrue, yPred mat.VecDense) (mat.VecDense, error),
+) Loss {
+	return lossTuple{name, loss, lossDerivative}
+}
+
+// all loss functions, the index is the specification number
+var lossTuples = []lossTuple{
+	LossMse:                          {"mse", Mse, MseDerivative},
+	LossMae:                          {"mae", Mae, MaeDerivative},
+	LossCategoricalCrossEntropy:      {"categorical_crossentropy", CategoricalCrossEntropy, CategoricalCrossEntropyDerivative},
+	LossBinaryCrossEntropy:           {"binary_crossentropy", BinaryCrossEntropy, BinaryCrossEntropyDerivative},
+	LossBinaryCrossEntropyFromLogits: {"binary_crossentropy_from_logits", BinaryCrossEntropyFromLogits, BinaryCrossEntropyFromLogitsDerivative},
+	LossHuber:                        {"huber", Huber, HuberDerivative},
+	LossHinge:                        {"hinge", Hinge, HingeDerivative},
+	LossSquaredHinge:                 {"squared_hinge", SquaredHinge, SquaredHingeDerivative},
+	LossLogCosh:                      {"log_cosh", LogCosh, LogCoshDerivative},
+	LossPoisson:                      {"poisson", Poisson, PoissonDerivative},
+	LossKLDivergence:                 {"kl_divergence", KLDivergence, KLDivergenceDerivative},
+}
+
+// the built in losses as typed values
+var (
+	MseLoss                          Loss = lossTuples[LossMse]
+	MaeLoss                          Loss = lossTuples[LossMae]
+	CategoricalCrossEntropyLoss      Loss = lossTuples[LossCategoricalCrossEntropy]
+	BinaryCrossEntropyLoss           Loss = lossTuples[LossBinaryCrossEntropy]
+	BinaryCrossEntropyFromLogitsLoss Loss = lossTuples[LossBinaryCrossEntropyFromLogits]
+	HuberLoss                        Loss = lossTuples[LossHuber]
+	HingeLoss                        Loss = lossTuples[LossHinge]
+	SquaredHingeLoss                 Loss = lossTuples[LossSquaredHinge]
+	LogCoshLoss                      Loss = lossTuples[LossLogCosh]
+	PoissonLoss                      Loss = lossTuples[LossPoisson]
+	KLDivergenceLoss                 Loss = lossTuples[LossKLDivergence]
+)
+
+// used by the network, when softmax and categorical cross entropy are fused
+var softmaxCrossEntropyLoss Loss = lossTuple{"softmax_crossentropy", SoftmaxCrossEntropy, SoftmaxCrossEntropyDerivative}
+
 // get function tuple based on specification number
-func getLossTuple(activationSpecs int) (lossTuple, error) {
+func getLossTuple(lossSpecs LossCode) (lossTuple, error) {
 	var funcs lossTuple
-	if activationSpecs < 0 || activationSpecs > 2 {
+	if lossSpecs < 0 || int(lossSpecs) >= len(lossTuples) {
 		return funcs, fmt.Errorf("wrong specification")
 	}
 
-	if activationSpecs == LossMse {
-		funcs = lossTuple{Mse, MseDerivative}
-		return funcs, nil
-	} else {
-		funcs = lossTuple{Mae, MaeDerivative}
-		return funcs, nil
-	}
+	return lossTuples[lossSpecs], nil
 }
 
 type lossFunc func(yTrue, yPred mat.VecDense) (float64, error)
@@ -84,6 +163,338 @@ func MaeDerivative(yTrue, yPred mat.VecDense) (mat.VecDense, error) {
 		return ans, fmt.Errorf("vectors need to have the same dimensions")
 	}
 
-	// TODO: add derivative of Mae
-	return yTrue, nil
+	ans = *mat.NewVecDense(yTrue.Len(), nil)
+	for i := 0; i < yTrue.Len(); i++ {
+		// the derivative of |x| is undefined at 0, 0 is used there
+		diff := yPred.AtVec(i) - yTrue.AtVec(i)
+		if diff > 0 {
+			ans.SetVec(i, 1/float64(yTrue.Len()))
+		} else if diff < 0 {
+			ans.SetVec(i, -1/float64(yTrue.Len()))
+		}
+	}
+	return ans, nil
+}
+
+// cross entropy for one hot encoded labels and predicted probabilities
+//
+// loss = -sum(yTrue * log(yPred))
+func CategoricalCrossEntropy(yTrue, yPred mat.VecDense) (float64, error) {
+	if yTrue.Len() != yPred.Len() {
+		return 0.0, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	var sum float64
+	for i := 0; i < yTrue.Len(); i++ {
+		sum -= yTrue.AtVec(i) * math.Log(math.Max(yPred.AtVec(i), lossEpsilon))
+	}
+
+	return sum, nil
+}
+
+func CategoricalCrossEntropyDerivative(yTrue, yPred mat.VecDense) (mat.VecDense, error) {
+	var ans mat.VecDense
+	if yTrue.Len() != yPred.Len() {
+		return ans, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	ans = *mat.NewVecDense(yTrue.Len(), nil)
+	for i := 0; i < yTrue.Len(); i++ {
+		ans.SetVec(i, -yTrue.AtVec(i)/math.Max(yPred.AtVec(i), lossEpsilon))
+	}
+	return ans, nil
+}
+
+// softmax followed by categorical cross entropy, calculated from the logits
+//
+// uses log-sum-exp, so large logits don't overflow:
+// loss = sum(yTrue) * logSumExp(logits) - sum(yTrue * logits)
+func SoftmaxCrossEntropy(yTrue, logits mat.VecDense) (float64, error) {
+	if yTrue.Len() != logits.Len() {
+		return 0.0, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	lse := logSumExp(mat.Col(nil, 0, &logits))
+	var sum float64
+	for i := 0; i < yTrue.Len(); i++ {
+		sum += yTrue.AtVec(i) * (lse - logits.AtVec(i))
+	}
+
+	return sum, nil
+}
+
+// the gradient of softmax and cross entropy combined simplifies to softmax(logits) - yTrue
+// this assumes that the labels sum up to 1
+func SoftmaxCrossEntropyDerivative(yTrue, logits mat.VecDense) (mat.VecDense, error) {
+	var ans mat.VecDense
+	if yTrue.Len() != logits.Len() {
+		return ans, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	ans = *mat.NewVecDense(yTrue.Len(), nil)
+	softmax(mat.Col(nil, 0, &logits), ans.RawVector().Data)
+	ans.SubVec(&ans, &yTrue)
+	return ans, nil
+}
+
+// cross entropy for independent binary labels and predicted probabilities
+// useful for multi label classification with a sigmoid output layer
+//
+// loss = -mean(yTrue * log(yPred) + (1 - yTrue) * log(1 - yPred))
+func BinaryCrossEntropy(yTrue, yPred mat.VecDense) (float64, error) {
+	if yTrue.Len() != yPred.Len() {
+		return 0.0, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	var sum float64
+	for i := 0; i < yTrue.Len(); i++ {
+		p := clipProbability(yPred.AtVec(i))
+		sum -= yTrue.AtVec(i)*math.Log(p) + (1-yTrue.AtVec(i))*math.Log(1-p)
+	}
+
+	return sum / float64(yTrue.Len()), nil
+}
+
+// clipped predictions get the derivative at the clip boundary, which stays finite,
+// so a sigmoid output that saturated on the wrong side still gets a gradient
+func BinaryCrossEntropyDerivative(yTrue, yPred mat.VecDense) (mat.VecDense, error) {
+	var ans mat.VecDense
+	if yTrue.Len() != yPred.Len() {
+		return ans, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	ans = *mat.NewVecDense(yTrue.Len(), nil)
+	for i := 0; i < yTrue.Len(); i++ {
+		p := clipProbability(yPred.AtVec(i))
+		ans.SetVec(i, (p-yTrue.AtVec(i))/(p*(1-p))/float64(yTrue.Len()))
+	}
+	return ans, nil
+}
+
+// binary cross entropy calculated from the logits instead of probabilities
+// the sigmoid is part of the loss, so the output layer shouldn't use an activation function
+//
+// loss = mean(max(x, 0) - x * yTrue + log(1 + exp(-|x|)))
+func BinaryCrossEntropyFromLogits(yTrue, logits mat.VecDense) (float64, error) {
+	if yTrue.Len() != logits.Len() {
+		return 0.0, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	var sum float64
+	for i := 0; i < yTrue.Len(); i++ {
+		x := logits.AtVec(i)
+		sum += math.Max(x, 0) - x*yTrue.AtVec(i) + math.Log1p(math.Exp(-math.Abs(x)))
+	}
+
+	return sum / float64(yTrue.Len()), nil
+}
+
+func BinaryCrossEntropyFromLogitsDerivative(yTrue, logits mat.VecDense) (mat.VecDense, error) {
+	var ans mat.VecDense
+	if yTrue.Len() != logits.Len() {
+		return ans, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	ans = *mat.NewVecDense(yTrue.Len(), nil)
+	for i := 0; i < yTrue.Len(); i++ {
+		ans.SetVec(i, (Sigmoid(logits.AtVec(i))-yTrue.AtVec(i))/float64(yTrue.Len()))
+	}
+	return ans, nil
+}
+
+// huber loss, also known as smooth L1 loss
+//
+// errors smaller than 1 are squared like mse, larger errors are linear like mae,
+// which makes the loss less sensitive to outliers
+func Huber(yTrue, yPred mat.VecDense) (float64, error) {
+	if yTrue.Len() != yPred.Len() {
+		return 0.0, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	var sum float64
+	for i := 0; i < yTrue.Len(); i++ {
+		diff := math.Abs(yPred.AtVec(i) - yTrue.AtVec(i))
+		if diff <= huberDelta {
+			sum += 0.5 * diff * diff
+		} else {
+			sum += huberDelta * (diff - 0.5*huberDelta)
+		}
+	}
+
+	return sum / float64(yTrue.Len()), nil
+}
+
+func HuberDerivative(yTrue, yPred mat.VecDense) (mat.VecDense, error) {
+	var ans mat.VecDense
+	if yTrue.Len() != yPred.Len() {
+		return ans, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	ans = *mat.NewVecDense(yTrue.Len(), nil)
+	for i := 0; i < yTrue.Len(); i++ {
+		diff := yPred.AtVec(i) - yTrue.AtVec(i)
+		diff = math.Max(-huberDelta, math.Min(huberDelta, diff))
+		ans.SetVec(i, diff/float64(yTrue.Len()))
+	}
+	return ans, nil
+}
+
+// hinge loss for labels that are -1 or 1
+//
+// loss = mean(max(0, 1 - yTrue * yPred))
+func Hinge(yTrue, yPred mat.VecDense) (float64, error) {
+	if yTrue.Len() != yPred.Len() {
+		return 0.0, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	var sum float64
+	for i := 0; i < yTrue.Len(); i++ {
+		sum += math.Max(0, 1-yTrue.AtVec(i)*yPred.AtVec(i))
+	}
+
+	return sum / float64(yTrue.Len()), nil
+}
+
+func HingeDerivative(yTrue, yPred mat.VecDense) (mat.VecDense, error) {
+	var ans mat.VecDense
+	if yTrue.Len() != yPred.Len() {
+		return ans, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	ans = *mat.NewVecDense(yTrue.Len(), nil)
+	for i := 0; i < yTrue.Len(); i++ {
+		if 1-yTrue.AtVec(i)*yPred.AtVec(i) > 0 {
+			ans.SetVec(i, -yTrue.AtVec(i)/float64(yTrue.Len()))
+		}
+	}
+	return ans, nil
+}
+
+// squared hinge loss for labels that are -1 or 1
+//
+// loss = mean(max(0, 1 - yTrue * yPred)^2)
+func SquaredHinge(yTrue, yPred mat.VecDense) (float64, error) {
+	if yTrue.Len() != yPred.Len() {
+		return 0.0, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	var sum float64
+	for i := 0; i < yTrue.Len(); i++ {
+		sum += math.Pow(math.Max(0, 1-yTrue.AtVec(i)*yPred.AtVec(i)), 2)
+	}
+
+	return sum / float64(yTrue.Len()), nil
+}
+
+func SquaredHingeDerivative(yTrue, yPred mat.VecDense) (mat.VecDense, error) {
+	var ans mat.VecDense
+	if yTrue.Len() != yPred.Len() {
+		return ans, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	ans = *mat.NewVecDense(yTrue.Len(), nil)
+	for i := 0; i < yTrue.Len(); i++ {
+		margin := math.Max(0, 1-yTrue.AtVec(i)*yPred.AtVec(i))
+		ans.SetVec(i, -2*yTrue.AtVec(i)*margin/float64(yTrue.Len()))
+	}
+	return ans, nil
+}
+
+// logarithm of the hyperbolic cosine of the error
+//
+// behaves like mse for small errors and like mae for large errors
+// log(cosh(x)) is calculated as |x| + log(1 + exp(-2|x|)) - log(2) to prevent overflows
+func LogCosh(yTrue, yPred mat.VecDense) (float64, error) {
+	if yTrue.Len() != yPred.Len() {
+		return 0.0, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	var sum float64
+	for i := 0; i < yTrue.Len(); i++ {
+		diff := math.Abs(yPred.AtVec(i) - yTrue.AtVec(i))
+		sum += diff + math.Log1p(math.Exp(-2*diff)) - math.Ln2
+	}
+
+	return sum / float64(yTrue.Len()), nil
+}
+
+func LogCoshDerivative(yTrue, yPred mat.VecDense) (mat.VecDense, error) {
+	var ans mat.VecDense
+	if yTrue.Len() != yPred.Len() {
+		return ans, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	ans = *mat.NewVecDense(yTrue.Len(), nil)
+	for i := 0; i < yTrue.Len(); i++ {
+		ans.SetVec(i, math.Tanh(yPred.AtVec(i)-yTrue.AtVec(i))/float64(yTrue.Len()))
+	}
+	return ans, nil
+}
+
+// poisson loss for count data, the predictions should be positive
+//
+// loss = mean(yPred - yTrue * log(yPred))
+func Poisson(yTrue, yPred mat.VecDense) (float64, error) {
+	if yTrue.Len() != yPred.Len() {
+		return 0.0, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	var sum float64
+	for i := 0; i < yTrue.Len(); i++ {
+		sum += yPred.AtVec(i) - yTrue.AtVec(i)*math.Log(math.Max(yPred.AtVec(i), lossEpsilon))
+	}
+
+	return sum / float64(yTrue.Len()), nil
+}
+
+func PoissonDerivative(yTrue, yPred mat.VecDense) (mat.VecDense, error) {
+	var ans mat.VecDense
+	if yTrue.Len() != yPred.Len() {
+		return ans, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	ans = *mat.NewVecDense(yTrue.Len(), nil)
+	for i := 0; i < yTrue.Len(); i++ {
+		ans.SetVec(i, (1-yTrue.AtVec(i)/math.Max(yPred.AtVec(i), lossEpsilon))/float64(yTrue.Len()))
+	}
+	return ans, nil
+}
+
+// kullback-leibler divergence between the label distribution and the predicted distribution
+//
+// loss = sum(yTrue * log(yTrue / yPred)), targets of 0 don't contribute to the loss
+func KLDivergence(yTrue, yPred mat.VecDense) (float64, error) {
+	if yTrue.Len() != yPred.Len() {
+		return 0.0, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	var sum float64
+	for i := 0; i < yTrue.Len(); i++ {
+		t := yTrue.AtVec(i)
+		if t == 0 {
+			continue
+		}
+		sum += t * math.Log(t/clipProbability(yPred.AtVec(i)))
+	}
+
+	return sum, nil
+}
+
+func KLDivergenceDerivative(yTrue, yPred mat.VecDense) (mat.VecDense, error) {
+	var ans mat.VecDense
+	if yTrue.Len() != yPred.Len() {
+		return ans, fmt.Errorf("vectors need to have the same dimensions")
+	}
+
+	ans = *mat.NewVecDense(yTrue.Len(), nil)
+	for i := 0; i < yTrue.Len(); i++ {
+		ans.SetVec(i, -yTrue.AtVec(i)/clipProbability(yPred.AtVec(i)))
+	}
+	return ans, nil
+}
+
+// clips the probability to [lossEpsilon, 1 - lossEpsilon],
+// so that the logarithm and the divisions stay finite
+func clipProbability(p float64) float64 {
+	return math.Max(lossEpsilon, math.Min(1-lossEpsilon, p))
 }
@@ -1,6 +1,7 @@
 package nngo
 
 import (
+	"math"
 	"math/rand"
 	"testing"
 
@@ -193,3 +194,304 @@ func TestMAEError(t *testing.T) {
 		t.Error("Expected error")
 	}
 }
+
+func TestGetLossTupleCategoricalCrossEntropy(t *testing.T) {
+	tuple, err := getLossTuple(LossCategoricalCrossEntropy)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	yTrue := mat.NewVecDense(2, []float64{0, 1})
+	yPred := mat.NewVecDense(2, []float64{0.5, 0.5})
+	expected, _ := CategoricalCrossEntropy(*yTrue, *yPred)
+	ans, _ := tuple.loss(*yTrue, *yPred)
+	if expected != ans {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+
+	if _, err := getLossTuple(100); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestCategoricalCrossEntropy(t *testing.T) {
+	yTrue := mat.NewVecDense(3, []float64{0, 1, 0})
+
+	t.Run("Normal", func(t *testing.T) {
+		yPred := mat.NewVecDense(3, []float64{0.2, 0.5, 0.3})
+		ans, err := CategoricalCrossEntropy(*yTrue, *yPred)
+		if err != nil || math.Abs(ans-math.Log(2)) > 1e-12 {
+			t.Errorf("Expected: %v, Got: %v", math.Log(2), ans)
+		}
+	})
+
+	t.Run("ZeroPrediction", func(t *testing.T) {
+		yPred := mat.NewVecDense(3, []float64{0.5, 0, 0.5})
+		ans, err := CategoricalCrossEntropy(*yTrue, *yPred)
+		if err != nil || math.IsInf(ans, 0) {
+			t.Errorf("Expected finite loss, Got: %v", ans)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		yPred := mat.NewVecDense(2, []float64{0.5, 0.5})
+		if _, err := CategoricalCrossEntropy(*yTrue, *yPred); err == nil {
+			t.Error("Expected error")
+		}
+	})
+}
+
+func TestCategoricalCrossEntropyDerivative(t *testing.T) {
+	yTrue := mat.NewVecDense(3, []float64{0, 1, 0})
+	yPred := mat.NewVecDense(3, []float64{0.2, 0.5, 0.3})
+
+	ans, err := CategoricalCrossEntropyDerivative(*yTrue, *yPred)
+	expected := mat.NewVecDense(3, []float64{0, -2, 0})
+	if err != nil || !mat.Equal(&ans, expected) {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+
+	if _, err := CategoricalCrossEntropyDerivative(*yTrue, *mat.NewVecDense(2, nil)); err == nil {
+		t.Error("Expected error")
+	}
+}
+
+func TestSoftmaxCrossEntropy(t *testing.T) {
+	yTrue := mat.NewVecDense(3, []float64{0, 1, 0})
+
+	t.Run("MatchesCategoricalCrossEntropy", func(t *testing.T) {
+		logits := mat.NewVecDense(3, []float64{1, 2, 3})
+		probabilities := mat.NewVecDense(3, nil)
+		softmax(logits.RawVector().Data, probabilities.RawVector().Data)
+
+		expected, _ := CategoricalCrossEntropy(*yTrue, *probabilities)
+		ans, err := SoftmaxCrossEntropy(*yTrue, *logits)
+		if err != nil || math.Abs(ans-expected) > 1e-12 {
+			t.Errorf("Expected: %v, Got: %v", expected, ans)
+		}
+	})
+
+	t.Run("LargeLogits", func(t *testing.T) {
+		logits := mat.NewVecDense(3, []float64{1000, 0, -1000})
+		ans, err := SoftmaxCrossEntropy(*yTrue, *logits)
+		if err != nil || math.Abs(ans-1000) > 1e-9 {
+			t.Errorf("Expected: %v, Got: %v", 1000, ans)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		if _, err := SoftmaxCrossEntropy(*yTrue, *mat.NewVecDense(2, nil)); err == nil {
+			t.Error("Expected error")
+		}
+	})
+}
+
+func TestSoftmaxCrossEntropyDerivative(t *testing.T) {
+	yTrue := mat.NewVecDense(3, []float64{0, 1, 0})
+	logits := mat.NewVecDense(3, []float64{1, 2, 3})
+
+	// the fused gradient has to match the gradient of cross entropy propagated through softmax
+	layer, _ := NewSoftmax(3)
+	probabilities := layer.forward(*mat.NewDense(3, 1, logits.RawVector().Data))
+	crossEntropyGradient, _ := CategoricalCrossEntropyDerivative(*yTrue, GetColVector(probabilities, 0))
+	expected := layer.backward(*mat.NewDense(3, 1, crossEntropyGradient.RawVector().Data))
+
+	ans, err := SoftmaxCrossEntropyDerivative(*yTrue, *logits)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if math.Abs(ans.AtVec(i)-expected.At(i, 0)) > 1e-12 {
+			t.Errorf("Expected: %v, Got: %v", expected.At(i, 0), ans.AtVec(i))
+		}
+	}
+
+	if _, err := SoftmaxCrossEntropyDerivative(*yTrue, *mat.NewVecDense(2, nil)); err == nil {
+		t.Error("Expected error")
+	}
+}
+
+func TestMAEDerivative(t *testing.T) {
+	a := mat.NewVecDense(4, []float64{1, 2, 3, 4})
+	b := mat.NewVecDense(4, []float64{2, 1, 3, 4})
+
+	ans, err := MaeDerivative(*a, *b)
+	expected := mat.NewVecDense(4, []float64{0.25, -0.25, 0, 0})
+	if err != nil || !mat.Equal(&ans, expected) {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+
+	if _, err := MaeDerivative(*a, *mat.NewVecDense(2, nil)); err == nil {
+		t.Error("Expected error")
+	}
+}
+
+// compares the derivative of the loss with the numerical derivative
+func checkLossDerivative(t *testing.T, tuple lossTuple, yTrue, yPred *mat.VecDense) {
+	t.Helper()
+	const h = 1e-6
+
+	ans, err := tuple.lossDerivative(*yTrue, *yPred)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	for i := 0; i < yPred.Len(); i++ {
+		value := yPred.AtVec(i)
+		yPred.SetVec(i, value+h)
+		plus, _ := tuple.loss(*yTrue, *yPred)
+		yPred.SetVec(i, value-h)
+		minus, _ := tuple.loss(*yTrue, *yPred)
+		yPred.SetVec(i, value)
+
+		numeric := (plus - minus) / (2 * h)
+		if math.Abs(ans.AtVec(i)-numeric) > 1e-5 {
+			t.Errorf("Expected: %v, Got: %v", numeric, ans.AtVec(i))
+		}
+	}
+}
+
+func TestLossDerivatives(t *testing.T) {
+	probabilities := []float64{0.2, 0.7, 0.4}
+	tests := []struct {
+		name  string
+		specs LossCode
+		yTrue []float64
+		yPred []float64
+	}{
+		{"MSE", LossMse, []float64{1, 0, 2}, []float64{0.5, 0.3, -1}},
+		{"MAE", LossMae, []float64{1, 0, 2}, []float64{0.5, 0.3, -1}},
+		{"CategoricalCrossEntropy", LossCategoricalCrossEntropy, []float64{0, 1, 0}, probabilities},
+		{"BinaryCrossEntropy", LossBinaryCrossEntropy, []float64{0, 1, 1}, probabilities},
+		{"BinaryCrossEntropyFromLogits", LossBinaryCrossEntropyFromLogits, []float64{0, 1, 1}, []float64{-2, 0.5, 3}},
+		{"Huber", LossHuber, []float64{1, 0, 2}, []float64{0.5, 3, -1}},
+		{"Hinge", LossHinge, []float64{1, -1, 1}, []float64{0.5, 0.3, 2}},
+		{"SquaredHinge", LossSquaredHinge, []float64{1, -1, 1}, []float64{0.5, 0.3, 2}},
+		{"LogCosh", LossLogCosh, []float64{1, 0, 2}, []float64{0.5, 3, -1}},
+		{"Poisson", LossPoisson, []float64{1, 0, 2}, []float64{0.5, 3, 1}},
+		{"KLDivergence", LossKLDivergence, []float64{0.1, 0.6, 0.3}, probabilities},
+		{"KLDivergenceZeroTarget", LossKLDivergence, []float64{0, 0.6, 0.4}, probabilities},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tuple, err := getLossTuple(test.specs)
+			if err != nil {
+				t.Errorf("Didn't expect error. Got: %v", err)
+			}
+
+			yTrue := mat.NewVecDense(3, append([]float64(nil), test.yTrue...))
+			yPred := mat.NewVecDense(3, append([]float64(nil), test.yPred...))
+			checkLossDerivative(t, tuple, yTrue, yPred)
+
+			if _, err := tuple.loss(*yTrue, *mat.NewVecDense(2, nil)); err == nil {
+				t.Error("Expected error")
+			}
+			if _, err := tuple.lossDerivative(*yTrue, *mat.NewVecDense(2, nil)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestBinaryCrossEntropy(t *testing.T) {
+	yTrue := mat.NewVecDense(2, []float64{1, 0})
+	yPred := mat.NewVecDense(2, []float64{0.5, 0.5})
+
+	ans, err := BinaryCrossEntropy(*yTrue, *yPred)
+	if err != nil || math.Abs(ans-math.Log(2)) > 1e-12 {
+		t.Errorf("Expected: %v, Got: %v", math.Log(2), ans)
+	}
+
+	// the loss from logits has to match the loss of the sigmoid probabilities
+	logits := mat.NewVecDense(2, []float64{2, -1})
+	probabilities := mat.NewVecDense(2, []float64{Sigmoid(2), Sigmoid(-1)})
+	expected, _ := BinaryCrossEntropy(*yTrue, *probabilities)
+	ans, err = BinaryCrossEntropyFromLogits(*yTrue, *logits)
+	if err != nil || math.Abs(ans-expected) > 1e-12 {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+
+	// large logits don't overflow
+	ans, err = BinaryCrossEntropyFromLogits(*yTrue, *mat.NewVecDense(2, []float64{-1000, 1000}))
+	if err != nil || math.Abs(ans-1000) > 1e-9 {
+		t.Errorf("Expected: %v, Got: %v", 1000, ans)
+	}
+
+	// saturated wrong predictions get the finite gradient of the clip boundary, which points to the label
+	gradient, err := BinaryCrossEntropyDerivative(*yTrue, *mat.NewVecDense(2, []float64{0, 1}))
+	if err != nil || !(gradient.AtVec(0) < 0) || !(gradient.AtVec(1) > 0) ||
+		math.IsInf(gradient.AtVec(0), 0) || math.IsInf(gradient.AtVec(1), 0) {
+		t.Errorf("Expected a finite gradient towards the labels, Got: %v", gradient.RawVector().Data)
+	}
+}
+
+func TestHuber(t *testing.T) {
+	yTrue := mat.NewVecDense(2, []float64{0, 0})
+	yPred := mat.NewVecDense(2, []float64{0.5, 3})
+
+	// 0.5 * 0.5^2 for the small error and 3 - 0.5 for the large error
+	expected := (0.125 + 2.5) / 2
+	ans, err := Huber(*yTrue, *yPred)
+	if err != nil || math.Abs(ans-expected) > 1e-12 {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+}
+
+func TestHinge(t *testing.T) {
+	yTrue := mat.NewVecDense(2, []float64{1, -1})
+	yPred := mat.NewVecDense(2, []float64{2, 0.5})
+
+	ans, err := Hinge(*yTrue, *yPred)
+	if err != nil || ans != 0.75 {
+		t.Errorf("Expected: %v, Got: %v", 0.75, ans)
+	}
+
+	ans, err = SquaredHinge(*yTrue, *yPred)
+	if err != nil || ans != 1.125 {
+		t.Errorf("Expected: %v, Got: %v", 1.125, ans)
+	}
+}
+
+func TestLogCosh(t *testing.T) {
+	yTrue := mat.NewVecDense(2, []float64{0, 0})
+	yPred := mat.NewVecDense(2, []float64{0.5, 1000})
+
+	expected := (math.Log(math.Cosh(0.5)) + 1000 - math.Ln2) / 2
+	ans, err := LogCosh(*yTrue, *yPred)
+	if err != nil || math.Abs(ans-expected) > 1e-9 {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+}
+
+func TestPoisson(t *testing.T) {
+	yTrue := mat.NewVecDense(2, []float64{1, 2})
+	yPred := mat.NewVecDense(2, []float64{1, 2})
+
+	expected := (1 + 2 - 2*math.Log(2)) / 2
+	ans, err := Poisson(*yTrue, *yPred)
+	if err != nil || math.Abs(ans-expected) > 1e-12 {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+}
+
+func TestKLDivergence(t *testing.T) {
+	yTrue := mat.NewVecDense(2, []float64{0.5, 0.5})
+
+	ans, err := KLDivergence(*yTrue, *yTrue)
+	if err != nil || math.Abs(ans) > 1e-12 {
+		t.Errorf("Expected: %v, Got: %v", 0, ans)
+	}
+
+	expected := 0.5*math.Log(0.5/0.25) + 0.5*math.Log(0.5/0.75)
+	ans, err = KLDivergence(*yTrue, *mat.NewVecDense(2, []float64{0.25, 0.75}))
+	if err != nil || math.Abs(ans-expected) > 1e-12 {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+
+	// targets of 0 don't contribute
+	ans, err = KLDivergence(*mat.NewVecDense(2, []float64{0, 1}), *mat.NewVecDense(2, []float64{0.5, 0.5}))
+	if err != nil || math.Abs(ans-math.Log(2)) > 1e-12 {
+		t.Errorf("Expected: %v, Got: %v", math.Log(2), ans)
+	}
+}
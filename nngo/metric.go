@@ -0,0 +1,95 @@
+package nngo
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// a metric rates the predictions of the network for a whole set, e.g. the accuracy
+//
+// each column of labels and predictions is one sample
+// metrics are only reported and don't influence the training
+type Metric interface {
+	Name() string
+	Evaluate(labels, predictions mat.Dense) float64
+}
+
+// tuple of a name and a metric function
+type metricTuple struct {
+	name   string
+	metric func(labels, predictions mat.Dense) float64
+}
+
+func (t metricTuple) Name() string {
+	return t.name
+}
+
+func (t metricTuple) Evaluate(labels, predictions mat.Dense) float64 {
+	return t.metric(labels, predictions)
+}
+
+// creates a Metric from a metric function
+func NewMetric(name string, metric func(labels, predictions mat.Dense) float64) Metric {
+	return metricTuple{name, metric}
+}
+
+// the built in metrics
+var (
+	AccuracyMetric       Metric = metricTuple{"accuracy", Accuracy}
+	BinaryAccuracyMetric Metric = metricTuple{"binary_accuracy", BinaryAccuracy}
+	MaeMetric            Metric = metricTuple{"mae", MeanAbsoluteError}
+	RmseMetric           Metric = metricTuple{"rmse", RootMeanSquaredError}
+)
+
+// fraction of samples, where the index of the highest prediction matches the index of the highest label
+// this only works for one hot encoded labels
+func Accuracy(labels, predictions mat.Dense) float64 {
+	_, cols := predictions.Dims()
+	correct := 0.0
+	for j := 0; j < cols; j++ {
+		if GetMaxIndex(GetColVector(predictions, j)) == GetMaxIndex(GetColVector(labels, j)) {
+			correct++
+		}
+	}
+	return correct / float64(cols)
+}
+
+// fraction of outputs, where the prediction is on the same side of 0.5 as the label
+func BinaryAccuracy(labels, predictions mat.Dense) float64 {
+	rows, cols := predictions.Dims()
+	correct := 0.0
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			if (predictions.At(i, j) >= 0.5) == (labels.At(i, j) >= 0.5) {
+				correct++
+			}
+		}
+	}
+	return correct / float64(rows*cols)
+}
+
+// mean absolute difference over all outputs
+func MeanAbsoluteError(labels, predictions mat.Dense) float64 {
+	rows, cols := predictions.Dims()
+	sum := 0.0
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			sum += math.Abs(labels.At(i, j) - predictions.At(i, j))
+		}
+	}
+	return sum / float64(rows*cols)
+}
+
+// square root of the mean squared difference over all outputs
+func RootMeanSquaredError(labels, predictions mat.Dense) float64 {
+	rows, cols := predictions.Dims()
+	sum := 0.0
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			diff := labels.At(i, j) - predictions.At(i, j)
+			sum += diff * diff
+		}
+	}
+	return math.Sqrt(sum / float64(rows*cols))
+}
@@ -0,0 +1,43 @@
+package nngo
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestMetrics(t *testing.T) {
+	labels := mat.NewDense(2, 4, []float64{1, 0, 1, 0, 0, 1, 0, 1})
+	predictions := mat.NewDense(2, 4, []float64{0.8, 0.4, 0.3, 0, 0.2, 0.6, 0.7, 1})
+
+	tests := []struct {
+		metric   Metric
+		expected float64
+	}{
+		{AccuracyMetric, 0.75},
+		{BinaryAccuracyMetric, 0.75},
+		{MaeMetric, 0.325},
+		{RmseMetric, math.Sqrt(0.1725)},
+	}
+
+	for _, test := range tests {
+		ans := test.metric.Evaluate(*labels, *predictions)
+		if math.Abs(ans-test.expected) > 1e-12 {
+			t.Errorf("%v Expected: %v, Got: %v", test.metric.Name(), test.expected, ans)
+		}
+	}
+}
+
+func TestNewMetric(t *testing.T) {
+	metric := NewMetric("first", func(labels, predictions mat.Dense) float64 {
+		return predictions.At(0, 0)
+	})
+
+	if metric.Name() != "first" {
+		t.Errorf("Expected: %v, Got: %v", "first", metric.Name())
+	}
+	if ans := metric.Evaluate(*mat.NewDense(1, 1, []float64{0}), *mat.NewDense(1, 1, []float64{3})); ans != 3 {
+		t.Errorf("Expected: %v, Got: %v", 3, ans)
+	}
+}
@@ -0,0 +1,557 @@
+package nngo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// saved models start with the magic bytes followed by the version of the format
+// the rest of the file is the gob encoded modelState
+//
+// the version has to be increased, when the format changes in an incompatible way
+var modelMagic = [4]byte{'n', 'n', 'g', 'o'}
+
+const modelVersion uint16 = 1
+
+// serialised form of a network
+type modelState struct {
+	Loss   string
+	Layers []layerState
+}
+
+// serialised form of a layer
+//
+// Shape holds the sizes that are needed to construct the layer
+// Config holds hyperparameters, e.g. alpha of LeakyRelu
+// Constraint holds the name of the constraint of dense layers, its value is part of Config
+// Params holds the learned parameters in the same order as params() of the layer
+// Buffers holds the values of stateful layers, e.g. the running statistics of BatchNorm
+type layerState struct {
+	Type       string
+	Shape      []int
+	Activation string
+	Config     []float64
+	Constraint string
+	Params     [][]float64
+	Buffers    [][]float64
+	// states of the layers inside of a wrapper, e.g. Bidirectional
+	Layers []layerState
+}
+
+// creates a layer from its serialised form, the parameters are restored afterwards
+var layerLoaders = map[string]func(state layerState) (Layer, error){
+	"dense":                       loadDense,
+	"activation":                  loadActivation,
+	"softmax":                     loadSoftmax,
+	"dropout":                     loadDropout,
+	"alpha_dropout":               loadAlphaDropout,
+	"gaussian_noise":              loadGaussianNoise,
+	"batch_norm":                  loadBatchNorm,
+	"layer_norm":                  loadLayerNorm,
+	"conv2d":                      loadConv2D,
+	"max_pool2d":                  loadMaxPool2D,
+	"avg_pool2d":                  loadAvgPool2D,
+	"global_average_pooling":      loadGlobalAveragePooling,
+	"flatten":                     loadFlatten,
+	"conv1d":                      loadConv1D,
+	"max_pool1d":                  loadMaxPool1D,
+	"avg_pool1d":                  loadAvgPool1D,
+	"simple_rnn":                  loadRecurrent,
+	"lstm":                        loadRecurrent,
+	"gru":                         loadRecurrent,
+	"bidirectional":               loadBidirectional,
+	"embedding":                   loadEmbedding,
+	"multi_head_attention":        loadMultiHeadAttention,
+	"positional_encoding":         loadPositionalEncoding,
+	"learned_positional_encoding": loadLearnedPositionalEncoding,
+	"transformer_encoder_block":   loadTransformerEncoderBlock,
+}
+
+// writes the architecture, the loss and all parameters of the network to w
+//
+// losses and activation functions are saved by their name,
+// so custom functions need to be registered before the model can be loaded
+// the optimizer is not saved
+func (dense *Network) Save(w io.Writer) error {
+	state, err := dense.state()
+	if err != nil {
+		return err
+	}
+
+	return writeGob(w, modelMagic, modelVersion, state)
+}
+
+// reads a network that was written by Save
+//
+// the loaded network uses the default optimizer
+func Load(r io.Reader) (*Network, error) {
+	var state modelState
+	if err := readGob(r, modelMagic, modelVersion, "model", &state); err != nil {
+		return nil, err
+	}
+	return networkFromState(state)
+}
+
+// writes the magic bytes, the version and the gob encoded value to w
+func writeGob(w io.Writer, magic [4]byte, version uint16, value interface{}) error {
+	buffered := bufio.NewWriter(w)
+	if _, err := buffered.Write(magic[:]); err != nil {
+		return err
+	}
+	if err := binary.Write(buffered, binary.BigEndian, version); err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(buffered).Encode(value); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+// checks the magic bytes and the version and decodes the rest of r into value
+// kind names the kind of file in error messages
+func readGob(r io.Reader, magic [4]byte, version uint16, kind string, value interface{}) error {
+	var readMagic [4]byte
+	if _, err := io.ReadFull(r, readMagic[:]); err != nil {
+		return fmt.Errorf("unable to read %v header: %w", kind, err)
+	}
+	if readMagic != magic {
+		return fmt.Errorf("input is not a nngo %v", kind)
+	}
+
+	var readVersion uint16
+	if err := binary.Read(r, binary.BigEndian, &readVersion); err != nil {
+		return fmt.Errorf("unable to read %v version: %w", kind, err)
+	}
+	if readVersion == 0 || readVersion > version {
+		return fmt.Errorf("unsupported %v version %v", kind, readVersion)
+	}
+
+	if err := gob.NewDecoder(r).Decode(value); err != nil {
+		return fmt.Errorf("unable to decode %v: %w", kind, err)
+	}
+	return nil
+}
+
+// collects the serialised form of the network
+func (dense *Network) state() (modelState, error) {
+	if _, err := LookupLoss(dense.loss.Name()); err != nil {
+		return modelState{}, err
+	}
+
+	state := modelState{Loss: dense.loss.Name()}
+	for _, layer := range dense.layers {
+		layerState := layer.state()
+		if layerState.Activation != "" {
+			if _, err := LookupActivation(layerState.Activation); err != nil {
+				return modelState{}, err
+			}
+		}
+		if t, ok := layer.(trainable); ok {
+			for _, p := range t.params() {
+				layerState.Params = append(layerState.Params, append([]float64(nil), p.Value...))
+			}
+		}
+		if st, ok := layer.(stateful); ok {
+			for _, buffer := range st.buffers() {
+				layerState.Buffers = append(layerState.Buffers, append([]float64(nil), buffer...))
+			}
+		}
+		state.Layers = append(state.Layers, layerState)
+	}
+	return state, nil
+}
+
+// creates a network from its serialised form
+func networkFromState(state modelState) (*Network, error) {
+	loss, err := LookupLoss(state.Loss)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := make([]Layer, len(state.Layers))
+	for i, layerState := range state.Layers {
+		loader, ok := layerLoaders[layerState.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown layer type %q", layerState.Type)
+		}
+
+		layer, err := loader(layerState)
+		if err != nil {
+			return nil, err
+		}
+		if err := restoreParams(layer, layerState.Params); err != nil {
+			return nil, fmt.Errorf("layer %v: %w", i, err)
+		}
+		if err := restoreBuffers(layer, layerState.Buffers); err != nil {
+			return nil, fmt.Errorf("layer %v: %w", i, err)
+		}
+		layers[i] = layer
+	}
+
+	return NewSequential(loss, layers...)
+}
+
+// copies the saved parameters into the layer
+func restoreParams(layer Layer, saved [][]float64) error {
+	var params []Param
+	if t, ok := layer.(trainable); ok {
+		params = t.params()
+	}
+
+	if len(params) != len(saved) {
+		return fmt.Errorf("expected %v parameters, got %v", len(params), len(saved))
+	}
+	for i, p := range params {
+		if len(p.Value) != len(saved[i]) {
+			return fmt.Errorf("parameter %v has size %v, expected %v", i, len(saved[i]), len(p.Value))
+		}
+		copy(p.Value, saved[i])
+	}
+	return nil
+}
+
+// copies the saved buffers into the layer
+func restoreBuffers(layer Layer, saved [][]float64) error {
+	var buffers [][]float64
+	if st, ok := layer.(stateful); ok {
+		buffers = st.buffers()
+	}
+
+	if len(buffers) != len(saved) {
+		return fmt.Errorf("expected %v buffers, got %v", len(buffers), len(saved))
+	}
+	for i, buffer := range buffers {
+		if len(buffer) != len(saved[i]) {
+			return fmt.Errorf("buffer %v has size %v, expected %v", i, len(saved[i]), len(buffer))
+		}
+		copy(buffer, saved[i])
+	}
+	return nil
+}
+
+// checks that the saved shape has the expected number of sizes
+func checkShape(state layerState, size int) error {
+	if len(state.Shape) != size {
+		return fmt.Errorf("%v layer needs %v sizes, got %v", state.Type, size, len(state.Shape))
+	}
+	return nil
+}
+
+func loadDense(state layerState) (Layer, error) {
+	if err := checkShape(state, 2); err != nil {
+		return nil, err
+	}
+	opts, err := regularizerOptions(state)
+	if err != nil {
+		return nil, err
+	}
+	return NewDense(state.Shape[0], state.Shape[1], opts...)
+}
+
+// returns the options for the regularizer and the constraint of the layer,
+// which are saved as {l1, l2, constraint value} in the config
+func regularizerOptions(state layerState) ([]Option, error) {
+	if len(state.Config) == 0 {
+		return nil, nil
+	}
+	if err := checkConfig(state, 3); err != nil {
+		return nil, err
+	}
+	constraint, err := constraintFromState(state.Constraint, state.Config[2])
+	if err != nil {
+		return nil, err
+	}
+	return []Option{WithRegularizer(ElasticNet(state.Config[0], state.Config[1])), WithConstraint(constraint)}, nil
+}
+
+func loadActivation(state layerState) (Layer, error) {
+	if err := checkShape(state, 1); err != nil {
+		return nil, err
+	}
+
+	function, err := LookupActivation(state.Activation)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := function.(configurableActivation); ok {
+		function, err = c.withConfig(state.Config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewActivationWith(state.Shape[0], function)
+}
+
+func loadSoftmax(state layerState) (Layer, error) {
+	if err := checkShape(state, 1); err != nil {
+		return nil, err
+	}
+	return NewSoftmax(state.Shape[0])
+}
+
+// checks that the saved config has the expected number of values
+func checkConfig(state layerState, size int) error {
+	if len(state.Config) != size {
+		return fmt.Errorf("%v layer needs %v config values, got %v", state.Type, size, len(state.Config))
+	}
+	return nil
+}
+
+func loadDropout(state layerState) (Layer, error) {
+	if err := checkShape(state, 1); err != nil {
+		return nil, err
+	}
+	if err := checkConfig(state, 1); err != nil {
+		return nil, err
+	}
+	return NewDropout(state.Shape[0], state.Config[0])
+}
+
+func loadAlphaDropout(state layerState) (Layer, error) {
+	if err := checkShape(state, 1); err != nil {
+		return nil, err
+	}
+	if err := checkConfig(state, 1); err != nil {
+		return nil, err
+	}
+	return NewAlphaDropout(state.Shape[0], state.Config[0])
+}
+
+func loadGaussianNoise(state layerState) (Layer, error) {
+	if err := checkShape(state, 1); err != nil {
+		return nil, err
+	}
+	if err := checkConfig(state, 1); err != nil {
+		return nil, err
+	}
+	return NewGaussianNoise(state.Shape[0], state.Config[0])
+}
+
+func loadBatchNorm(state layerState) (Layer, error) {
+	if err := checkShape(state, 1); err != nil {
+		return nil, err
+	}
+	if err := checkConfig(state, 2); err != nil {
+		return nil, err
+	}
+	return NewBatchNorm(state.Shape[0], state.Config[0], state.Config[1])
+}
+
+func loadLayerNorm(state layerState) (Layer, error) {
+	if err := checkShape(state, 1); err != nil {
+		return nil, err
+	}
+	if err := checkConfig(state, 1); err != nil {
+		return nil, err
+	}
+	return NewLayerNorm(state.Shape[0], state.Config[0])
+}
+
+func loadConv2D(state layerState) (Layer, error) {
+	if err := checkShape(state, geometrySizes+1); err != nil {
+		return nil, err
+	}
+	opts, err := regularizerOptions(state)
+	if err != nil {
+		return nil, err
+	}
+	return newConv2D(geometryFromState(state.Shape), state.Shape[geometrySizes], newOptions(opts))
+}
+
+func loadMaxPool2D(state layerState) (Layer, error) {
+	if err := checkShape(state, geometrySizes); err != nil {
+		return nil, err
+	}
+	geometry := geometryFromState(state.Shape)
+	if err := geometry.validate(); err != nil {
+		return nil, err
+	}
+	return &MaxPool2D{geometry: geometry, indices: geometry.indices()}, nil
+}
+
+func loadAvgPool2D(state layerState) (Layer, error) {
+	if err := checkShape(state, geometrySizes); err != nil {
+		return nil, err
+	}
+	geometry := geometryFromState(state.Shape)
+	if err := geometry.validate(); err != nil {
+		return nil, err
+	}
+	return &AvgPool2D{geometry: geometry, indices: geometry.indices()}, nil
+}
+
+func loadGlobalAveragePooling(state layerState) (Layer, error) {
+	if err := checkShape(state, 3); err != nil {
+		return nil, err
+	}
+	return NewGlobalAveragePooling(Shape{state.Shape[0], state.Shape[1], state.Shape[2]})
+}
+
+func loadFlatten(state layerState) (Layer, error) {
+	if err := checkShape(state, 3); err != nil {
+		return nil, err
+	}
+	return NewFlatten(Shape{state.Shape[0], state.Shape[1], state.Shape[2]})
+}
+
+func loadConv1D(state layerState) (Layer, error) {
+	conv, err := loadConv2D(state)
+	if err != nil {
+		return nil, err
+	}
+	return &Conv1D{conv.(*Conv2D)}, nil
+}
+
+func loadMaxPool1D(state layerState) (Layer, error) {
+	pool, err := loadMaxPool2D(state)
+	if err != nil {
+		return nil, err
+	}
+	return &MaxPool1D{pool.(*MaxPool2D)}, nil
+}
+
+func loadAvgPool1D(state layerState) (Layer, error) {
+	pool, err := loadAvgPool2D(state)
+	if err != nil {
+		return nil, err
+	}
+	return &AvgPool1D{pool.(*AvgPool2D)}, nil
+}
+
+// the shape is saved as {features, steps, units} and the config as {return sequences, truncation, reverse}
+func loadRecurrent(state layerState) (Layer, error) {
+	if _, ok := recurrentCells[state.Type]; !ok {
+		return nil, fmt.Errorf("unknown recurrent layer type %q", state.Type)
+	}
+	if err := checkShape(state, 3); err != nil {
+		return nil, err
+	}
+	if err := checkConfig(state, 3); err != nil {
+		return nil, err
+	}
+
+	opts := []Option{WithTruncatedBPTT(int(state.Config[1]))}
+	if state.Config[0] != 0 {
+		opts = append(opts, WithReturnSequences())
+	}
+	r, err := newRecurrent(state.Type, Signal(state.Shape[0], state.Shape[1]), state.Shape[2], newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	r.reverse = state.Config[2] != 0
+	return r, nil
+}
+
+func loadBidirectional(state layerState) (Layer, error) {
+	if len(state.Layers) != 2 {
+		return nil, fmt.Errorf("bidirectional layer needs 2 layers, got %v", len(state.Layers))
+	}
+	forward, err := loadRecurrent(state.Layers[0])
+	if err != nil {
+		return nil, err
+	}
+	backward, err := loadRecurrent(state.Layers[1])
+	if err != nil {
+		return nil, err
+	}
+	return NewBidirectional(forward.(*Recurrent), backward.(*Recurrent))
+}
+
+// the shape is saved as {vocabulary, dimension, length} and the config as {frozen, padding mask}
+func loadEmbedding(state layerState) (Layer, error) {
+	if err := checkShape(state, 3); err != nil {
+		return nil, err
+	}
+	if err := checkConfig(state, 2); err != nil {
+		return nil, err
+	}
+	e, err := NewEmbedding(state.Shape[0], state.Shape[1], state.Shape[2], WithWeightInit(Zeros))
+	if err != nil {
+		return nil, err
+	}
+	e.frozen = state.Config[0] != 0
+	e.paddingMask = state.Config[1] != 0
+	return e, nil
+}
+
+// returns WithPaddingMask, if the saved flag is set
+func paddingMaskOptions(flag float64) []Option {
+	if flag != 0 {
+		return []Option{WithPaddingMask()}
+	}
+	return nil
+}
+
+// the shape is saved as {features, steps, heads} and the config as {causal mask, padding mask},
+// followed by the values of the regularizer and the constraint, if the layer has them
+func loadMultiHeadAttention(state layerState) (Layer, error) {
+	if err := checkShape(state, 3); err != nil {
+		return nil, err
+	}
+	if len(state.Config) != 2 && len(state.Config) != 5 {
+		return nil, fmt.Errorf("%v layer needs 2 or 5 config values, got %v", state.Type, len(state.Config))
+	}
+	opts, err := regularizerOptions(layerState{Type: state.Type, Config: state.Config[2:], Constraint: state.Constraint})
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, paddingMaskOptions(state.Config[1])...)
+	if state.Config[0] != 0 {
+		opts = append(opts, WithCausalMask())
+	}
+	return NewMultiHeadAttention(Signal(state.Shape[0], state.Shape[1]), state.Shape[2], opts...)
+}
+
+func loadPositionalEncoding(state layerState) (Layer, error) {
+	if err := checkShape(state, 2); err != nil {
+		return nil, err
+	}
+	if err := checkConfig(state, 1); err != nil {
+		return nil, err
+	}
+	return NewPositionalEncoding(Signal(state.Shape[0], state.Shape[1]), paddingMaskOptions(state.Config[0])...)
+}
+
+func loadLearnedPositionalEncoding(state layerState) (Layer, error) {
+	if err := checkShape(state, 2); err != nil {
+		return nil, err
+	}
+	if err := checkConfig(state, 1); err != nil {
+		return nil, err
+	}
+	return NewLearnedPositionalEncoding(Signal(state.Shape[0], state.Shape[1]), paddingMaskOptions(state.Config[0])...)
+}
+
+// the inner layers are loaded from Layers in the order of TransformerEncoderBlock.layers
+func loadTransformerEncoderBlock(state layerState) (Layer, error) {
+	types := []string{"multi_head_attention", "layer_norm", "dense", "activation", "dense", "layer_norm"}
+	loaders := []func(state layerState) (Layer, error){
+		loadMultiHeadAttention, loadLayerNorm, loadDense, loadActivation, loadDense, loadLayerNorm,
+	}
+	if len(state.Layers) != len(types) {
+		return nil, fmt.Errorf("transformer encoder block needs %v layers, got %v", len(types), len(state.Layers))
+	}
+
+	layers := make([]Layer, len(types))
+	for i, layerState := range state.Layers {
+		if layerState.Type != types[i] {
+			return nil, fmt.Errorf("layer %v of transformer encoder block should be %v, got %v", i, types[i], layerState.Type)
+		}
+		layer, err := loaders[i](layerState)
+		if err != nil {
+			return nil, err
+		}
+		layers[i] = layer
+	}
+	return &TransformerEncoderBlock{
+		attention:  layers[0].(*MultiHeadAttention),
+		firstNorm:  layers[1].(*LayerNorm),
+		hidden:     layers[2].(*Dense),
+		activation: layers[3].(*Activation),
+		output:     layers[4].(*Dense),
+		secondNorm: layers[5].(*LayerNorm),
+	}, nil
+}
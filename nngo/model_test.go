@@ -0,0 +1,162 @@
+package nngo
+
+import (
+	"bytes"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// predicts the output of the network for the data and fails the test on an error
+func predictBatch(t *testing.T, network *Network, data mat.Dense) mat.Dense {
+	t.Helper()
+	output, err := network.PredictBatch(data)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	return output
+}
+
+// checks that both networks predict the same output for the data
+func checkSamePredictions(t *testing.T, expected, ans *Network, data mat.Dense) {
+	t.Helper()
+	expectedOutput := predictBatch(t, expected, data)
+	output := predictBatch(t, ans, data)
+	if !mat.Equal(&expectedOutput, &output) {
+		t.Errorf("Expected: %v, Got: %v", expectedOutput, output)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	data := mat.NewDense(3, 2, []float64{1, -2, 0.5, 3, -1, 2})
+
+	dense, _ := NewDense(3, 4)
+	leaky, _ := NewActivationWith(4, LeakyRelu{0.2})
+	hidden, _ := NewDense(4, 4)
+	prelu, _ := NewActivation(4, ActivationPRelu)
+	prelu.function.(*PRelu).alpha[0] = 0.1
+	output, _ := NewDense(4, 2)
+	softmax, _ := NewSoftmax(2)
+
+	network, err := NewSequential(CategoricalCrossEntropyLoss, dense, leaky, hidden, prelu, output, softmax)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := network.Save(&buffer); err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	loaded, err := Load(&buffer)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	checkSamePredictions(t, network, loaded, *data)
+
+	if loaded.loss.Name() != "categorical_crossentropy" || !loaded.fusedSoftmax {
+		t.Errorf("Expected fused categorical cross entropy, Got: %v", loaded.loss.Name())
+	}
+
+	if alpha := loaded.layers[1].(*Activation).function.(LeakyRelu).Alpha; alpha != 0.2 {
+		t.Errorf("Expected: %v, Got: %v", 0.2, alpha)
+	}
+
+	if alpha := loaded.layers[3].(*Activation).function.(*PRelu).Alpha(); alpha != 0.1 {
+		t.Errorf("Expected: %v, Got: %v", 0.1, alpha)
+	}
+}
+
+func TestSaveLoadNewNetwork(t *testing.T) {
+	network, err := NewNetwork([]LayerSpec{{2, 5, ActivationTanh}, {5, 1, ActivationSigmoid}}, LossBinaryCrossEntropy)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := network.Save(&buffer); err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	loaded, err := Load(&buffer)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	checkSamePredictions(t, network, loaded, *mat.NewDense(2, 3, []float64{0, 1, 2, 3, 4, 5}))
+}
+
+func TestSaveLoadCustomActivation(t *testing.T) {
+	square := NewActivationFunction("test_square", func(x float64) float64 { return x * x }, func(x float64) float64 { return 2 * x })
+
+	dense, _ := NewDense(2, 2)
+	activation, _ := NewActivationWith(2, square)
+	network, _ := NewSequential(MseLoss, dense, activation)
+
+	var buffer bytes.Buffer
+	if err := network.Save(&buffer); err == nil {
+		t.Error("Expected error for unregistered activation.")
+	}
+
+	if err := RegisterActivation(square); err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	buffer.Reset()
+	if err := network.Save(&buffer); err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	loaded, err := Load(&buffer)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	checkSamePredictions(t, network, loaded, *mat.NewDense(2, 2, []float64{1, 2, 3, 4}))
+}
+
+func TestLoadError(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		if _, err := Load(bytes.NewReader(nil)); err == nil {
+			t.Error("Expected error.")
+		}
+	})
+
+	t.Run("WrongMagic", func(t *testing.T) {
+		if _, err := Load(bytes.NewReader([]byte("abcd\x00\x01"))); err == nil {
+			t.Error("Expected error.")
+		}
+	})
+
+	t.Run("NewerVersion", func(t *testing.T) {
+		if _, err := Load(bytes.NewReader([]byte("nngo\xff\xff"))); err == nil {
+			t.Error("Expected error.")
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		network, _ := NewNetwork([]LayerSpec{{2, 2, 0}}, LossMse)
+		var buffer bytes.Buffer
+		_ = network.Save(&buffer)
+
+		if _, err := Load(bytes.NewReader(buffer.Bytes()[:buffer.Len()/2])); err == nil {
+			t.Error("Expected error.")
+		}
+	})
+
+	t.Run("UnknownLayer", func(t *testing.T) {
+		_, err := networkFromState(modelState{Loss: "mse", Layers: []layerState{{Type: "unknown"}}})
+		if err == nil {
+			t.Error("Expected error.")
+		}
+	})
+
+	t.Run("WrongParameterSize", func(t *testing.T) {
+		state := layerState{Type: "dense", Shape: []int{2, 2}, Params: [][]float64{{1}, {1, 2}}}
+		_, err := networkFromState(modelState{Loss: "mse", Layers: []layerState{state}})
+		if err == nil {
+			t.Error("Expected error.")
+		}
+	})
+}
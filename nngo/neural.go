@@ -51,6 +51,68 @@ func NewSet(data, labels [][]float64) (*Set, error) {
 	return &set, nil
 }
 
+// saves sequences, e.g. time series or logs, and their labels for recurrent layers
+//
+// each sequence has Steps steps with Features values each,
+// the sequences are saved in Data in the layout of Signal(Features, Steps)
+// the embedded Set can be used for training, e.g. network.Train(&sequenceSet.Set, ...)
+type SequenceSet struct {
+	Set
+	Features int
+	Steps    int
+}
+
+// converts sequences into a matrix
+// each sequence is a slice of steps and each step a slice with the same number of features
+// shorter sequences are padded with zeros at the beginning, so that the last steps of all sequences line up
+// the size of each label vector should match the output size of the output layer
+func NewSequenceSet(sequences [][][]float64, labels [][]float64) (*SequenceSet, error) {
+	if len(sequences) != len(labels) {
+		return nil, fmt.Errorf("size of sequences and labels should match")
+	}
+	if len(sequences) == 0 {
+		return nil, fmt.Errorf("sequences must not be empty")
+	}
+
+	features, steps := -1, 0
+	for i, sequence := range sequences {
+		if len(sequence) == 0 {
+			return nil, fmt.Errorf("sequence %v has no steps", i)
+		}
+		for _, step := range sequence {
+			if features == -1 {
+				features = len(step)
+			}
+			if len(step) != features || features == 0 {
+				return nil, fmt.Errorf("all steps should have the same positive number of features")
+			}
+		}
+		if len(sequence) > steps {
+			steps = len(sequence)
+		}
+	}
+
+	dataMatrix := mat.NewDense(features*steps, len(sequences), nil)
+	labelMatrix := mat.NewDense(len(labels[0]), len(labels), nil)
+	for i, sequence := range sequences {
+		padding := steps - len(sequence)
+		for t, step := range sequence {
+			for f, v := range step {
+				dataMatrix.Set(f*steps+padding+t, i, v)
+			}
+		}
+		labelMatrix.SetCol(i, labels[i])
+	}
+
+	set := SequenceSet{Set{*dataMatrix, *labelMatrix}, features, steps}
+	return &set, nil
+}
+
+// returns the shape of one sequence, which is Signal(Features, Steps)
+func (s *SequenceSet) Shape() Shape {
+	return Signal(s.Features, s.Steps)
+}
+
 // converts data vectors into a matrix
 // each subslice of the data slice should represent a vector
 // the size of each label vector should match the output size of the output layer
@@ -87,7 +149,9 @@ func NewSplitSet(testData, trainData, testLabels, trainLabels [][]float64) (*Spl
 // converts data vectors into a matrix
 // each subslice of the data slice should represent a vector
 // the size of each label vector should match the output size of the output layer
-func NewSplitSetAlt(data, labels [][]float64, splitRatio float64) (*SplitSet, error) {
+//
+// the samples are shuffled before the split with the source of WithRand
+func NewSplitSetAlt(data, labels [][]float64, splitRatio float64, opts ...Option) (*SplitSet, error) {
 	if len(data) != len(labels) {
 		return nil, fmt.Errorf("size of data and labels should match")
 	}
@@ -102,7 +166,7 @@ func NewSplitSetAlt(data, labels [][]float64, splitRatio float64) (*SplitSet, er
 	}
 
 	set := Set{*dataMatrix, *labelMatrix}
-	splitSet, err := set.splitDataSet(splitRatio)
+	splitSet, err := set.splitDataSet(splitRatio, newOptions(opts).random)
 	if err != nil {
 		return nil, err
 	}
@@ -113,15 +177,16 @@ func NewSplitSetAlt(data, labels [][]float64, splitRatio float64) (*SplitSet, er
 // splits the data and label set into a training and test set
 // splitRatio specifies the size of the test data
 // splitRatio should be between 0 and 1
-func (set *Set) splitDataSet(splitRatio float64) (SplitSet, error) {
-	var splitSet SplitSet
+//
+// the samples are shuffled with random before the split
+func (set *Set) splitDataSet(splitRatio float64, random *rand.Rand) (SplitSet, error) {
 	if splitRatio <= 0.0 || splitRatio >= 1.0 {
-		return splitSet, fmt.Errorf("splitRatio should be a value between 0 and 1")
+		return SplitSet{}, fmt.Errorf("splitRatio should be a value between 0 and 1")
 	}
 
 	// shuffle data and labels
 	for i := 0; i < set.Data.RawMatrix().Cols; i++ {
-		j := rand.Intn(i + 1)
+		j := random.Intn(i + 1)
 
 		// swap data
 		cache := mat.Col(nil, i, &set.Data)
@@ -134,6 +199,12 @@ func (set *Set) splitDataSet(splitRatio float64) (SplitSet, error) {
 		set.Labels.SetCol(j, cache)
 	}
 
+	return set.split(splitRatio), nil
+}
+
+// splits the set without shuffling
+// the last len(data)*splitRatio elements are copied into the test set, the rest into the training set
+func (set *Set) split(splitRatio float64) SplitSet {
 	// take len(data)*splitRatio percent of the last elements as test data
 	splitIndex := set.Data.RawMatrix().Cols - int(math.Ceil(float64(set.Data.RawMatrix().Cols)*splitRatio))
 	trainData := mat.NewDense(set.Data.RawMatrix().Rows,
@@ -163,98 +234,217 @@ func (set *Set) splitDataSet(splitRatio float64) (SplitSet, error) {
 		testLabels.SetCol(i-splitIndex, mat.Col(nil, i, &set.Labels))
 	}
 
-	splitSet = SplitSet{Set{*trainData, *trainLabels}, Set{*testData, *testLabels}}
-	return splitSet, nil
+	return SplitSet{Set{*trainData, *trainLabels}, Set{*testData, *testLabels}}
 }
 
 // specifies a neural network
 // layers are saved inside a slice
 // this structure allows for almost every possible neural network configuration
+// the optimizer updates the parameters of all trainable layers after each batch
+//
+// if the last layer is a softmax layer and the loss is categorical cross entropy,
+// both are fused during training (see SoftmaxCrossEntropy)
+//
+// all random numbers during training come from the random source of the network,
+// it can be seeded with WithSeed and is saved in checkpoints
 type Network struct {
-	layers         []Layer
-	loss           lossFunc
-	lossDerivative lossFuncDerivative
+	layers       []Layer
+	loss         Loss
+	optimizer    Optimizer
+	fusedSoftmax bool
+	random       *randSource
 }
 
-// create a neural network
-// each layer is specified by a subslice
-// e.g. {4, 5, 0} specifies a layer with 4 input, 5 output neurons and Sigmoid as a activation function
-func NewNetwork(layerSpecs [][]int, lossSpecs int) (*Network, error) {
-	layers := make([]Layer, len(layerSpecs)*2)
-	for i, tuple := range layerSpecs {
-		if len(tuple) != 3 {
-			return nil, fmt.Errorf("unexpected layer tuple: %v", tuple)
-		}
+// specification of a dense layer of NewNetwork
+// e.g. {4, 5, ActivationSigmoid} specifies a layer with 4 input, 5 output neurons and Sigmoid as a activation function
+// {4, 5, ActivationSoftmax} specifies a layer with 4 input, 5 output neurons followed by a softmax layer
+type LayerSpec struct {
+	Input      int
+	Output     int
+	Activation ActivationCode
+}
 
-		dense, err := NewDense(tuple[0], tuple[1])
+// create a neural network
+// each layer is specified by a LayerSpec and the loss by its code, e.g. LossMse
+//
+// the options are passed to every dense layer,
+// the source of WithRand also seeds the random source of the network, see WithSeed
+// WithBatchNorm adds a BatchNorm layer after each hidden dense layer
+func NewNetwork(layerSpecs []LayerSpec, lossSpecs LossCode, opts ...Option) (*Network, error) {
+	// all layers draw from the same source, so one seed determines the whole network
+	config := newOptions(opts)
+	random := config.random
+	opts = append(opts[:len(opts):len(opts)], WithRand(random))
+
+	var layers []Layer
+	for i, spec := range layerSpecs {
+		dense, err := NewDense(spec.Input, spec.Output, opts...)
 		if err != nil {
 			return nil, err
 		}
-		layers[2*i] = dense
+		layers = append(layers, dense)
 
-		activation, err := NewActivation(tuple[1], tuple[2])
+		if config.batchNorm != nil && i < len(layerSpecs)-1 {
+			batchNorm, err := NewBatchNorm(spec.Output, config.batchNorm[0], config.batchNorm[1])
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, batchNorm)
+		}
+
+		if spec.Activation == ActivationSoftmax {
+			softmax, err := NewSoftmax(spec.Output)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, softmax)
+			continue
+		}
+
+		activation, err := NewActivation(spec.Output, spec.Activation)
 		if err != nil {
 			return nil, err
 		}
-		layers[2*i+1] = activation
+		layers = append(layers, activation)
 	}
 	funcs, err := getLossTuple(lossSpecs)
 	if err != nil {
 		return nil, err
 	}
-	network := Network{layers, funcs.loss, funcs.lossDerivative}
+	network, err := NewSequential(funcs, layers...)
+	if err != nil {
+		return nil, err
+	}
+	network.SetRand(random)
+	return network, nil
+}
+
+// create a neural network from already constructed layers
+// the layers are applied in the given order
+// the random source of the network is seeded randomly, SetRand or WithSeed make it reproducible
+//
+// e.g. NewSequential(MseLoss, dense, activation)
+func NewSequential(loss Loss, layers ...Layer) (*Network, error) {
+	if loss == nil {
+		return nil, fmt.Errorf("loss must not be nil")
+	}
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("network needs at least one layer")
+	}
+	for i, layer := range layers {
+		if layer == nil {
+			return nil, fmt.Errorf("layer %v must not be nil", i)
+		}
+	}
+
+	fusedSoftmax := endsWithSoftmax(layers) && loss.Name() == CategoricalCrossEntropyLoss.Name()
+	network := Network{
+		layers:       layers,
+		loss:         loss,
+		optimizer:    NewSGD(),
+		fusedSoftmax: fusedSoftmax,
+		random:       newRandSource(rand.Int63()),
+	}
 	return &network, nil
 }
 
-func (dense *Network) Predict(input mat.VecDense) mat.VecDense {
-	for _, layer := range dense.layers {
-		input = layer.forward(input)
+// checks if the last layer is a softmax layer
+func endsWithSoftmax(layers []Layer) bool {
+	if len(layers) == 0 {
+		return false
 	}
-	return input
+	_, ok := layers[len(layers)-1].(*Softmax)
+	return ok
 }
 
-func (dense *Network) Train(train *Set, epochs int, learningRate float64) error {
-	for i := 0; i < epochs; i++ {
-		diff := 0.0
-		for j := 0; j < train.Data.RawMatrix().Cols; j++ {
-			out := dense.Predict(GetColVector(train.Data, j))
-			cache, err := dense.loss(GetColVector(train.Labels, j), out)
-			if err != nil {
-				return err
-			}
-			diff += cache
+// replaces the optimizer of the network, the default optimizer is plain SGD
+//
+// the optimizer keeps state for each parameter,
+// so an optimizer should only be used for one network
+func (dense *Network) SetOptimizer(optimizer Optimizer) {
+	dense.optimizer = optimizer
+}
 
-			grad, err := dense.lossDerivative(GetColVector(train.Labels, j), out)
-			if err != nil {
-				return err
-			}
+// seeds the random source of the network, which is used during training, e.g. by WithShuffle and Dropout,
+// from random like WithRand does for NewNetwork
+//
+// WithSeed of Train replaces the seed again
+func (dense *Network) SetRand(random *rand.Rand) {
+	dense.random.Seed(random.Int63())
+}
 
-			for k := range dense.layers {
-				grad = dense.layers[len(dense.layers)-1-k].backward(grad, learningRate)
-			}
+// collects the parameters of all trainable layers
+// the order of the parameters is the same for every call
+func (dense *Network) params() []Param {
+	return layerParams(dense.layers)
+}
+
+// collects the parameters of all trainable layers in the given order
+func layerParams(layers []Layer) []Param {
+	var params []Param
+	for _, layer := range layers {
+		if t, ok := layer.(trainable); ok {
+			params = append(params, t.params()...)
 		}
-		diff /= float64(train.Data.RawMatrix().Cols)
-		fmt.Printf("Epoch = %v, Error = %v \n", i+1, diff)
+	}
+	return params
+}
+
+// calculates the output of the network for one sample
+//
+// the prediction doesn't change the network,
+// so a trained network can be used from many goroutines at once, as long as it isn't trained at the same time
+func (dense *Network) Predict(input mat.VecDense) (mat.VecDense, error) {
+	batch := mat.NewDense(input.Len(), 1, nil)
+	batch.SetCol(0, mat.Col(nil, 0, &input))
+	output, err := dense.PredictBatch(*batch)
+	if err != nil {
+		return mat.VecDense{}, err
+	}
+	return GetColVector(output, 0), nil
+}
+
+// calculates the output of the network for a batch, each column of the input is one sample
+//
+// like Predict it is safe for concurrent use
+// returns an error, if the first layer can't handle the input, e.g. a wrong number of ids of an Embedding
+func (dense *Network) PredictBatch(input mat.Dense) (mat.Dense, error) {
+	if err := dense.checkInput(input, false); err != nil {
+		return mat.Dense{}, err
+	}
+	for _, layer := range dense.layers {
+		input = layer.predict(input)
+	}
+	return input, nil
+}
+
+// checks the input with the first layer, see checkedInput
+func (dense *Network) checkInput(input mat.Dense, training bool) error {
+	if c, ok := dense.layers[0].(checkedInput); ok {
+		return c.checkInput(input, training)
 	}
 	return nil
 }
 
-// this evaluate function only works for one hot encoded input
-func (dense *Network) EvaluateOneHot(test *Set) float64 {
-	diff := 0.0
-	for i := 0; i < test.Data.RawMatrix().Cols; i++ {
-		input := GetColVector(test.Data, i)
-		predicted := dense.Predict(input)
-
-		predictedIndex := GetMaxIndex(predicted)
-		expectedOutput := GetColVector(test.Labels, i)
-		realIndex := GetMaxIndex(expectedOutput)
-
-		if predictedIndex == realIndex {
-			diff++
-		}
+// propagates a batch through all layers
+// each column of the input is one sample
+func (dense *Network) forward(input mat.Dense) mat.Dense {
+	return forwardLayers(dense.layers, input)
+}
+
+// propagates a batch through the given layers
+func forwardLayers(layers []Layer, input mat.Dense) mat.Dense {
+	for _, layer := range layers {
+		input = layer.forward(input)
 	}
+	return input
+}
 
-	diff /= float64(test.Data.RawMatrix().Cols)
-	return diff
+// this evaluate function only works for one hot encoded input
+func (dense *Network) EvaluateOneHot(test *Set) (float64, error) {
+	predictions, err := dense.PredictBatch(test.Data)
+	if err != nil {
+		return 0, err
+	}
+	return Accuracy(test.Labels, predictions), nil
 }
@@ -1,6 +1,8 @@
 package nngo
 
 import (
+	"math/rand"
+	"sync"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -234,3 +236,194 @@ func TestNewSplitSetAltError(t *testing.T) {
 		}
 	})
 }
+
+func TestTrainBatchSizeError(t *testing.T) {
+	network, err := NewNetwork([]LayerSpec{{2, 2, 2}}, LossMse)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	set := Set{*mat.NewDense(2, 2, []float64{0, 1, 1, 0}), *mat.NewDense(2, 2, []float64{0, 1, 1, 0})}
+	if _, err := network.Train(&set, 1, 0, ConstantRate(0.1)); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestTrainBatch(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	labels := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	set := Set{*data, *labels}
+
+	for _, batchSize := range []int{1, 3, 4, 10} {
+		network, err := NewNetwork([]LayerSpec{{2, 4, 2}, {4, 2, 2}}, LossMse)
+		if err != nil {
+			t.Errorf("Didn't expect error. Got: %v", err)
+		}
+
+		before, _, err := network.batchLoss(set.Labels, network.forward(set.Data))
+		if err != nil {
+			t.Errorf("Didn't expect error. Got: %v", err)
+		}
+
+		if _, err := network.Train(&set, 20, batchSize, ConstantRate(0.01)); err != nil {
+			t.Errorf("Didn't expect error. Got: %v", err)
+		}
+
+		after, _, err := network.batchLoss(set.Labels, network.forward(set.Data))
+		if err != nil {
+			t.Errorf("Didn't expect error. Got: %v", err)
+		}
+
+		if after >= before {
+			t.Errorf("Expected loss to decrease for batch size %v. Before: %v, After: %v", batchSize, before, after)
+		}
+	}
+}
+
+func TestNewNetworkSoftmax(t *testing.T) {
+	network, err := NewNetwork([]LayerSpec{{2, 4, 2}, {4, 3, ActivationSoftmax}}, LossCategoricalCrossEntropy)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	if _, ok := network.layers[3].(*Softmax); !ok {
+		t.Errorf("Expected softmax layer, Got: %T", network.layers[3])
+	}
+
+	if !network.fusedSoftmax {
+		t.Error("Expected softmax and cross entropy to be fused")
+	}
+
+	network, err = NewNetwork([]LayerSpec{{2, 3, ActivationSoftmax}}, LossMse)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	if network.fusedSoftmax {
+		t.Error("Didn't expect softmax and mse to be fused")
+	}
+}
+
+func TestNewNetworkCodes(t *testing.T) {
+	// the codes have their own types, NewNetwork(specs, ActivationSoftmax) doesn't compile
+	var activation ActivationCode = ActivationTanh
+	var loss LossCode = LossBinaryCrossEntropy
+	network, err := NewNetwork([]LayerSpec{{Input: 2, Output: 1, Activation: activation}}, loss)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if name := network.loss.Name(); name != BinaryCrossEntropyLoss.Name() {
+		t.Errorf("Expected: %v, Got: %v", BinaryCrossEntropyLoss.Name(), name)
+	}
+	if name := network.layers[1].(*Activation).function.Name(); name != TanhActivation.Name() {
+		t.Errorf("Expected: %v, Got: %v", TanhActivation.Name(), name)
+	}
+
+	if _, err := NewNetwork([]LayerSpec{{2, 1, ActivationTanh}}, LossCode(100)); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewNetwork([]LayerSpec{{2, 1, ActivationCode(100)}}, LossMse); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestTrainSoftmaxCrossEntropy(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	labels := mat.NewDense(2, 4, []float64{1, 0, 0, 0, 0, 1, 1, 1})
+	set := Set{*data, *labels}
+
+	network, err := NewNetwork([]LayerSpec{{2, 4, 2}, {4, 2, ActivationSoftmax}}, LossCategoricalCrossEntropy)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	before, _, _ := network.batchLoss(set.Labels, network.forward(set.Data))
+	if _, err := network.Train(&set, 20, 4, ConstantRate(0.01)); err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+	after, _, _ := network.batchLoss(set.Labels, network.forward(set.Data))
+
+	if after >= before {
+		t.Errorf("Expected loss to decrease. Before: %v, After: %v", before, after)
+	}
+}
+
+func TestPredictBatch(t *testing.T) {
+	data := mat.NewDense(2, 3, []float64{0, 1, -1, 2, 0.5, 1})
+
+	network, err := NewNetwork([]LayerSpec{{2, 4, ActivationPRelu}, {4, 3, ActivationSoftmax}}, LossCategoricalCrossEntropy)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	before := network.layers[0].(*Dense).base.input
+
+	ans := predictBatch(t, network, *data)
+	if !mat.Equal(&network.layers[0].(*Dense).base.input, &before) {
+		t.Error("Expected predict not to change the layer")
+	}
+
+	expected := network.forward(*data)
+	if !mat.Equal(&ans, &expected) {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+
+	single, err := network.Predict(*mat.NewVecDense(2, []float64{1, 0.5}))
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if expectedCol := GetColVector(expected, 1); !mat.Equal(&single, &expectedCol) {
+		t.Errorf("Expected: %v, Got: %v", expectedCol, single)
+	}
+}
+
+func TestPredictConcurrent(t *testing.T) {
+	data := mat.NewDense(2, 3, []float64{0, 1, -1, 2, 0.5, 1})
+
+	network, err := NewNetwork([]LayerSpec{{2, 4, ActivationTanh}, {4, 2, ActivationSoftmax}}, LossCategoricalCrossEntropy)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	expected := predictBatch(t, network, *data)
+
+	var wg sync.WaitGroup
+	results := make([]mat.Dense, 8)
+	errs := make([]error, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = network.PredictBatch(*data)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+	}
+
+	for _, ans := range results {
+		if !mat.Equal(&ans, &expected) {
+			t.Errorf("Expected: %v, Got: %v", expected, ans)
+		}
+	}
+}
+
+func TestNewSplitSetAltRand(t *testing.T) {
+	data := [][]float64{{1}, {2}, {3}, {4}, {5}, {6}}
+	labels := [][]float64{{1}, {2}, {3}, {4}, {5}, {6}}
+
+	first, err := NewSplitSetAlt(data, labels, 0.5, WithRand(rand.New(rand.NewSource(3))))
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	second, err := NewSplitSetAlt(data, labels, 0.5, WithRand(rand.New(rand.NewSource(3))))
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	if !mat.Equal(&first.Train.Data, &second.Train.Data) || !mat.Equal(&first.Test.Labels, &second.Test.Labels) {
+		t.Errorf("Expected: %v, Got: %v", first, second)
+	}
+}
@@ -0,0 +1,414 @@
+package nngo
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// layers with values that change during training, but are not learned by the optimizer,
+// e.g. the running statistics of BatchNorm
+//
+// the slices share the memory with the layer, they are saved together with the parameters
+type stateful interface {
+	buffers() [][]float64
+}
+
+// layers that combine values over the parts of the batch of all workers, e.g. BatchNorm
+//
+// before each batch the layer of every worker gets the same group and the index of its worker,
+// a nil group means that the layer sees the whole batch
+type synchronized interface {
+	synchronize(group *workerGroup, worker int)
+}
+
+// adds up values over the workers of a batch, see synchronized
+type workerGroup struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	values  [][]float64
+	arrived int
+	round   int
+	total   []float64
+}
+
+func newWorkerGroup(workers int) *workerGroup {
+	g := workerGroup{values: make([][]float64, workers)}
+	g.cond = sync.NewCond(&g.mu)
+	return &g
+}
+
+// waits until every worker passed its values and returns a copy of their sum
+//
+// the values are added in the order of the workers, so the sum doesn't depend on the scheduling
+func (g *workerGroup) sum(worker int, values []float64) []float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[worker] = values
+	g.arrived++
+	round := g.round
+	if g.arrived == len(g.values) {
+		g.total = make([]float64, len(values))
+		for _, v := range g.values {
+			floats.Add(g.total, v)
+		}
+		g.arrived = 0
+		g.round++
+		g.cond.Broadcast()
+	}
+	for round == g.round {
+		g.cond.Wait()
+	}
+	return append([]float64(nil), g.total...)
+}
+
+// normalizes each feature over the samples of the batch
+//
+// in training mode the mean and the variance of the batch are used
+// and the running statistics are updated with
+// running = momentum * running + (1 - momentum) * batch statistic
+// in inference mode the running statistics are used instead
+// afterwards the normalized values are scaled by gamma and shifted by beta, which are learned
+//
+// with multiple workers the statistics are combined over the parts of all workers,
+// so the result doesn't depend on the number of workers
+// paper: https://arxiv.org/abs/1502.03167
+type BatchNorm struct {
+	momentum      float64
+	epsilon       float64
+	gamma         mat.VecDense
+	beta          mat.VecDense
+	gammaGradient mat.VecDense
+	betaGradient  mat.VecDense
+	runningMean   mat.VecDense
+	runningVar    mat.VecDense
+	// normalized input, inverse standard deviation and number of samples of the last forward propagation
+	normalized mat.Dense
+	invStd     []float64
+	samples    float64
+	// workers of the current batch, see synchronized
+	group  *workerGroup
+	worker int
+}
+
+// constructor for BatchNorm layer
+//
+// momentum should be in [0, 1), e.g. 0.99, and epsilon, which is added to the variance, greater than 0
+func NewBatchNorm(size int, momentum, epsilon float64) (*BatchNorm, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("size must be greater than 0")
+	}
+	if momentum < 0 || momentum >= 1 {
+		return nil, fmt.Errorf("momentum should be a value in [0, 1)")
+	}
+	if epsilon <= 0 {
+		return nil, fmt.Errorf("epsilon must be greater than 0")
+	}
+
+	b := BatchNorm{
+		momentum:      momentum,
+		epsilon:       epsilon,
+		gamma:         *mat.NewVecDense(size, ones(size)),
+		beta:          *mat.NewVecDense(size, nil),
+		gammaGradient: *mat.NewVecDense(size, nil),
+		betaGradient:  *mat.NewVecDense(size, nil),
+		runningMean:   *mat.NewVecDense(size, nil),
+		runningVar:    *mat.NewVecDense(size, ones(size)),
+	}
+	return &b, nil
+}
+
+// normalizes with the statistics of the batch and updates the running statistics
+func (b *BatchNorm) forward(input mat.Dense) mat.Dense {
+	rows, cols := input.Dims()
+	b.normalized = *mat.NewDense(rows, cols, nil)
+	b.invStd = make([]float64, rows)
+	means, variances := b.moments(input)
+	for i := 0; i < rows; i++ {
+		b.invStd[i] = 1 / math.Sqrt(variances[i]+b.epsilon)
+		normalize(input.RawRowView(i), b.normalized.RawRowView(i), means[i], b.invStd[i])
+
+		b.runningMean.SetVec(i, b.momentum*b.runningMean.AtVec(i)+(1-b.momentum)*means[i])
+		b.runningVar.SetVec(i, b.momentum*b.runningVar.AtVec(i)+(1-b.momentum)*variances[i])
+	}
+	return scaleShift(b.normalized, b.gamma, b.beta)
+}
+
+// returns the mean and the variance of each feature over the whole batch and sets the number of samples
+//
+// with a group the sums of all workers are combined, first for the mean
+// and afterwards for the squared deviations from it
+func (b *BatchNorm) moments(input mat.Dense) ([]float64, []float64) {
+	rows, cols := input.Dims()
+	means := make([]float64, rows)
+	variances := make([]float64, rows)
+	b.samples = float64(cols)
+	if b.group == nil {
+		for i := 0; i < rows; i++ {
+			means[i], variances[i] = moments(input.RawRowView(i))
+		}
+		return means, variances
+	}
+
+	sums := make([]float64, rows+1)
+	for i := 0; i < rows; i++ {
+		sums[i] = floats.Sum(input.RawRowView(i))
+	}
+	sums[rows] = b.samples
+	sums = b.group.sum(b.worker, sums)
+	b.samples = sums[rows]
+	for i := 0; i < rows; i++ {
+		means[i] = sums[i] / b.samples
+		for _, v := range input.RawRowView(i) {
+			variances[i] += (v - means[i]) * (v - means[i])
+		}
+	}
+	variances = b.group.sum(b.worker, variances)
+	floats.Scale(1/b.samples, variances)
+	return means, variances
+}
+
+// normalizes with the running statistics
+func (b *BatchNorm) predict(input mat.Dense) mat.Dense {
+	rows, cols := input.Dims()
+	normalized := mat.NewDense(rows, cols, nil)
+	for i := 0; i < rows; i++ {
+		invStd := 1 / math.Sqrt(b.runningVar.AtVec(i)+b.epsilon)
+		normalize(input.RawRowView(i), normalized.RawRowView(i), b.runningMean.AtVec(i), invStd)
+	}
+	return scaleShift(*normalized, b.gamma, b.beta)
+}
+
+// the mean and the variance depend on all samples of the batch,
+// so the gradient of each input depends on the whole row:
+//
+// inputGradient = invStd / n * (n * g - sum(g) - normalized * sum(g * normalized))
+// with g = outputGradient * gamma
+//
+// with a group both sums run over the parts of all workers
+func (b *BatchNorm) backward(outputGradient mat.Dense) mat.Dense {
+	rows, cols := outputGradient.Dims()
+	sums := make([]float64, 2*rows)
+	for i := 0; i < rows; i++ {
+		b.gammaGradient.SetVec(i, floats.Dot(outputGradient.RawRowView(i), b.normalized.RawRowView(i)))
+		b.betaGradient.SetVec(i, floats.Sum(outputGradient.RawRowView(i)))
+		sums[i], sums[rows+i] = b.betaGradient.AtVec(i), b.gammaGradient.AtVec(i)
+	}
+	if b.group != nil {
+		sums = b.group.sum(b.worker, sums)
+	}
+
+	ans := mat.NewDense(rows, cols, nil)
+	for i := 0; i < rows; i++ {
+		gamma := b.gamma.AtVec(i)
+		normalizeGradientSums(outputGradient.RawRowView(i), b.normalized.RawRowView(i), gamma, b.invStd[i],
+			b.samples, gamma*sums[i], gamma*sums[rows+i], ans.RawRowView(i))
+	}
+	return *ans
+}
+
+func (b *BatchNorm) synchronize(group *workerGroup, worker int) {
+	b.group, b.worker = group, worker
+}
+
+func (b *BatchNorm) state() layerState {
+	return layerState{Type: "batch_norm", Shape: []int{b.gamma.Len()}, Config: []float64{b.momentum, b.epsilon}}
+}
+
+// the replica has its own running statistics, which are not used,
+// they are the same as the ones of the network, because the workers share the batch statistics
+func (b *BatchNorm) replica() Layer {
+	size := b.gamma.Len()
+	return &BatchNorm{
+		momentum:      b.momentum,
+		epsilon:       b.epsilon,
+		gamma:         b.gamma,
+		beta:          b.beta,
+		gammaGradient: *mat.NewVecDense(size, nil),
+		betaGradient:  *mat.NewVecDense(size, nil),
+		runningMean:   *mat.NewVecDense(size, nil),
+		runningVar:    *mat.NewVecDense(size, ones(size)),
+	}
+}
+
+// returns gamma and beta together with their gradients
+func (b *BatchNorm) params() []Param {
+	return []Param{
+		{Value: b.gamma.RawVector().Data, Gradient: b.gammaGradient.RawVector().Data},
+		{Value: b.beta.RawVector().Data, Gradient: b.betaGradient.RawVector().Data},
+	}
+}
+
+// returns the running mean and the running variance
+func (b *BatchNorm) buffers() [][]float64 {
+	return [][]float64{b.runningMean.RawVector().Data, b.runningVar.RawVector().Data}
+}
+
+// normalizes each sample over its features
+//
+// unlike BatchNorm the statistics don't depend on the batch,
+// so the layer works the same way in training and inference mode
+// afterwards the normalized values are scaled by gamma and shifted by beta, which are learned
+// paper: https://arxiv.org/abs/1607.06450
+type LayerNorm struct {
+	epsilon       float64
+	gamma         mat.VecDense
+	beta          mat.VecDense
+	gammaGradient mat.VecDense
+	betaGradient  mat.VecDense
+	// normalized input and inverse standard deviation of each sample of the last forward propagation
+	normalized mat.Dense
+	invStd     []float64
+}
+
+// constructor for LayerNorm layer
+//
+// epsilon is added to the variance and needs to be greater than 0, e.g. 1e-5
+func NewLayerNorm(size int, epsilon float64) (*LayerNorm, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("size must be greater than 0")
+	}
+	if epsilon <= 0 {
+		return nil, fmt.Errorf("epsilon must be greater than 0")
+	}
+
+	l := LayerNorm{
+		epsilon:       epsilon,
+		gamma:         *mat.NewVecDense(size, ones(size)),
+		beta:          *mat.NewVecDense(size, nil),
+		gammaGradient: *mat.NewVecDense(size, nil),
+		betaGradient:  *mat.NewVecDense(size, nil),
+	}
+	return &l, nil
+}
+
+func (l *LayerNorm) forward(input mat.Dense) mat.Dense {
+	var output mat.Dense
+	l.normalized, l.invStd, output = l.normalize(input)
+	return output
+}
+
+func (l *LayerNorm) predict(input mat.Dense) mat.Dense {
+	_, _, output := l.normalize(input)
+	return output
+}
+
+// returns the normalized input, the inverse standard deviation of each sample and the output
+func (l *LayerNorm) normalize(input mat.Dense) (mat.Dense, []float64, mat.Dense) {
+	rows, cols := input.Dims()
+	normalized := mat.NewDense(rows, cols, nil)
+	invStd := make([]float64, cols)
+	column := make([]float64, rows)
+	for j := 0; j < cols; j++ {
+		mat.Col(column, j, &input)
+		mean, variance := moments(column)
+		invStd[j] = 1 / math.Sqrt(variance+l.epsilon)
+		normalize(column, column, mean, invStd[j])
+		normalized.SetCol(j, column)
+	}
+	return *normalized, invStd, scaleShift(*normalized, l.gamma, l.beta)
+}
+
+// same as the backward propagation of BatchNorm, but the sums run over the features of each sample
+func (l *LayerNorm) backward(outputGradient mat.Dense) mat.Dense {
+	rows, cols := outputGradient.Dims()
+	ans := mat.NewDense(rows, cols, nil)
+	for i := 0; i < rows; i++ {
+		l.gammaGradient.SetVec(i, floats.Dot(outputGradient.RawRowView(i), l.normalized.RawRowView(i)))
+		l.betaGradient.SetVec(i, floats.Sum(outputGradient.RawRowView(i)))
+	}
+
+	gradient := make([]float64, rows)
+	normalized := make([]float64, rows)
+	column := make([]float64, rows)
+	for j := 0; j < cols; j++ {
+		mat.Col(gradient, j, &outputGradient)
+		mat.Col(normalized, j, &l.normalized)
+		for i := range gradient {
+			gradient[i] *= l.gamma.AtVec(i)
+		}
+		normalizeGradient(gradient, normalized, 1, l.invStd[j], column)
+		ans.SetCol(j, column)
+	}
+	return *ans
+}
+
+func (l *LayerNorm) state() layerState {
+	return layerState{Type: "layer_norm", Shape: []int{l.gamma.Len()}, Config: []float64{l.epsilon}}
+}
+
+func (l *LayerNorm) replica() Layer {
+	size := l.gamma.Len()
+	return &LayerNorm{
+		epsilon:       l.epsilon,
+		gamma:         l.gamma,
+		beta:          l.beta,
+		gammaGradient: *mat.NewVecDense(size, nil),
+		betaGradient:  *mat.NewVecDense(size, nil),
+	}
+}
+
+// returns gamma and beta together with their gradients
+func (l *LayerNorm) params() []Param {
+	return []Param{
+		{Value: l.gamma.RawVector().Data, Gradient: l.gammaGradient.RawVector().Data},
+		{Value: l.beta.RawVector().Data, Gradient: l.betaGradient.RawVector().Data},
+	}
+}
+
+// returns the mean and the biased variance of the values
+func moments(values []float64) (float64, float64) {
+	mean := floats.Sum(values) / float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, variance / float64(len(values))
+}
+
+// writes (values - mean) * invStd to dst, which can be values itself
+func normalize(values, dst []float64, mean, invStd float64) {
+	for k, v := range values {
+		dst[k] = (v - mean) * invStd
+	}
+}
+
+// writes the gradient of the normalization to dst
+// the gradient of the normalized values is outputGradient * gamma
+func normalizeGradient(outputGradient, normalized []float64, gamma, invStd float64, dst []float64) {
+	gradientSum, productSum := 0.0, 0.0
+	for k, g := range outputGradient {
+		gradientSum += g * gamma
+		productSum += g * gamma * normalized[k]
+	}
+	normalizeGradientSums(outputGradient, normalized, gamma, invStd, float64(len(outputGradient)), gradientSum, productSum, dst)
+}
+
+// same as normalizeGradient, but the statistics were calculated over n values,
+// which can be more than the given ones, and the sums of the gradient and of the gradient * normalized are given
+func normalizeGradientSums(outputGradient, normalized []float64, gamma, invStd, n, gradientSum, productSum float64, dst []float64) {
+	for k, g := range outputGradient {
+		dst[k] = invStd / n * (n*g*gamma - gradientSum - normalized[k]*productSum)
+	}
+}
+
+// multiplies each row i with gamma[i] and adds beta[i]
+func scaleShift(normalized mat.Dense, gamma, beta mat.VecDense) mat.Dense {
+	var ans mat.Dense
+	ans.Apply(func(i, j int, v float64) float64 {
+		return gamma.AtVec(i)*v + beta.AtVec(i)
+	}, &normalized)
+	return ans
+}
+
+// returns a slice with all values set to 1
+func ones(size int) []float64 {
+	values := make([]float64, size)
+	for i := range values {
+		values[i] = 1
+	}
+	return values
+}
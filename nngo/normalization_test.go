@@ -0,0 +1,227 @@
+package nngo
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestNewNormError(t *testing.T) {
+	if _, err := NewBatchNorm(0, 0.9, 1e-5); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewBatchNorm(2, 1, 1e-5); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewBatchNorm(2, 0.9, 0); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewLayerNorm(2, -1); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestBatchNormForward(t *testing.T) {
+	batchNorm, _ := NewBatchNorm(2, 0.5, 1e-5)
+	input := mat.NewDense(2, 4, []float64{1, 2, 3, 4, -2, 0, 2, 4})
+
+	output := batchNorm.forward(*input)
+	for i := 0; i < 2; i++ {
+		mean, variance := moments(output.RawRowView(i))
+		if math.Abs(mean) > 1e-12 || math.Abs(variance-1) > 1e-4 {
+			t.Errorf("Expected: mean 0 and variance 1, Got: mean %v and variance %v", mean, variance)
+		}
+	}
+
+	// running = 0.5 * initial + 0.5 * batch
+	expected := [][]float64{{1.25, 0.5}, {1.125, 3}}
+	if diff := cmp.Diff(expected, batchNorm.buffers(), cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+		t.Errorf("Running statistics mismatch (-want +got):\n%s", diff)
+	}
+
+	// the prediction uses the running statistics and doesn't change them
+	predicted := batchNorm.predict(*input)
+	if ans, expected := predicted.At(0, 0), (1-1.25)/math.Sqrt(1.125+1e-5); math.Abs(ans-expected) > 1e-12 {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+	if diff := cmp.Diff(expected, batchNorm.buffers(), cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+		t.Errorf("Running statistics mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLayerNormForward(t *testing.T) {
+	layerNorm, _ := NewLayerNorm(3, 1e-5)
+	input := mat.NewDense(3, 2, []float64{1, -2, 2, 0, 6, 5})
+
+	output := layerNorm.forward(*input)
+	for j := 0; j < 2; j++ {
+		mean, variance := moments(mat.Col(nil, j, &output))
+		if math.Abs(mean) > 1e-12 || math.Abs(variance-1) > 1e-4 {
+			t.Errorf("Expected: mean 0 and variance 1, Got: mean %v and variance %v", mean, variance)
+		}
+	}
+
+	predicted := layerNorm.predict(*input)
+	if !mat.Equal(&output, &predicted) {
+		t.Errorf("Expected: %v, Got: %v", output, predicted)
+	}
+}
+
+func TestNormGradients(t *testing.T) {
+	batchNorm, _ := NewBatchNorm(3, 0.9, 1e-5)
+	layerNorm, _ := NewLayerNorm(3, 1e-5)
+	input := mat.NewDense(3, 4, []float64{0.5, -1, 2, 0, 1.5, -0.3, 0.7, 1, -2, 0.1, 0.4, -0.8})
+
+	for _, layer := range []Layer{batchNorm, layerNorm} {
+		params := layer.(trainable).params()
+		copy(params[0].Value, []float64{0.5, 1.5, -1})
+		copy(params[1].Value, []float64{0.1, -0.2, 0.3})
+		checkGradients(t, layer, *input)
+	}
+}
+
+func TestTrainBatchNorm(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	data := normalMatrix(2, 20, random)
+	data.Apply(func(i, j int, v float64) float64 { return 10*v + 5 }, data)
+	labels := mat.NewDense(1, 20, nil)
+	labels.Apply(func(i, j int, v float64) float64 {
+		if data.At(0, j) > 5 {
+			return 1
+		}
+		return 0
+	}, labels)
+	set := Set{*data, *labels}
+
+	dense, _ := NewDense(2, 4, WithRand(random))
+	batchNorm, _ := NewBatchNorm(4, 0.9, 1e-5)
+	activation, _ := NewActivation(4, ActivationRelu)
+	layerNorm, _ := NewLayerNorm(4, 1e-5)
+	output, _ := NewDense(4, 1, WithRand(random))
+	sigmoid, _ := NewActivation(1, ActivationSigmoid)
+	network, err := NewSequential(BinaryCrossEntropyLoss, dense, batchNorm, activation, layerNorm, output, sigmoid)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	history, err := network.Train(&set, 10, 5, ConstantRate(0.1), WithWorkers(2), WithWriter(nil))
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if loss := history.Loss(); loss[len(loss)-1] >= loss[0] {
+		t.Errorf("Expected decreasing loss, Got: %v", loss)
+	}
+	if batchNorm.runningVar.AtVec(0) == 1 {
+		t.Error("Expected updated running statistics.")
+	}
+
+	var buffer bytes.Buffer
+	if err := network.Save(&buffer); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	loaded, err := Load(&buffer)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	checkSamePredictions(t, network, loaded, *data)
+	if diff := cmp.Diff(batchNorm.buffers(), loaded.layers[1].(*BatchNorm).buffers()); diff != "" {
+		t.Errorf("Running statistics mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestBatchNormWorkers(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	data := normalMatrix(2, 20, random)
+	data.Apply(func(i, j int, v float64) float64 { return 3*v + float64(j%4) }, data)
+	labels := mat.NewDense(1, 20, nil)
+	labels.Apply(func(i, j int, v float64) float64 { return data.At(0, j) - data.At(1, j) }, labels)
+	set := Set{*data, *labels}
+
+	// the workers share the statistics of the batch, so the training doesn't depend on their number
+	var networks []*Network
+	for _, workers := range []int{1, 2, 3} {
+		random := rand.New(rand.NewSource(3))
+		dense, _ := NewDense(2, 3, WithRand(random))
+		batchNorm, _ := NewBatchNorm(3, 0.5, 1e-5)
+		output, _ := NewDense(3, 1, WithRand(random))
+		network, _ := NewSequential(MseLoss, dense, batchNorm, output)
+		if _, err := network.Train(&set, 3, 10, ConstantRate(0.1), WithWorkers(workers), WithWriter(nil)); err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		networks = append(networks, network)
+	}
+
+	expected := networks[0].layers[1].(*BatchNorm).buffers()
+	for _, network := range networks[1:] {
+		ans := network.layers[1].(*BatchNorm).buffers()
+		if diff := cmp.Diff(expected, ans, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+			t.Errorf("Running statistics mismatch (-want +got):\n%s", diff)
+		}
+		expectedOutput := predictBatch(t, networks[0], *data)
+		output := predictBatch(t, network, *data)
+		if !mat.EqualApprox(&expectedOutput, &output, 1e-9) {
+			t.Errorf("Expected: %v, Got: %v", expectedOutput, output)
+		}
+	}
+}
+
+func TestRestoreBestBuffers(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	set := Set{*data, *data}
+
+	dense, _ := NewDense(2, 2)
+	batchNorm, _ := NewBatchNorm(2, 0.5, 1e-5)
+	network, _ := NewSequential(MseLoss, dense, batchNorm)
+
+	// the loss doesn't change without updates, so the first epoch stays the best one
+	var buffers [][]float64
+	callback := epochEndCallback(func(result EpochResult) {
+		if result.Epoch == 0 {
+			buffers = copyValues(batchNorm.buffers())
+		}
+	})
+	if _, err := network.Train(&set, 3, 1, ConstantRate(0), WithEarlyStopping(5, true), WithCallbacks(callback), WithWriter(nil)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if diff := cmp.Diff(buffers, batchNorm.buffers()); diff != "" {
+		t.Errorf("Running statistics mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// calls the function at the end of each epoch
+type epochEndCallback func(result EpochResult)
+
+func (f epochEndCallback) OnTrainBegin(network *Network)             {}
+func (f epochEndCallback) OnTrainEnd(history *History)               {}
+func (f epochEndCallback) OnEpochBegin(epoch int)                    {}
+func (f epochEndCallback) OnEpochEnd(result EpochResult)             { f(result) }
+func (f epochEndCallback) OnBatchBegin(epoch, batch int)             {}
+func (f epochEndCallback) OnBatchEnd(epoch, batch int, loss float64) {}
+
+func TestNewNetworkBatchNorm(t *testing.T) {
+	network, err := NewNetwork([]LayerSpec{{2, 4, ActivationRelu}, {4, 4, ActivationRelu}, {4, 2, ActivationSoftmax}}, LossCategoricalCrossEntropy, WithBatchNorm(0.9, 1e-5))
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	var types []string
+	for _, layer := range network.layers {
+		types = append(types, layer.state().Type)
+	}
+	expected := []string{"dense", "batch_norm", "activation", "dense", "batch_norm", "activation", "dense", "softmax"}
+	if diff := cmp.Diff(expected, types); diff != "" {
+		t.Errorf("Layer mismatch (-want +got):\n%s", diff)
+	}
+	if !network.fusedSoftmax {
+		t.Error("Expected fused softmax.")
+	}
+
+	if _, err := NewNetwork([]LayerSpec{{2, 4, ActivationRelu}, {4, 2, ActivationSoftmax}}, LossMse, WithBatchNorm(1, 1e-5)); err == nil {
+		t.Error("Expected error.")
+	}
+}
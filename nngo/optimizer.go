@@ -0,0 +1,343 @@
+package nngo
+
+import (
+	"fmt"
+	"math"
+)
+
+// parameter of a trainable layer together with its gradient
+//
+// both slices share the memory with the matrices of the layer,
+// so the optimizer can update the parameter in place
+//
+// with RowSize > 0 the gradient is sparse, e.g. for Embedding:
+// the values are split into rows of RowSize values and only the rows in Rows have a gradient,
+// the optimizers only update these rows, so an update doesn't depend on the size of the parameter
+type Param struct {
+	Value    []float64
+	Gradient []float64
+	Rows     []int
+	RowSize  int
+}
+
+// returns the ranges [start, end) of the values that have a gradient
+func (p Param) spans() [][2]int {
+	if p.RowSize == 0 {
+		return [][2]int{{0, len(p.Value)}}
+	}
+	spans := make([][2]int, len(p.Rows))
+	for i, row := range p.Rows {
+		spans[i] = [2]int{row * p.RowSize, (row + 1) * p.RowSize}
+	}
+	return spans
+}
+
+// an optimizer updates the parameters of the network with their gradients
+//
+// Update is called once after each batch with the parameters of all trainable layers
+// the position of each parameter in params is the same for every call,
+// so optimizers can use it to save state for each parameter
+// for sparse parameters the built in optimizers only update the state of the rows with a gradient
+type Optimizer interface {
+	Update(params []Param, learningRate float64)
+}
+
+// small value that prevents the division by zero
+const optimizerEpsilon = 1e-8
+
+// serialised form of an optimizer together with its state
+//
+// Config holds the hyperparameters, Step the number of updates
+// and Slots the state for each parameter, e.g. m and v of Adam
+type optimizerSnapshot struct {
+	Type   string
+	Config []float64
+	Step   int
+	Slots  [][][]float64
+}
+
+// optimizers that can be written to checkpoints
+// all built in optimizers implement it
+type snapshotOptimizer interface {
+	Optimizer
+	snapshot() optimizerSnapshot
+}
+
+// creates a slice of zero slices with the same sizes as the parameters
+// existing state is kept, if the sizes still match
+func optimizerState(state [][]float64, params []Param) [][]float64 {
+	if len(state) == len(params) {
+		match := true
+		for i := range params {
+			if len(state[i]) != len(params[i].Value) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return state
+		}
+	}
+
+	state = make([][]float64, len(params))
+	for i := range params {
+		state[i] = make([]float64, len(params[i].Value))
+	}
+	return state
+}
+
+// stochastic gradient descent with optional momentum
+//
+// momentum:
+// velocity = momentum * velocity + gradient
+// value -= learningRate * velocity
+//
+// nesterov:
+// velocity = momentum * velocity + gradient
+// value -= learningRate * (gradient + momentum * velocity)
+type SGD struct {
+	momentum float64
+	nesterov bool
+	velocity [][]float64
+}
+
+// constructor for plain SGD without momentum
+func NewSGD() *SGD {
+	return &SGD{}
+}
+
+// constructor for SGD with momentum
+//
+// momentum needs to be between 0 and 1
+func NewMomentum(momentum float64) (*SGD, error) {
+	if momentum < 0 || momentum >= 1 {
+		return nil, fmt.Errorf("momentum should be a value between 0 and 1")
+	}
+	return &SGD{momentum: momentum}, nil
+}
+
+// constructor for SGD with nesterov momentum
+//
+// momentum needs to be between 0 and 1
+func NewNesterov(momentum float64) (*SGD, error) {
+	if momentum < 0 || momentum >= 1 {
+		return nil, fmt.Errorf("momentum should be a value between 0 and 1")
+	}
+	return &SGD{momentum: momentum, nesterov: true}, nil
+}
+
+func (opt *SGD) snapshot() optimizerSnapshot {
+	nesterov := 0.0
+	if opt.nesterov {
+		nesterov = 1
+	}
+	return optimizerSnapshot{
+		Type:   "sgd",
+		Config: []float64{opt.momentum, nesterov},
+		Slots:  [][][]float64{opt.velocity},
+	}
+}
+
+func (opt *SGD) Update(params []Param, learningRate float64) {
+	if opt.momentum == 0 {
+		for _, p := range params {
+			for _, span := range p.spans() {
+				for j := span[0]; j < span[1]; j++ {
+					p.Value[j] -= learningRate * p.Gradient[j]
+				}
+			}
+		}
+		return
+	}
+
+	opt.velocity = optimizerState(opt.velocity, params)
+	for i, p := range params {
+		velocity := opt.velocity[i]
+		for _, span := range p.spans() {
+			for j := span[0]; j < span[1]; j++ {
+				g := p.Gradient[j]
+				velocity[j] = opt.momentum*velocity[j] + g
+				if opt.nesterov {
+					p.Value[j] -= learningRate * (g + opt.momentum*velocity[j])
+				} else {
+					p.Value[j] -= learningRate * velocity[j]
+				}
+			}
+		}
+	}
+}
+
+// adagrad scales the learning rate of each parameter by all past squared gradients
+//
+// sum += gradient^2
+// value -= learningRate * gradient / (sqrt(sum) + epsilon)
+type Adagrad struct {
+	sum [][]float64
+}
+
+// constructor for Adagrad
+func NewAdagrad() *Adagrad {
+	return &Adagrad{}
+}
+
+func (opt *Adagrad) snapshot() optimizerSnapshot {
+	return optimizerSnapshot{Type: "adagrad", Slots: [][][]float64{opt.sum}}
+}
+
+func (opt *Adagrad) Update(params []Param, learningRate float64) {
+	opt.sum = optimizerState(opt.sum, params)
+	for i, p := range params {
+		sum := opt.sum[i]
+		for _, span := range p.spans() {
+			for j := span[0]; j < span[1]; j++ {
+				g := p.Gradient[j]
+				sum[j] += g * g
+				p.Value[j] -= learningRate * g / (math.Sqrt(sum[j]) + optimizerEpsilon)
+			}
+		}
+	}
+}
+
+// rmsprop scales the learning rate of each parameter by a moving average of the squared gradients
+//
+// average = rho * average + (1 - rho) * gradient^2
+// value -= learningRate * gradient / (sqrt(average) + epsilon)
+type RMSprop struct {
+	rho     float64
+	average [][]float64
+}
+
+// constructor for RMSprop
+//
+// rho is the decay rate of the moving average and needs to be between 0 and 1
+// a common value is 0.9
+func NewRMSprop(rho float64) (*RMSprop, error) {
+	if rho <= 0 || rho >= 1 {
+		return nil, fmt.Errorf("rho should be a value between 0 and 1")
+	}
+	return &RMSprop{rho: rho}, nil
+}
+
+func (opt *RMSprop) snapshot() optimizerSnapshot {
+	return optimizerSnapshot{
+		Type:   "rmsprop",
+		Config: []float64{opt.rho},
+		Slots:  [][][]float64{opt.average},
+	}
+}
+
+func (opt *RMSprop) Update(params []Param, learningRate float64) {
+	opt.average = optimizerState(opt.average, params)
+	for i, p := range params {
+		average := opt.average[i]
+		for _, span := range p.spans() {
+			for j := span[0]; j < span[1]; j++ {
+				g := p.Gradient[j]
+				average[j] = opt.rho*average[j] + (1-opt.rho)*g*g
+				p.Value[j] -= learningRate * g / (math.Sqrt(average[j]) + optimizerEpsilon)
+			}
+		}
+	}
+}
+
+// adam keeps moving averages of the gradients and the squared gradients
+//
+// m = beta1 * m + (1 - beta1) * gradient
+// v = beta2 * v + (1 - beta2) * gradient^2
+// value -= learningRate * mHat / (sqrt(vHat) + epsilon)
+//
+// mHat and vHat are the bias corrected averages
+// paper: https://arxiv.org/abs/1412.6980
+type Adam struct {
+	beta1 float64
+	beta2 float64
+	step  int
+	m     [][]float64
+	v     [][]float64
+}
+
+// constructor for Adam
+//
+// beta1 and beta2 need to be between 0 and 1
+// common values are 0.9 and 0.999
+func NewAdam(beta1, beta2 float64) (*Adam, error) {
+	if beta1 < 0 || beta1 >= 1 || beta2 < 0 || beta2 >= 1 {
+		return nil, fmt.Errorf("beta1 and beta2 should be values between 0 and 1")
+	}
+	return &Adam{beta1: beta1, beta2: beta2}, nil
+}
+
+func (opt *Adam) snapshot() optimizerSnapshot {
+	return optimizerSnapshot{
+		Type:   "adam",
+		Config: []float64{opt.beta1, opt.beta2},
+		Step:   opt.step,
+		Slots:  [][][]float64{opt.m, opt.v},
+	}
+}
+
+func (opt *Adam) Update(params []Param, learningRate float64) {
+	opt.update(params, learningRate, 0)
+}
+
+// applies one adam step
+// weightDecay is only used by AdamW and is applied directly to the parameters
+func (opt *Adam) update(params []Param, learningRate, weightDecay float64) {
+	opt.m = optimizerState(opt.m, params)
+	opt.v = optimizerState(opt.v, params)
+	opt.step++
+
+	correction1 := 1 - math.Pow(opt.beta1, float64(opt.step))
+	correction2 := 1 - math.Pow(opt.beta2, float64(opt.step))
+	for i, p := range params {
+		m := opt.m[i]
+		v := opt.v[i]
+		for _, span := range p.spans() {
+			for j := span[0]; j < span[1]; j++ {
+				g := p.Gradient[j]
+				m[j] = opt.beta1*m[j] + (1-opt.beta1)*g
+				v[j] = opt.beta2*v[j] + (1-opt.beta2)*g*g
+				mHat := m[j] / correction1
+				vHat := v[j] / correction2
+				p.Value[j] -= learningRate * (mHat/(math.Sqrt(vHat)+optimizerEpsilon) + weightDecay*p.Value[j])
+			}
+		}
+	}
+}
+
+// adam with decoupled weight decay
+//
+// the weight decay is not added to the gradient,
+// instead each parameter is shrunk directly by learningRate * weightDecay * value
+// paper: https://arxiv.org/abs/1711.05101
+type AdamW struct {
+	Adam
+	weightDecay float64
+}
+
+// constructor for AdamW
+//
+// beta1 and beta2 need to be between 0 and 1, weightDecay must not be negative,
+// with 0 it behaves like Adam
+// common values are 0.9, 0.999 and 0.01
+func NewAdamW(beta1, beta2, weightDecay float64) (*AdamW, error) {
+	adam, err := NewAdam(beta1, beta2)
+	if err != nil {
+		return nil, err
+	}
+	if weightDecay < 0 {
+		return nil, fmt.Errorf("weightDecay must not be negative")
+	}
+	return &AdamW{*adam, weightDecay}, nil
+}
+
+func (opt *AdamW) snapshot() optimizerSnapshot {
+	snapshot := opt.Adam.snapshot()
+	snapshot.Type = "adamw"
+	snapshot.Config = append(snapshot.Config, opt.weightDecay)
+	return snapshot
+}
+
+func (opt *AdamW) Update(params []Param, learningRate float64) {
+	opt.update(params, learningRate, opt.weightDecay)
+}
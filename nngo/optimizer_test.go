@@ -0,0 +1,195 @@
+package nngo
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// returns a single parameter with the value 1 and the gradient 0.5
+func testParams() []Param {
+	return []Param{{Value: []float64{1}, Gradient: []float64{0.5}}}
+}
+
+func TestSGD(t *testing.T) {
+	params := testParams()
+	NewSGD().Update(params, 0.1)
+
+	if params[0].Value[0] != 0.95 {
+		t.Errorf("Expected: %v, Got: %v", 0.95, params[0].Value[0])
+	}
+}
+
+func TestMomentum(t *testing.T) {
+	optimizer, err := NewMomentum(0.5)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	params := testParams()
+	optimizer.Update(params, 1)
+	optimizer.Update(params, 1)
+
+	// velocity is 0.5 after the first and 0.75 after the second step
+	expected := 1 - 0.5 - 0.75
+	if math.Abs(params[0].Value[0]-expected) > 1e-12 {
+		t.Errorf("Expected: %v, Got: %v", expected, params[0].Value[0])
+	}
+}
+
+func TestNesterov(t *testing.T) {
+	optimizer, err := NewNesterov(0.5)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	params := testParams()
+	optimizer.Update(params, 1)
+
+	// velocity is 0.5, so the step is 0.5 + 0.5 * 0.5
+	expected := 1 - 0.75
+	if math.Abs(params[0].Value[0]-expected) > 1e-12 {
+		t.Errorf("Expected: %v, Got: %v", expected, params[0].Value[0])
+	}
+}
+
+func TestMomentumError(t *testing.T) {
+	if _, err := NewMomentum(1); err == nil {
+		t.Error("Expected error.")
+	}
+
+	if _, err := NewNesterov(-0.1); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestAdagrad(t *testing.T) {
+	params := testParams()
+	NewAdagrad().Update(params, 0.1)
+
+	// gradient / sqrt(gradient^2) is 1
+	expected := 0.9
+	if math.Abs(params[0].Value[0]-expected) > 1e-6 {
+		t.Errorf("Expected: %v, Got: %v", expected, params[0].Value[0])
+	}
+}
+
+func TestRMSprop(t *testing.T) {
+	optimizer, err := NewRMSprop(0.75)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	params := testParams()
+	optimizer.Update(params, 0.1)
+
+	// average is 0.25 * 0.25, so the step is 0.1 * 0.5 / 0.25
+	expected := 0.8
+	if math.Abs(params[0].Value[0]-expected) > 1e-6 {
+		t.Errorf("Expected: %v, Got: %v", expected, params[0].Value[0])
+	}
+
+	if _, err := NewRMSprop(1); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestAdam(t *testing.T) {
+	optimizer, err := NewAdam(0.9, 0.999)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	params := testParams()
+	optimizer.Update(params, 0.1)
+
+	// after bias correction the first step has the size of the learning rate
+	expected := 0.9
+	if math.Abs(params[0].Value[0]-expected) > 1e-6 {
+		t.Errorf("Expected: %v, Got: %v", expected, params[0].Value[0])
+	}
+
+	if _, err := NewAdam(1, 0.999); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestAdamW(t *testing.T) {
+	optimizer, err := NewAdamW(0.9, 0.999, 0.5)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	params := testParams()
+	optimizer.Update(params, 0.1)
+
+	// adam step plus the decay of 0.1 * 0.5 * 1
+	expected := 0.85
+	if math.Abs(params[0].Value[0]-expected) > 1e-6 {
+		t.Errorf("Expected: %v, Got: %v", expected, params[0].Value[0])
+	}
+
+	if _, err := NewAdamW(0.9, 0.999, -1); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewAdamW(0.9, 0.999, 0); err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+}
+
+func TestOptimizerStateReset(t *testing.T) {
+	params := testParams()
+	state := optimizerState(nil, params)
+	state[0][0] = 1
+
+	if kept := optimizerState(state, params); kept[0][0] != 1 {
+		t.Error("Expected state to be kept")
+	}
+
+	other := []Param{{Value: make([]float64, 2), Gradient: make([]float64, 2)}}
+	if reset := optimizerState(state, other); len(reset[0]) != 2 || reset[0][0] != 0 {
+		t.Error("Expected state to be reset")
+	}
+}
+
+func TestTrainOptimizers(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	labels := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	set := Set{*data, *labels}
+
+	momentum, _ := NewMomentum(0.9)
+	nesterov, _ := NewNesterov(0.9)
+	rmsprop, _ := NewRMSprop(0.9)
+	adam, _ := NewAdam(0.9, 0.999)
+	adamW, _ := NewAdamW(0.9, 0.999, 0.01)
+	optimizers := map[string]Optimizer{
+		"SGD":      NewSGD(),
+		"Momentum": momentum,
+		"Nesterov": nesterov,
+		"Adagrad":  NewAdagrad(),
+		"RMSprop":  rmsprop,
+		"Adam":     adam,
+		"AdamW":    adamW,
+	}
+
+	for name, optimizer := range optimizers {
+		t.Run(name, func(t *testing.T) {
+			network, err := NewNetwork([]LayerSpec{{2, 4, 2}, {4, 2, 2}}, LossMse)
+			if err != nil {
+				t.Errorf("Didn't expect error. Got: %v", err)
+			}
+			network.SetOptimizer(optimizer)
+
+			before, _, _ := network.batchLoss(set.Labels, network.forward(set.Data))
+			if _, err := network.Train(&set, 20, 4, ConstantRate(0.001)); err != nil {
+				t.Errorf("Didn't expect error. Got: %v", err)
+			}
+			after, _, _ := network.batchLoss(set.Labels, network.forward(set.Data))
+
+			if after >= before {
+				t.Errorf("Expected loss to decrease. Before: %v, After: %v", before, after)
+			}
+		})
+	}
+}
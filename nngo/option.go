@@ -0,0 +1,175 @@
+package nngo
+
+import "math/rand"
+
+// optional setting of constructors, e.g. WithRand for NewDense
+type Option func(config *options)
+
+// settings of a constructor, collected from the options
+type options struct {
+	random        *rand.Rand
+	weightInit    Initializer
+	biasInit      Initializer
+	regularizer   Regularizer
+	constraint    Constraint
+	batchNorm     []float64
+	stride        int
+	dilation      int
+	padding       int
+	samePadding   bool
+	causalPadding bool
+	recurrentInit Initializer
+	sequences     bool
+	truncation    int
+	causalMask    bool
+	paddingMask   bool
+}
+
+// uses random for all random numbers of the constructor, e.g. the initial weights
+//
+// the same source with the same seed always creates the same values,
+// without this option a new source is seeded from the global source of math/rand
+func WithRand(random *rand.Rand) Option {
+	return func(config *options) {
+		config.random = random
+	}
+}
+
+// initializes the weights of dense and convolution layers with init, the default is GlorotUniform
+func WithWeightInit(init Initializer) Option {
+	return func(config *options) {
+		config.weightInit = init
+	}
+}
+
+// initializes the biases of dense and convolution layers with init, the default is Zeros
+func WithBiasInit(init Initializer) Option {
+	return func(config *options) {
+		config.biasInit = init
+	}
+}
+
+// adds the penalty of regularizer to the loss for the weights of dense, convolution and attention layers, e.g. L2(0.01)
+func WithRegularizer(regularizer Regularizer) Option {
+	return func(config *options) {
+		config.regularizer = regularizer
+	}
+}
+
+// applies constraint to the weights of dense, convolution and attention layers after each update, e.g. MaxNorm(3)
+func WithConstraint(constraint Constraint) Option {
+	return func(config *options) {
+		config.constraint = constraint
+	}
+}
+
+// inserts a BatchNorm layer between each hidden dense layer of NewNetwork and its activation
+// the output layer is not normalized
+func WithBatchNorm(momentum, epsilon float64) Option {
+	return func(config *options) {
+		config.batchNorm = []float64{momentum, epsilon}
+	}
+}
+
+// moves the window of convolution and pooling layers by stride positions
+// the default is 1 for convolutions and the window size for pooling
+func WithStride(stride int) Option {
+	return func(config *options) {
+		config.stride = stride
+	}
+}
+
+// spreads the kernel of convolution and pooling layers, so that it covers every dilation-th position
+// the default is 1, i.e. no gaps
+func WithDilation(dilation int) Option {
+	return func(config *options) {
+		config.dilation = dilation
+	}
+}
+
+// pads the input of convolution and pooling layers with padding zeros on each side
+func WithPadding(padding int) Option {
+	return func(config *options) {
+		config.padding = padding
+	}
+}
+
+// pads the input of convolution and pooling layers,
+// so that the output has size / stride positions rounded up, e.g. the same size with stride 1
+func WithSamePadding() Option {
+	return func(config *options) {
+		config.samePadding = true
+	}
+}
+
+// pads the input of 1D convolution and pooling layers only at the beginning,
+// so that each output only depends on the current and the earlier positions
+// with stride 1 the output has the same length as the input
+func WithCausalPadding() Option {
+	return func(config *options) {
+		config.causalPadding = true
+	}
+}
+
+// initializes the recurrent weights of recurrent layers with init, the default is Orthogonal
+func WithRecurrentInit(init Initializer) Option {
+	return func(config *options) {
+		config.recurrentInit = init
+	}
+}
+
+// recurrent layers return the hidden state of every step instead of only the last one
+func WithReturnSequences() Option {
+	return func(config *options) {
+		config.sequences = true
+	}
+}
+
+// recurrent layers propagate the gradient back through at most steps steps at a time (truncated BPTT)
+//
+// the sequence is split into chunks of steps steps, the hidden state is passed on between the chunks,
+// but the gradient stops at the beginning of each chunk
+// the default is 0, which propagates the gradient through the whole sequence
+func WithTruncatedBPTT(steps int) Option {
+	return func(config *options) {
+		config.truncation = steps
+	}
+}
+
+// attention layers only let each step attend to itself and the earlier steps, e.g. for language models
+func WithCausalMask() Option {
+	return func(config *options) {
+		config.causalMask = true
+	}
+}
+
+// treats steps of sequences, where all features are zero, as padding, e.g. the padding of NewSequenceSet
+//
+// attention layers ignore the padded steps and positional encodings keep them zero,
+// Embedding reserves id 0 for padding and maps it to a zero vector, which is not trained
+func WithPaddingMask() Option {
+	return func(config *options) {
+		config.paddingMask = true
+	}
+}
+
+// collects the options and fills in the defaults
+func newOptions(opts []Option) options {
+	var config options
+	for _, option := range opts {
+		option(&config)
+	}
+	if config.random == nil {
+		config.random = rand.New(newRandSource(rand.Int63()))
+	}
+	if config.weightInit == nil {
+		config.weightInit = GlorotUniform
+	}
+	if config.biasInit == nil {
+		config.biasInit = Zeros
+	}
+	if config.recurrentInit == nil {
+		config.recurrentInit = Orthogonal
+	}
+	return config
+}
@@ -0,0 +1,310 @@
+package nngo
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// takes the maximum of each window in each channel
+//
+// positions in the padding are ignored
+type MaxPool2D struct {
+	geometry convGeometry
+	indices  []int
+	// input row of the maximum of each output value of the last forward propagation
+	argmax    []int
+	inputRows int
+}
+
+// constructor for MaxPool2D layer
+//
+// the window has the size size x size, the stride is the size of the window unless set with WithStride
+// WithPadding, WithSamePadding and WithDilation work like for Conv2D
+func NewMaxPool2D(input Shape, size int, opts ...Option) (*MaxPool2D, error) {
+	geometry, err := newGeometry(input, size, size, size, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return &MaxPool2D{geometry: geometry, indices: geometry.indices()}, nil
+}
+
+// returns the shape of the output of one sample
+func (m *MaxPool2D) OutputShape() Shape {
+	return m.geometry.output(m.geometry.input.Channels)
+}
+
+func (m *MaxPool2D) forward(input mat.Dense) mat.Dense {
+	output, argmax := m.pool(input)
+	m.argmax = argmax
+	m.inputRows, _ = input.Dims()
+	return output
+}
+
+func (m *MaxPool2D) predict(input mat.Dense) mat.Dense {
+	output, _ := m.pool(input)
+	return output
+}
+
+// returns the output and the input row of each maximum, the rows are saved column by column
+func (m *MaxPool2D) pool(input mat.Dense) (mat.Dense, []int) {
+	_, samples := input.Dims()
+	channels := m.geometry.input.Channels
+	channelSize := m.geometry.input.Height * m.geometry.input.Width
+	positions := len(m.indices) / (m.geometry.kernelHeight * m.geometry.kernelWidth)
+
+	ans := mat.NewDense(channels*positions, samples, nil)
+	argmax := make([]int, channels*positions*samples)
+	for n := 0; n < samples; n++ {
+		for ch := 0; ch < channels; ch++ {
+			for p := 0; p < positions; p++ {
+				best, bestRow := math.Inf(-1), -1
+				for k := p; k < len(m.indices); k += positions {
+					if index := m.indices[k]; index >= 0 {
+						row := ch*channelSize + index
+						if v := input.At(row, n); v > best || bestRow < 0 {
+							best, bestRow = v, row
+						}
+					}
+				}
+				// a window can lie completely in the padding
+				if bestRow < 0 {
+					best = 0
+				}
+				ans.Set(ch*positions+p, n, best)
+				argmax[n*channels*positions+ch*positions+p] = bestRow
+			}
+		}
+	}
+	return *ans, argmax
+}
+
+// only the maximum of each window gets the gradient
+func (m *MaxPool2D) backward(outputGradient mat.Dense) mat.Dense {
+	rows, samples := outputGradient.Dims()
+	ans := mat.NewDense(m.inputRows, samples, nil)
+	for n := 0; n < samples; n++ {
+		for i := 0; i < rows; i++ {
+			if row := m.argmax[n*rows+i]; row >= 0 {
+				ans.Set(row, n, ans.At(row, n)+outputGradient.At(i, n))
+			}
+		}
+	}
+	return *ans
+}
+
+func (m *MaxPool2D) state() layerState {
+	return layerState{Type: "max_pool2d", Shape: m.geometry.sizes()}
+}
+
+func (m *MaxPool2D) replica() Layer {
+	return &MaxPool2D{geometry: m.geometry, indices: m.indices}
+}
+
+// takes the average of each window in each channel
+//
+// positions in the padding are not counted
+type AvgPool2D struct {
+	geometry  convGeometry
+	indices   []int
+	inputRows int
+}
+
+// constructor for AvgPool2D layer
+//
+// the window has the size size x size, the stride is the size of the window unless set with WithStride
+// WithPadding, WithSamePadding and WithDilation work like for Conv2D
+func NewAvgPool2D(input Shape, size int, opts ...Option) (*AvgPool2D, error) {
+	geometry, err := newGeometry(input, size, size, size, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return &AvgPool2D{geometry: geometry, indices: geometry.indices()}, nil
+}
+
+// returns the shape of the output of one sample
+func (a *AvgPool2D) OutputShape() Shape {
+	return a.geometry.output(a.geometry.input.Channels)
+}
+
+func (a *AvgPool2D) forward(input mat.Dense) mat.Dense {
+	a.inputRows, _ = input.Dims()
+	return a.predict(input)
+}
+
+func (a *AvgPool2D) predict(input mat.Dense) mat.Dense {
+	_, samples := input.Dims()
+	channels := a.geometry.input.Channels
+	channelSize := a.geometry.input.Height * a.geometry.input.Width
+	positions := len(a.indices) / (a.geometry.kernelHeight * a.geometry.kernelWidth)
+	counts := a.counts()
+
+	ans := mat.NewDense(channels*positions, samples, nil)
+	for n := 0; n < samples; n++ {
+		for ch := 0; ch < channels; ch++ {
+			for p := 0; p < positions; p++ {
+				sum := 0.0
+				for k := p; k < len(a.indices); k += positions {
+					if index := a.indices[k]; index >= 0 {
+						sum += input.At(ch*channelSize+index, n)
+					}
+				}
+				if counts[p] > 0 {
+					ans.Set(ch*positions+p, n, sum/counts[p])
+				}
+			}
+		}
+	}
+	return *ans
+}
+
+// each value of a window gets the same part of the gradient
+func (a *AvgPool2D) backward(outputGradient mat.Dense) mat.Dense {
+	_, samples := outputGradient.Dims()
+	channels := a.geometry.input.Channels
+	channelSize := a.geometry.input.Height * a.geometry.input.Width
+	positions := len(a.indices) / (a.geometry.kernelHeight * a.geometry.kernelWidth)
+	counts := a.counts()
+
+	ans := mat.NewDense(a.inputRows, samples, nil)
+	for n := 0; n < samples; n++ {
+		for ch := 0; ch < channels; ch++ {
+			for p := 0; p < positions; p++ {
+				if counts[p] == 0 {
+					continue
+				}
+				gradient := outputGradient.At(ch*positions+p, n) / counts[p]
+				for k := p; k < len(a.indices); k += positions {
+					if index := a.indices[k]; index >= 0 {
+						row := ch*channelSize + index
+						ans.Set(row, n, ans.At(row, n)+gradient)
+					}
+				}
+			}
+		}
+	}
+	return *ans
+}
+
+// returns the number of positions outside of the padding for each window
+func (a *AvgPool2D) counts() []float64 {
+	positions := len(a.indices) / (a.geometry.kernelHeight * a.geometry.kernelWidth)
+	counts := make([]float64, positions)
+	for k, index := range a.indices {
+		if index >= 0 {
+			counts[k%positions]++
+		}
+	}
+	return counts
+}
+
+func (a *AvgPool2D) state() layerState {
+	return layerState{Type: "avg_pool2d", Shape: a.geometry.sizes()}
+}
+
+func (a *AvgPool2D) replica() Layer {
+	return &AvgPool2D{geometry: a.geometry, indices: a.indices}
+}
+
+// takes the average of each channel, the output has one value for each channel
+type GlobalAveragePooling struct {
+	input Shape
+}
+
+// constructor for GlobalAveragePooling layer
+func NewGlobalAveragePooling(input Shape) (*GlobalAveragePooling, error) {
+	if !input.valid() {
+		return nil, fmt.Errorf("input shape %v must be positive", input)
+	}
+	return &GlobalAveragePooling{input}, nil
+}
+
+// returns the shape of the output of one sample
+func (g *GlobalAveragePooling) OutputShape() Shape {
+	return Shape{g.input.Channels, 1, 1}
+}
+
+func (g *GlobalAveragePooling) forward(input mat.Dense) mat.Dense {
+	return g.predict(input)
+}
+
+func (g *GlobalAveragePooling) predict(input mat.Dense) mat.Dense {
+	_, samples := input.Dims()
+	channelSize := g.input.Height * g.input.Width
+	ans := mat.NewDense(g.input.Channels, samples, nil)
+	for ch := 0; ch < g.input.Channels; ch++ {
+		for n := 0; n < samples; n++ {
+			sum := 0.0
+			for i := 0; i < channelSize; i++ {
+				sum += input.At(ch*channelSize+i, n)
+			}
+			ans.Set(ch, n, sum/float64(channelSize))
+		}
+	}
+	return *ans
+}
+
+func (g *GlobalAveragePooling) backward(outputGradient mat.Dense) mat.Dense {
+	_, samples := outputGradient.Dims()
+	channelSize := g.input.Height * g.input.Width
+	ans := mat.NewDense(g.input.Size(), samples, nil)
+	for ch := 0; ch < g.input.Channels; ch++ {
+		for n := 0; n < samples; n++ {
+			gradient := outputGradient.At(ch, n) / float64(channelSize)
+			for i := 0; i < channelSize; i++ {
+				ans.Set(ch*channelSize+i, n, gradient)
+			}
+		}
+	}
+	return *ans
+}
+
+func (g *GlobalAveragePooling) state() layerState {
+	return layerState{Type: "global_average_pooling", Shape: []int{g.input.Channels, g.input.Height, g.input.Width}}
+}
+
+func (g *GlobalAveragePooling) replica() Layer {
+	return &GlobalAveragePooling{g.input}
+}
+
+// turns an image into a plain vector, e.g. before a Dense layer
+//
+// the images are already saved as vectors, so the values are passed through unchanged,
+// the layer only marks the end of the image layers in the network
+type Flatten struct {
+	input Shape
+}
+
+// constructor for Flatten layer
+func NewFlatten(input Shape) (*Flatten, error) {
+	if !input.valid() {
+		return nil, fmt.Errorf("input shape %v must be positive", input)
+	}
+	return &Flatten{input}, nil
+}
+
+// returns the shape of the output of one sample, which is {size, 1, 1}
+func (f *Flatten) OutputShape() Shape {
+	return Shape{f.input.Size(), 1, 1}
+}
+
+func (f *Flatten) forward(input mat.Dense) mat.Dense {
+	return input
+}
+
+func (f *Flatten) predict(input mat.Dense) mat.Dense {
+	return input
+}
+
+func (f *Flatten) backward(outputGradient mat.Dense) mat.Dense {
+	return outputGradient
+}
+
+func (f *Flatten) state() layerState {
+	return layerState{Type: "flatten", Shape: []int{f.input.Channels, f.input.Height, f.input.Width}}
+}
+
+func (f *Flatten) replica() Layer {
+	return &Flatten{f.input}
+}
@@ -0,0 +1,121 @@
+package nngo
+
+import (
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestMaxPool2D(t *testing.T) {
+	pool, err := NewMaxPool2D(Shape{2, 4, 4}, 2)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if ans := pool.OutputShape(); ans != (Shape{2, 2, 2}) {
+		t.Errorf("Expected: %v, Got: %v", Shape{2, 2, 2}, ans)
+	}
+
+	input := countingBatch(32, 2)
+	output := pool.forward(*input)
+	expected := mat.NewDense(8, 2, []float64{6, 16, 8, 18, 14, 24, 16, 26, 22, 32, 24, 34, 30, 40, 32, 42})
+	if !mat.Equal(expected, &output) {
+		t.Errorf("Expected: %v, Got: %v", mat.Formatted(expected), mat.Formatted(&output))
+	}
+
+	// the gradient goes to the maximum of each window
+	gradient := pool.backward(*countingBatch(8, 2))
+	if ans := gradient.At(5, 0); ans != 1 {
+		t.Errorf("Expected: %v, Got: %v", 1, ans)
+	}
+	if ans := gradient.At(4, 0); ans != 0 {
+		t.Errorf("Expected: %v, Got: %v", 0, ans)
+	}
+}
+
+func TestAvgPool2D(t *testing.T) {
+	pool, _ := NewAvgPool2D(Shape{1, 3, 3}, 2, WithStride(1), WithPadding(1))
+	if ans := pool.OutputShape(); ans != (Shape{1, 4, 4}) {
+		t.Errorf("Expected: %v, Got: %v", Shape{1, 4, 4}, ans)
+	}
+
+	// the padding is not counted
+	output := pool.forward(*countingBatch(9, 1))
+	expected := []float64{1, 1.5, 2.5, 3, 2.5, 3, 4, 4.5, 5.5, 6, 7, 7.5, 7, 7.5, 8.5, 9}
+	if !mat.Equal(mat.NewDense(16, 1, expected), &output) {
+		t.Errorf("Expected: %v, Got: %v", expected, output.RawMatrix().Data)
+	}
+}
+
+func TestPoolingGradients(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	input := normalMatrix(2*5*5, 2, random)
+
+	maxPool, _ := NewMaxPool2D(Shape{2, 5, 5}, 2, WithSamePadding())
+	dilated, _ := NewMaxPool2D(Shape{2, 5, 5}, 2, WithStride(1), WithDilation(2))
+	avgPool, _ := NewAvgPool2D(Shape{2, 5, 5}, 3, WithStride(2), WithPadding(1))
+	global, _ := NewGlobalAveragePooling(Shape{2, 5, 5})
+	flatten, _ := NewFlatten(Shape{2, 5, 5})
+
+	for _, layer := range []Layer{maxPool, dilated, avgPool, global, flatten} {
+		checkGradients(t, layer, *input)
+	}
+}
+
+func TestGlobalAveragePooling(t *testing.T) {
+	global, _ := NewGlobalAveragePooling(Shape{2, 2, 2})
+	output := global.forward(*countingBatch(8, 1))
+	if expected := mat.NewDense(2, 1, []float64{2.5, 6.5}); !mat.Equal(expected, &output) {
+		t.Errorf("Expected: %v, Got: %v", expected, output)
+	}
+
+	if _, err := NewGlobalAveragePooling(Shape{0, 1, 1}); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewFlatten(Shape{1, 1, 0}); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestTrainCNN(t *testing.T) {
+	// vertical and horizontal lines in 4x4 images
+	random := rand.New(rand.NewSource(1))
+	data := mat.NewDense(16, 8, nil)
+	labels := mat.NewDense(2, 8, nil)
+	for j := 0; j < 8; j++ {
+		line := j % 4
+		for k := 0; k < 4; k++ {
+			if j < 4 {
+				data.Set(k*4+line, j, 1)
+			} else {
+				data.Set(line*4+k, j, 1)
+			}
+		}
+		labels.Set(j/4, j, 1)
+	}
+	set := Set{*data, *labels}
+
+	conv, _ := NewConv2D(Shape{1, 4, 4}, 4, 3, 3, WithSamePadding(), WithRand(random))
+	relu, _ := NewActivation(conv.OutputShape().Size(), ActivationRelu)
+	pool, _ := NewMaxPool2D(conv.OutputShape(), 2)
+	flatten, _ := NewFlatten(pool.OutputShape())
+	dense, _ := NewDense(flatten.OutputShape().Size(), 2, WithRand(random))
+	softmax, _ := NewSoftmax(2)
+	network, err := NewSequential(CategoricalCrossEntropyLoss, conv, relu, pool, flatten, dense, softmax)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	adam, _ := NewAdam(0.9, 0.999)
+	network.SetOptimizer(adam)
+	if _, err := network.Train(&set, 50, 4, ConstantRate(0.05), WithWorkers(2), WithWriter(nil)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	accuracy, err := network.EvaluateOneHot(&set)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if accuracy != 1 {
+		t.Errorf("Expected: %v, Got: %v", 1, accuracy)
+	}
+}
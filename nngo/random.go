@@ -0,0 +1,34 @@
+package nngo
+
+import "math/rand"
+
+// random source of the network
+//
+// unlike the source of math/rand the whole state is a single number,
+// so it can be written to checkpoints and restored exactly
+// the generator is splitmix64: https://prng.di.unimi.it/splitmix64.c
+type randSource struct {
+	state uint64
+}
+
+var _ rand.Source64 = (*randSource)(nil)
+
+func newRandSource(seed int64) *randSource {
+	return &randSource{uint64(seed)}
+}
+
+func (s *randSource) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+func (s *randSource) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *randSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
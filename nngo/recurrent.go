@@ -0,0 +1,583 @@
+package nngo
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// hidden state of a recurrent layer for all samples of a batch, one column per sample
+// cell is only used by LSTM
+type recurrentState struct {
+	hidden mat.Dense
+	cell   mat.Dense
+}
+
+// calculation of one step of a recurrent layer
+//
+// the weights of the input and the bias are applied by the layer for all gates at once,
+// the cell applies the recurrent weights and the gates
+type recurrentCell interface {
+	// number of gates, the weights have gates * units rows
+	gates() int
+	// calculates the next state from inputs = weights * input + bias
+	// and returns the values that backstep needs
+	step(inputs mat.Dense, previous recurrentState, recurrent *mat.Dense) (recurrentState, []mat.Dense)
+	// calculates the gradient of inputs and of the previous state from the gradient of the next state
+	// and adds the gradient of the recurrent weights to recurrentGradient
+	backstep(gradient, previous recurrentState, values []mat.Dense, recurrent, recurrentGradient *mat.Dense) (mat.Dense, recurrentState)
+}
+
+// cells of the recurrent layers by their type
+var recurrentCells = map[string]recurrentCell{
+	"simple_rnn": simpleCell{},
+	"lstm":       lstmCell{},
+	"gru":        gruCell{},
+}
+
+// values of one step of the last forward propagation
+type recurrentStep struct {
+	input    mat.Dense
+	previous recurrentState
+	values   []mat.Dense
+}
+
+// recurrent layer, which processes a sequence step by step and passes a hidden state from one step to the next,
+// see NewSimpleRNN, NewLSTM and NewGRU
+//
+// the input of each sample is a sequence in the layout of Signal(features, steps),
+// i.e. the values are ordered by feature and then by step
+// the output is the hidden state after the last step or with WithReturnSequences the hidden state of every step
+// in the layout of Signal(units, steps)
+// the hidden state starts with zeros for each sequence
+//
+// backward propagation runs through the steps in reverse order (backpropagation through time),
+// WithTruncatedBPTT limits how far the gradient flows back
+type Recurrent struct {
+	kind       string
+	cell       recurrentCell
+	input      Shape
+	units      int
+	sequences  bool
+	truncation int
+	// processes the steps from the last to the first, see Bidirectional
+	reverse           bool
+	weights           mat.Dense
+	recurrent         mat.Dense
+	bias              mat.VecDense
+	weightsGradient   mat.Dense
+	recurrentGradient mat.Dense
+	biasGradient      mat.VecDense
+	steps             []recurrentStep
+}
+
+// constructor for a simple recurrent layer (Elman network)
+//
+// hidden = tanh(weights * input + recurrent * previous hidden + bias)
+// input needs a height of 1, see Signal, the features are the channels and the steps the positions
+// the weights are initialized like the weights of Dense, see WithWeightInit and WithBiasInit,
+// WithRecurrentInit initializes the recurrent weights
+// WithReturnSequences and WithTruncatedBPTT change the output and the backward propagation
+func NewSimpleRNN(input Shape, units int, opts ...Option) (*Recurrent, error) {
+	return newRecurrent("simple_rnn", input, units, newOptions(opts))
+}
+
+// constructor for a long short-term memory layer
+//
+// the input, forget and output gates decide what is written to, kept in and read from the cell state:
+// cell = forget * previous cell + in * tanh(candidate), hidden = out * tanh(cell)
+// the bias of the forget gate starts at 1, so that the cell state is kept at the beginning of the training
+// the options work like for NewSimpleRNN
+// paper: https://www.bioinf.jku.at/publications/older/2604.pdf
+func NewLSTM(input Shape, units int, opts ...Option) (*Recurrent, error) {
+	r, err := newRecurrent("lstm", input, units, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	forget := r.bias.RawVector().Data[units : 2*units]
+	for i := range forget {
+		forget[i]++
+	}
+	return r, nil
+}
+
+// constructor for a gated recurrent unit layer
+//
+// the update gate mixes the previous hidden state with a candidate,
+// the reset gate decides how much of the previous hidden state the candidate uses:
+// hidden = update * previous hidden + (1 - update) * tanh(weights * input + recurrent * (reset * previous hidden) + bias)
+// the options work like for NewSimpleRNN
+// paper: https://arxiv.org/abs/1406.1078
+func NewGRU(input Shape, units int, opts ...Option) (*Recurrent, error) {
+	return newRecurrent("gru", input, units, newOptions(opts))
+}
+
+// creates a recurrent layer with the cell of kind
+func newRecurrent(kind string, input Shape, units int, config options) (*Recurrent, error) {
+	if !input.valid() {
+		return nil, fmt.Errorf("input shape %v must be positive", input)
+	}
+	if input.Height != 1 {
+		return nil, fmt.Errorf("recurrent layers need an input with height 1, got %v", input)
+	}
+	if units <= 0 {
+		return nil, fmt.Errorf("units must be greater than 0")
+	}
+	if config.truncation < 0 {
+		return nil, fmt.Errorf("truncation must be at least 0")
+	}
+
+	cell := recurrentCells[kind]
+	rows := cell.gates() * units
+	features := input.Channels
+	r := Recurrent{
+		kind:              kind,
+		cell:              cell,
+		input:             input,
+		units:             units,
+		sequences:         config.sequences,
+		truncation:        config.truncation,
+		weights:           *mat.NewDense(rows, features, nil),
+		recurrent:         *mat.NewDense(rows, units, nil),
+		bias:              *mat.NewVecDense(rows, nil),
+		weightsGradient:   *mat.NewDense(rows, features, nil),
+		recurrentGradient: *mat.NewDense(rows, units, nil),
+		biasGradient:      *mat.NewVecDense(rows, nil),
+	}
+	config.weightInit(r.weights.RawMatrix().Data, features, rows, config.random)
+	config.recurrentInit(r.recurrent.RawMatrix().Data, units, rows, config.random)
+	config.biasInit(r.bias.RawVector().Data, features, rows, config.random)
+	return &r, nil
+}
+
+// returns the shape of the output of one sample,
+// Signal(units, steps) with WithReturnSequences and Signal(units, 1) otherwise
+func (r *Recurrent) OutputShape() Shape {
+	if r.sequences {
+		return Signal(r.units, r.input.Width)
+	}
+	return Signal(r.units, 1)
+}
+
+func (r *Recurrent) forward(input mat.Dense) mat.Dense {
+	output, steps := r.run(input, true)
+	r.steps = steps
+	return output
+}
+
+func (r *Recurrent) predict(input mat.Dense) mat.Dense {
+	output, _ := r.run(input, false)
+	return output
+}
+
+// returns the position in the sequence of the k-th processed step
+func (r *Recurrent) position(k int) int {
+	if r.reverse {
+		return r.input.Width - 1 - k
+	}
+	return k
+}
+
+// processes all steps and returns the output, keep also returns the values of each step for backward
+func (r *Recurrent) run(input mat.Dense, keep bool) (mat.Dense, []recurrentStep) {
+	_, samples := input.Dims()
+	steps := r.input.Width
+	state := r.zeroState(samples)
+
+	var output *mat.Dense
+	if r.sequences {
+		output = mat.NewDense(r.units*steps, samples, nil)
+	}
+	var cache []recurrentStep
+	for k := 0; k < steps; k++ {
+		t := r.position(k)
+		x := r.stepInput(input, t)
+
+		var inputs mat.Dense
+		inputs.Mul(&r.weights, &x)
+		for i := 0; i < r.bias.Len(); i++ {
+			floats.AddConst(r.bias.AtVec(i), inputs.RawRowView(i))
+		}
+
+		next, values := r.cell.step(inputs, state, &r.recurrent)
+		if keep {
+			cache = append(cache, recurrentStep{x, state, values})
+		}
+		state = next
+
+		if r.sequences {
+			for u := 0; u < r.units; u++ {
+				copy(output.RawRowView(u*steps+t), state.hidden.RawRowView(u))
+			}
+		}
+	}
+
+	if !r.sequences {
+		return state.hidden, cache
+	}
+	return *output, cache
+}
+
+// returns the features of step t of all samples
+func (r *Recurrent) stepInput(input mat.Dense, t int) mat.Dense {
+	_, samples := input.Dims()
+	x := mat.NewDense(r.input.Channels, samples, nil)
+	for f := 0; f < r.input.Channels; f++ {
+		copy(x.RawRowView(f), input.RawRowView(f*r.input.Width+t))
+	}
+	return *x
+}
+
+// backpropagation through time, the gradients of all steps are added up
+//
+// with WithTruncatedBPTT the gradient of the hidden state is reset at the beginning of each chunk
+func (r *Recurrent) backward(outputGradient mat.Dense) mat.Dense {
+	_, samples := outputGradient.Dims()
+	steps := r.input.Width
+	features := r.input.Channels
+
+	r.weightsGradient.Zero()
+	r.recurrentGradient.Zero()
+	r.biasGradient.Zero()
+
+	ans := mat.NewDense(features*steps, samples, nil)
+	gradient := r.zeroState(samples)
+	if !r.sequences {
+		gradient.hidden.Copy(&outputGradient)
+	}
+	for k := steps - 1; k >= 0; k-- {
+		t := r.position(k)
+		if r.sequences {
+			for u := 0; u < r.units; u++ {
+				floats.Add(gradient.hidden.RawRowView(u), outputGradient.RawRowView(u*steps+t))
+			}
+		}
+
+		step := r.steps[k]
+		inputsGradient, previous := r.cell.backstep(gradient, step.previous, step.values, &r.recurrent, &r.recurrentGradient)
+
+		var weightsGradient mat.Dense
+		weightsGradient.Mul(&inputsGradient, step.input.T())
+		r.weightsGradient.Add(&r.weightsGradient, &weightsGradient)
+		for i := 0; i < r.biasGradient.Len(); i++ {
+			r.biasGradient.SetVec(i, r.biasGradient.AtVec(i)+floats.Sum(inputsGradient.RawRowView(i)))
+		}
+
+		var inputGradient mat.Dense
+		inputGradient.Mul(r.weights.T(), &inputsGradient)
+		for f := 0; f < features; f++ {
+			copy(ans.RawRowView(f*steps+t), inputGradient.RawRowView(f))
+		}
+
+		gradient = previous
+		if r.truncation > 0 && k%r.truncation == 0 {
+			gradient = r.zeroState(samples)
+		}
+	}
+	return *ans
+}
+
+// returns a state with zeros for all samples
+func (r *Recurrent) zeroState(samples int) recurrentState {
+	return recurrentState{*mat.NewDense(r.units, samples, nil), *mat.NewDense(r.units, samples, nil)}
+}
+
+func (r *Recurrent) state() layerState {
+	return layerState{
+		Type:   r.kind,
+		Shape:  []int{r.input.Channels, r.input.Width, r.units},
+		Config: []float64{boolToFloat(r.sequences), float64(r.truncation), boolToFloat(r.reverse)},
+	}
+}
+
+func (r *Recurrent) replica() Layer {
+	rows, features := r.weights.Dims()
+	return &Recurrent{
+		kind:              r.kind,
+		cell:              r.cell,
+		input:             r.input,
+		units:             r.units,
+		sequences:         r.sequences,
+		truncation:        r.truncation,
+		reverse:           r.reverse,
+		weights:           r.weights,
+		recurrent:         r.recurrent,
+		bias:              r.bias,
+		weightsGradient:   *mat.NewDense(rows, features, nil),
+		recurrentGradient: *mat.NewDense(rows, r.units, nil),
+		biasGradient:      *mat.NewVecDense(rows, nil),
+	}
+}
+
+// returns the weights, the recurrent weights and the bias together with their gradients
+func (r *Recurrent) params() []Param {
+	return []Param{
+		{Value: r.weights.RawMatrix().Data, Gradient: r.weightsGradient.RawMatrix().Data},
+		{Value: r.recurrent.RawMatrix().Data, Gradient: r.recurrentGradient.RawMatrix().Data},
+		{Value: r.bias.RawVector().Data, Gradient: r.biasGradient.RawVector().Data},
+	}
+}
+
+// runs two recurrent layers over the same sequences, one from the first to the last step
+// and one from the last to the first step, and stacks their outputs
+//
+// the output has the shape Signal(forward units + backward units, positions),
+// the outputs of the backward layer are aligned with the steps of the input
+// without WithReturnSequences the backward layer returns its state after the first step
+type Bidirectional struct {
+	forwardLayer  *Recurrent
+	backwardLayer *Recurrent
+}
+
+// constructor for Bidirectional layer
+//
+// both layers need the same input shape and both or none need WithReturnSequences
+// backward is changed to process the steps in reverse order, so it shouldn't be used on its own anymore
+func NewBidirectional(forward, backward *Recurrent) (*Bidirectional, error) {
+	if forward == nil || backward == nil {
+		return nil, fmt.Errorf("layers must not be nil")
+	}
+	if forward == backward {
+		return nil, fmt.Errorf("forward and backward layer must be different layers")
+	}
+	if forward.reverse {
+		return nil, fmt.Errorf("forward layer is already used as backward layer")
+	}
+	if forward.input != backward.input {
+		return nil, fmt.Errorf("input shapes %v and %v should match", forward.input, backward.input)
+	}
+	if forward.sequences != backward.sequences {
+		return nil, fmt.Errorf("both or none of the layers need to return sequences")
+	}
+
+	backward.reverse = true
+	return &Bidirectional{forward, backward}, nil
+}
+
+// returns the shape of the output of one sample
+func (b *Bidirectional) OutputShape() Shape {
+	shape := b.forwardLayer.OutputShape()
+	shape.Channels += b.backwardLayer.units
+	return shape
+}
+
+func (b *Bidirectional) forward(input mat.Dense) mat.Dense {
+	forward, backward := b.forwardLayer.forward(input), b.backwardLayer.forward(input)
+	var ans mat.Dense
+	ans.Stack(&forward, &backward)
+	return ans
+}
+
+func (b *Bidirectional) predict(input mat.Dense) mat.Dense {
+	forward, backward := b.forwardLayer.predict(input), b.backwardLayer.predict(input)
+	var ans mat.Dense
+	ans.Stack(&forward, &backward)
+	return ans
+}
+
+// splits the gradient between the layers and adds up their input gradients
+func (b *Bidirectional) backward(outputGradient mat.Dense) mat.Dense {
+	rows, samples := outputGradient.Dims()
+	split := b.forwardLayer.OutputShape().Size()
+	forward := b.forwardLayer.backward(*mat.DenseCopyOf(outputGradient.Slice(0, split, 0, samples)))
+	backward := b.backwardLayer.backward(*mat.DenseCopyOf(outputGradient.Slice(split, rows, 0, samples)))
+
+	var ans mat.Dense
+	ans.Add(&forward, &backward)
+	return ans
+}
+
+// the state of both layers is saved in Layers
+func (b *Bidirectional) state() layerState {
+	return layerState{Type: "bidirectional", Layers: []layerState{b.forwardLayer.state(), b.backwardLayer.state()}}
+}
+
+func (b *Bidirectional) replica() Layer {
+	return &Bidirectional{b.forwardLayer.replica().(*Recurrent), b.backwardLayer.replica().(*Recurrent)}
+}
+
+// returns the parameters of the forward layer followed by the parameters of the backward layer
+func (b *Bidirectional) params() []Param {
+	return append(b.forwardLayer.params(), b.backwardLayer.params()...)
+}
+
+// hidden = tanh(inputs + recurrent * previous hidden)
+type simpleCell struct{}
+
+func (simpleCell) gates() int {
+	return 1
+}
+
+func (simpleCell) step(inputs mat.Dense, previous recurrentState, recurrent *mat.Dense) (recurrentState, []mat.Dense) {
+	var hidden mat.Dense
+	hidden.Mul(recurrent, &previous.hidden)
+	hidden.Add(&hidden, &inputs)
+	hidden.Apply(func(i, j int, v float64) float64 {
+		return math.Tanh(v)
+	}, &hidden)
+	return recurrentState{hidden: hidden}, []mat.Dense{hidden}
+}
+
+func (simpleCell) backstep(gradient, previous recurrentState, values []mat.Dense, recurrent, recurrentGradient *mat.Dense) (mat.Dense, recurrentState) {
+	var ans mat.Dense
+	ans.Apply(func(i, j int, h float64) float64 {
+		return gradient.hidden.At(i, j) * (1 - h*h)
+	}, &values[0])
+	addProduct(recurrentGradient, &ans, previous.hidden.T())
+
+	var hidden mat.Dense
+	hidden.Mul(recurrent.T(), &ans)
+	return ans, recurrentState{hidden: hidden}
+}
+
+// the gates are saved in the order input, forget, candidate and output
+type lstmCell struct{}
+
+func (lstmCell) gates() int {
+	return 4
+}
+
+// the activated gates are kept for backstep
+func (lstmCell) step(inputs mat.Dense, previous recurrentState, recurrent *mat.Dense) (recurrentState, []mat.Dense) {
+	units, samples := previous.hidden.Dims()
+	size := units * samples
+
+	var gates mat.Dense
+	gates.Mul(recurrent, &previous.hidden)
+	gates.Add(&gates, &inputs)
+
+	hidden := mat.NewDense(units, samples, nil)
+	cell := mat.NewDense(units, samples, nil)
+	g := gates.RawMatrix().Data
+	h, c := hidden.RawMatrix().Data, cell.RawMatrix().Data
+	previousCell := previous.cell.RawMatrix().Data
+	for k := 0; k < size; k++ {
+		in, forget := Sigmoid(g[k]), Sigmoid(g[size+k])
+		candidate, out := math.Tanh(g[2*size+k]), Sigmoid(g[3*size+k])
+		g[k], g[size+k], g[2*size+k], g[3*size+k] = in, forget, candidate, out
+
+		c[k] = forget*previousCell[k] + in*candidate
+		h[k] = out * math.Tanh(c[k])
+	}
+	return recurrentState{*hidden, *cell}, []mat.Dense{gates}
+}
+
+func (lstmCell) backstep(gradient, previous recurrentState, values []mat.Dense, recurrent, recurrentGradient *mat.Dense) (mat.Dense, recurrentState) {
+	units, samples := previous.hidden.Dims()
+	size := units * samples
+
+	ans := mat.NewDense(4*units, samples, nil)
+	previousCellGradient := mat.NewDense(units, samples, nil)
+	g, a := values[0].RawMatrix().Data, ans.RawMatrix().Data
+	hiddenGradient, cellGradient := gradient.hidden.RawMatrix().Data, gradient.cell.RawMatrix().Data
+	previousCell, p := previous.cell.RawMatrix().Data, previousCellGradient.RawMatrix().Data
+	for k := 0; k < size; k++ {
+		in, forget, candidate, out := g[k], g[size+k], g[2*size+k], g[3*size+k]
+		tanhCell := math.Tanh(forget*previousCell[k] + in*candidate)
+
+		dc := cellGradient[k] + hiddenGradient[k]*out*(1-tanhCell*tanhCell)
+		a[k] = dc * candidate * in * (1 - in)
+		a[size+k] = dc * previousCell[k] * forget * (1 - forget)
+		a[2*size+k] = dc * in * (1 - candidate*candidate)
+		a[3*size+k] = hiddenGradient[k] * tanhCell * out * (1 - out)
+		p[k] = dc * forget
+	}
+	addProduct(recurrentGradient, ans, previous.hidden.T())
+
+	var hidden mat.Dense
+	hidden.Mul(recurrent.T(), ans)
+	return *ans, recurrentState{hidden, *previousCellGradient}
+}
+
+// the gates are saved in the order update, reset and candidate
+type gruCell struct{}
+
+func (gruCell) gates() int {
+	return 3
+}
+
+// the activated gates and reset * previous hidden are kept for backstep
+func (gruCell) step(inputs mat.Dense, previous recurrentState, recurrent *mat.Dense) (recurrentState, []mat.Dense) {
+	units, samples := previous.hidden.Dims()
+	size := units * samples
+
+	var updateReset mat.Dense
+	updateReset.Mul(recurrent.Slice(0, 2*units, 0, units), &previous.hidden)
+
+	gates := mat.DenseCopyOf(&inputs)
+	reset := mat.NewDense(units, samples, nil)
+	g, ur, r := gates.RawMatrix().Data, updateReset.RawMatrix().Data, reset.RawMatrix().Data
+	previousHidden := previous.hidden.RawMatrix().Data
+	for k := 0; k < 2*size; k++ {
+		g[k] = Sigmoid(g[k] + ur[k])
+	}
+	for k := 0; k < size; k++ {
+		r[k] = g[size+k] * previousHidden[k]
+	}
+
+	var candidates mat.Dense
+	candidates.Mul(recurrent.Slice(2*units, 3*units, 0, units), reset)
+
+	hidden := mat.NewDense(units, samples, nil)
+	c, h := candidates.RawMatrix().Data, hidden.RawMatrix().Data
+	for k := 0; k < size; k++ {
+		update, candidate := g[k], math.Tanh(g[2*size+k]+c[k])
+		g[2*size+k] = candidate
+		h[k] = update*previousHidden[k] + (1-update)*candidate
+	}
+	return recurrentState{hidden: *hidden}, []mat.Dense{*gates, *reset}
+}
+
+func (gruCell) backstep(gradient, previous recurrentState, values []mat.Dense, recurrent, recurrentGradient *mat.Dense) (mat.Dense, recurrentState) {
+	units, samples := previous.hidden.Dims()
+	size := units * samples
+
+	ans := mat.NewDense(3*units, samples, nil)
+	previousGradient := mat.NewDense(units, samples, nil)
+	g, a, p := values[0].RawMatrix().Data, ans.RawMatrix().Data, previousGradient.RawMatrix().Data
+	hiddenGradient, previousHidden := gradient.hidden.RawMatrix().Data, previous.hidden.RawMatrix().Data
+	for k := 0; k < size; k++ {
+		update, candidate := g[k], g[2*size+k]
+		a[k] = hiddenGradient[k] * (previousHidden[k] - candidate) * update * (1 - update)
+		a[2*size+k] = hiddenGradient[k] * (1 - update) * (1 - candidate*candidate)
+		p[k] = hiddenGradient[k] * update
+	}
+
+	// the candidate uses reset * previous hidden
+	candidateGradient := ans.Slice(2*units, 3*units, 0, samples)
+	candidateRecurrent := recurrent.Slice(2*units, 3*units, 0, units)
+	addProduct(recurrentGradient.Slice(2*units, 3*units, 0, units).(*mat.Dense), candidateGradient, values[1].T())
+	var resetGradient mat.Dense
+	resetGradient.Mul(candidateRecurrent.T(), candidateGradient)
+	r := resetGradient.RawMatrix().Data
+	for k := 0; k < size; k++ {
+		reset := g[size+k]
+		a[size+k] = r[k] * previousHidden[k] * reset * (1 - reset)
+		p[k] += r[k] * reset
+	}
+
+	gateGradient := ans.Slice(0, 2*units, 0, samples)
+	gateRecurrent := recurrent.Slice(0, 2*units, 0, units)
+	addProduct(recurrentGradient.Slice(0, 2*units, 0, units).(*mat.Dense), gateGradient, previous.hidden.T())
+	var hidden mat.Dense
+	hidden.Mul(gateRecurrent.T(), gateGradient)
+	previousGradient.Add(previousGradient, &hidden)
+	return *ans, recurrentState{hidden: *previousGradient}
+}
+
+// adds a * b to dst
+func addProduct(dst *mat.Dense, a, b mat.Matrix) {
+	var product mat.Dense
+	product.Mul(a, b)
+	dst.Add(dst, &product)
+}
+
+// returns 1 for true and 0 for false
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
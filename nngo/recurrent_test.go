@@ -0,0 +1,252 @@
+package nngo
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestRecurrentOutputShape(t *testing.T) {
+	rnn, _ := NewSimpleRNN(Signal(3, 5), 4)
+	lstm, _ := NewLSTM(Signal(3, 5), 4, WithReturnSequences())
+	backward, _ := NewGRU(Signal(3, 5), 2, WithReturnSequences())
+	bidirectional, _ := NewBidirectional(lstm, backward)
+
+	tests := []struct {
+		name     string
+		ans      Shape
+		expected Shape
+	}{
+		{"LastState", rnn.OutputShape(), Signal(4, 1)},
+		{"Sequences", lstm.OutputShape(), Signal(4, 5)},
+		{"Bidirectional", bidirectional.OutputShape(), Signal(6, 5)},
+	}
+
+	for _, test := range tests {
+		if test.ans != test.expected {
+			t.Errorf("%v Expected: %v, Got: %v", test.name, test.expected, test.ans)
+		}
+	}
+}
+
+func TestNewRecurrentError(t *testing.T) {
+	if _, err := NewLSTM(Shape{1, 2, 3}, 2); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewGRU(Signal(1, 3), 0); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewSimpleRNN(Signal(1, 3), 2, WithTruncatedBPTT(-1)); err == nil {
+		t.Error("Expected error.")
+	}
+
+	first, _ := NewLSTM(Signal(1, 3), 2)
+	other, _ := NewLSTM(Signal(1, 4), 2)
+	sequences, _ := NewLSTM(Signal(1, 3), 2, WithReturnSequences())
+	if _, err := NewBidirectional(first, first); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewBidirectional(first, other); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewBidirectional(first, sequences); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestNewLSTMForgetBias(t *testing.T) {
+	lstm, _ := NewLSTM(Signal(2, 3), 2)
+	expected := []float64{0, 0, 1, 1, 0, 0, 0, 0}
+	if diff := cmp.Diff(expected, lstm.bias.RawVector().Data); diff != "" {
+		t.Errorf("Bias mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSimpleRNNForward(t *testing.T) {
+	rnn, _ := NewSimpleRNN(Signal(1, 3), 1, WithReturnSequences())
+	rnn.weights.Set(0, 0, 0.5)
+	rnn.recurrent.Set(0, 0, 2)
+	rnn.bias.SetVec(0, 0.1)
+
+	input := mat.NewDense(3, 1, []float64{1, -1, 0.5})
+	h1 := math.Tanh(0.5 + 0.1)
+	h2 := math.Tanh(-0.5 + 2*h1 + 0.1)
+	h3 := math.Tanh(0.25 + 2*h2 + 0.1)
+	expected := mat.NewDense(3, 1, []float64{h1, h2, h3})
+
+	output := rnn.forward(*input)
+	if !mat.EqualApprox(expected, &output, 1e-12) {
+		t.Errorf("Expected: %v, Got: %v", expected, output)
+	}
+
+	// the backward layer of a Bidirectional processes the steps in reverse order, but keeps their positions
+	rnn.reverse = true
+	h3 = math.Tanh(0.25 + 0.1)
+	h2 = math.Tanh(-0.5 + 2*h3 + 0.1)
+	h1 = math.Tanh(0.5 + 2*h2 + 0.1)
+	expected = mat.NewDense(3, 1, []float64{h1, h2, h3})
+	output = rnn.predict(*input)
+	if !mat.EqualApprox(expected, &output, 1e-12) {
+		t.Errorf("Expected: %v, Got: %v", expected, output)
+	}
+}
+
+func TestRecurrentGradients(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	input := normalMatrix(2*4, 3, random)
+	for _, kind := range []string{"simple_rnn", "lstm", "gru"} {
+		for _, sequences := range []bool{false, true} {
+			config := newOptions([]Option{WithRand(random), WithBiasInit(LeCunNormal)})
+			config.sequences = sequences
+			layer, err := newRecurrent(kind, Signal(2, 4), 3, config)
+			if err != nil {
+				t.Fatalf("Didn't expect error. Got: %v", err)
+			}
+			checkGradients(t, layer, *input)
+		}
+	}
+}
+
+func TestBidirectionalGradients(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	input := normalMatrix(2*3, 2, random)
+	for _, opts := range [][]Option{nil, {WithReturnSequences()}} {
+		forward, _ := NewLSTM(Signal(2, 3), 2, append(opts, WithRand(random))...)
+		backward, _ := NewGRU(Signal(2, 3), 3, append(opts, WithRand(random))...)
+		layer, err := NewBidirectional(forward, backward)
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		checkGradients(t, layer, *input)
+	}
+}
+
+func TestRecurrentTruncatedBPTT(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	input := normalMatrix(6, 2, random)
+	full, _ := NewLSTM(Signal(1, 6), 2, WithRand(rand.New(rand.NewSource(4))))
+	truncated, _ := NewLSTM(Signal(1, 6), 2, WithRand(rand.New(rand.NewSource(4))), WithTruncatedBPTT(4))
+
+	outputGradient := mat.NewDense(2, 2, []float64{1, -1, 0.5, 2})
+	full.forward(*input)
+	fullGradient := full.backward(*outputGradient)
+	truncated.forward(*input)
+	truncatedGradient := truncated.backward(*outputGradient)
+
+	// the chunks are steps 0-3 and 4-5, only the last chunk gets the gradient of the last state
+	for step := 0; step < 6; step++ {
+		for n := 0; n < 2; n++ {
+			ans := truncatedGradient.At(step, n)
+			if step < 4 && ans != 0 {
+				t.Errorf("Expected: %v, Got: %v", 0, ans)
+			}
+			if step >= 4 && ans != fullGradient.At(step, n) {
+				t.Errorf("Expected: %v, Got: %v", fullGradient.At(step, n), ans)
+			}
+		}
+	}
+}
+
+func TestNewSequenceSet(t *testing.T) {
+	sequences := [][][]float64{
+		{{1, 10}, {2, 20}, {3, 30}},
+		{{4, 40}},
+	}
+	set, err := NewSequenceSet(sequences, [][]float64{{1}, {0}})
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	if ans := set.Shape(); ans != Signal(2, 3) {
+		t.Errorf("Expected: %v, Got: %v", Signal(2, 3), ans)
+	}
+	// the shorter sequence is padded at the beginning
+	expected := mat.NewDense(6, 2, []float64{
+		1, 0,
+		2, 0,
+		3, 4,
+		10, 0,
+		20, 0,
+		30, 40,
+	})
+	if !mat.Equal(expected, &set.Data) {
+		t.Errorf("Expected: %v, Got: %v", expected, set.Data)
+	}
+
+	if _, err := NewSequenceSet(sequences, [][]float64{{1}}); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewSequenceSet([][][]float64{{{1}, {2, 3}}}, [][]float64{{1}}); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewSequenceSet([][][]float64{{}}, [][]float64{{1}}); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestRecurrentSaveLoad(t *testing.T) {
+	random := rand.New(rand.NewSource(5))
+	rnn, _ := NewSimpleRNN(Signal(2, 4), 3, WithRand(random), WithReturnSequences(), WithTruncatedBPTT(2))
+	forward, _ := NewLSTM(rnn.OutputShape(), 2, WithRand(random))
+	backward, _ := NewGRU(rnn.OutputShape(), 2, WithRand(random))
+	bidirectional, _ := NewBidirectional(forward, backward)
+	dense, _ := NewDense(4, 1, WithRand(random))
+	network, err := NewSequential(MseLoss, rnn, bidirectional, dense)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := network.Save(&buffer); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	loaded, err := Load(&buffer)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	checkSamePredictions(t, network, loaded, *normalMatrix(8, 3, random))
+
+	if diff := cmp.Diff(rnn.state(), loaded.layers[0].state()); diff != "" {
+		t.Errorf("State mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRecurrentTrain(t *testing.T) {
+	// the label is the first value of the sequence, so the layer needs to remember it
+	random := rand.New(rand.NewSource(6))
+	var sequences [][][]float64
+	var labels [][]float64
+	for i := 0; i < 64; i++ {
+		sequence := make([][]float64, 5)
+		for step := range sequence {
+			sequence[step] = []float64{random.Float64()*2 - 1}
+		}
+		sequences = append(sequences, sequence)
+		labels = append(labels, []float64{sequence[0][0]})
+	}
+	set, err := NewSequenceSet(sequences, labels)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	for _, kind := range []string{"simple_rnn", "lstm", "gru"} {
+		layer, _ := newRecurrent(kind, set.Shape(), 8, newOptions([]Option{WithRand(rand.New(rand.NewSource(7)))}))
+		dense, _ := NewDense(8, 1, WithRand(rand.New(rand.NewSource(8))))
+		network, _ := NewSequential(MseLoss, layer, dense)
+		adam, _ := NewAdam(0.9, 0.999)
+		network.SetOptimizer(adam)
+
+		history, err := network.Train(&set.Set, 60, 16, ConstantRate(0.01), WithSeed(9), WithWorkers(2), WithWriter(nil))
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		loss := history.Loss()
+		if loss[len(loss)-1] > loss[0]/4 {
+			t.Errorf("%v Expected the loss to decrease from %v, Got: %v", kind, loss[0], loss[len(loss)-1])
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package nngo
+
+import (
+	"fmt"
+	"sync"
+)
+
+// registries map the names of losses and activation functions to their values
+// all built in functions are registered, custom functions can be added
+//
+// names are used to reference the functions without the value, e.g. in saved models
+var (
+	registryMutex      sync.RWMutex
+	lossRegistry       = map[string]Loss{}
+	activationRegistry = map[string]ActivationFunction{}
+)
+
+func init() {
+	for _, loss := range lossTuples {
+		lossRegistry[loss.Name()] = loss
+	}
+	for _, activation := range activationTuples {
+		if activation.name != "" {
+			activationRegistry[activation.Name()] = activation
+		}
+	}
+	activationRegistry["leaky_relu"] = LeakyRelu{0.01}
+	activationRegistry["elu"] = Elu{1}
+	activationRegistry["prelu"] = NewPRelu(0.25)
+}
+
+// adds a loss to the registry
+// the name needs to be unique
+func RegisterLoss(loss Loss) error {
+	if loss == nil || loss.Name() == "" {
+		return fmt.Errorf("loss needs a name")
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, ok := lossRegistry[loss.Name()]; ok {
+		return fmt.Errorf("loss %q is already registered", loss.Name())
+	}
+	lossRegistry[loss.Name()] = loss
+	return nil
+}
+
+// returns the registered loss with the given name
+func LookupLoss(name string) (Loss, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	loss, ok := lossRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("loss %q is not registered", name)
+	}
+	return loss, nil
+}
+
+// adds an activation function to the registry
+// the name needs to be unique
+func RegisterActivation(activation ActivationFunction) error {
+	if activation == nil || activation.Name() == "" {
+		return fmt.Errorf("activation function needs a name")
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, ok := activationRegistry[activation.Name()]; ok {
+		return fmt.Errorf("activation function %q is already registered", activation.Name())
+	}
+	activationRegistry[activation.Name()] = activation
+	return nil
+}
+
+// returns the registered activation function with the given name
+//
+// parameterised functions like PRelu are returned as a new copy with the default parameters,
+// so they can be used by different layers
+func LookupActivation(name string) (ActivationFunction, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	activation, ok := activationRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("activation function %q is not registered", name)
+	}
+	if c, ok := activation.(configurableActivation); ok {
+		return c.withConfig(c.config())
+	}
+	return activation, nil
+}
@@ -0,0 +1,165 @@
+package nngo
+
+import (
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestLookupBuiltIn(t *testing.T) {
+	for _, name := range []string{"mse", "mae", "categorical_crossentropy", "kl_divergence"} {
+		loss, err := LookupLoss(name)
+		if err != nil || loss.Name() != name {
+			t.Errorf("Expected loss %v, Got: %v, %v", name, loss, err)
+		}
+	}
+
+	for _, name := range []string{"sigmoid", "relu", "tanh"} {
+		activation, err := LookupActivation(name)
+		if err != nil || activation.Name() != name {
+			t.Errorf("Expected activation %v, Got: %v, %v", name, activation, err)
+		}
+	}
+
+	if _, err := LookupLoss("unknown"); err == nil {
+		t.Error("Expected error.")
+	}
+
+	if _, err := LookupActivation("unknown"); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestRegisterLoss(t *testing.T) {
+	loss := NewLoss("test_registered_loss", Mse, MseDerivative)
+	if err := RegisterLoss(loss); err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	found, err := LookupLoss("test_registered_loss")
+	if err != nil || found.Name() != loss.Name() {
+		t.Errorf("Expected: %v, Got: %v", loss.Name(), found)
+	}
+
+	t.Run("Duplicate", func(t *testing.T) {
+		if err := RegisterLoss(NewLoss("mse", Mae, MaeDerivative)); err == nil {
+			t.Error("Expected error.")
+		}
+	})
+
+	t.Run("EmptyName", func(t *testing.T) {
+		if err := RegisterLoss(NewLoss("", Mae, MaeDerivative)); err == nil {
+			t.Error("Expected error.")
+		}
+	})
+}
+
+func TestRegisterActivation(t *testing.T) {
+	identity := func(x float64) float64 { return x }
+	one := func(x float64) float64 { return 1 }
+
+	activation := NewActivationFunction("test_identity", identity, one)
+	if err := RegisterActivation(activation); err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	found, err := LookupActivation("test_identity")
+	if err != nil || found.Activate(3) != 3 || found.Derivative(3) != 1 {
+		t.Errorf("Expected identity, Got: %v", found)
+	}
+
+	if err := RegisterActivation(NewActivationFunction("relu", identity, one)); err == nil {
+		t.Error("Expected error.")
+	}
+
+	if err := RegisterActivation(nil); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestNewSequential(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	labels := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	set := Set{*data, *labels}
+
+	// custom activation and loss are passed directly
+	leaky := NewActivationFunction("test_leaky", func(x float64) float64 {
+		if x < 0 {
+			return 0.1 * x
+		}
+		return x
+	}, func(x float64) float64 {
+		if x < 0 {
+			return 0.1
+		}
+		return 1
+	})
+	loss := NewLoss("test_squared_error", Mse, MseDerivative)
+
+	dense, _ := NewDense(2, 4)
+	activation, err := NewActivationWith(4, leaky)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+	output, _ := NewDense(4, 2)
+	outputActivation, _ := NewActivationWith(2, TanhActivation)
+
+	network, err := NewSequential(loss, dense, activation, output, outputActivation)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	before, _, _ := network.batchLoss(set.Labels, network.forward(set.Data))
+	if _, err := network.Train(&set, 20, 4, ConstantRate(0.01)); err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+	after, _, _ := network.batchLoss(set.Labels, network.forward(set.Data))
+
+	if after >= before {
+		t.Errorf("Expected loss to decrease. Before: %v, After: %v", before, after)
+	}
+}
+
+func TestNewSequentialError(t *testing.T) {
+	dense, _ := NewDense(2, 2)
+
+	if _, err := NewSequential(nil, dense); err == nil {
+		t.Error("Expected error.")
+	}
+
+	if _, err := NewSequential(MseLoss); err == nil {
+		t.Error("Expected error.")
+	}
+
+	if _, err := NewSequential(MseLoss, dense, nil); err == nil {
+		t.Error("Expected error.")
+	}
+
+	if _, err := NewActivationWith(2, nil); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestNewSequentialFusedSoftmax(t *testing.T) {
+	dense, _ := NewDense(2, 3)
+	softmax, _ := NewSoftmax(3)
+
+	network, err := NewSequential(CategoricalCrossEntropyLoss, dense, softmax)
+	if err != nil || !network.fusedSoftmax {
+		t.Error("Expected softmax and cross entropy to be fused")
+	}
+}
+
+func TestLookupActivationCopy(t *testing.T) {
+	first, err := LookupActivation("prelu")
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+	second, _ := LookupActivation("prelu")
+
+	// each lookup returns a new PRelu, so layers don't share their slope
+	first.(*PRelu).alpha[0] = 1
+	if second.(*PRelu).Alpha() != 0.25 {
+		t.Errorf("Expected: %v, Got: %v", 0.25, second.(*PRelu).Alpha())
+	}
+}
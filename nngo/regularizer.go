@@ -0,0 +1,145 @@
+package nngo
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// penalty on the weights of a layer, that is added to the loss during training
+//
+// penalty = L1 * sum(|w|) + L2 * sum(w^2)
+// the gradient of the penalty is added to the gradient of the weights before each update
+type Regularizer struct {
+	L1 float64
+	L2 float64
+}
+
+// regularizer with the L1 penalty, which pushes single weights to zero
+func L1(factor float64) Regularizer {
+	return Regularizer{L1: factor}
+}
+
+// regularizer with the L2 penalty, also known as weight decay
+func L2(factor float64) Regularizer {
+	return Regularizer{L2: factor}
+}
+
+// regularizer with the L1 and the L2 penalty
+func ElasticNet(l1, l2 float64) Regularizer {
+	return Regularizer{L1: l1, L2: l2}
+}
+
+func (r Regularizer) validate() error {
+	if r.L1 < 0 || r.L2 < 0 {
+		return fmt.Errorf("regularization factors must not be negative")
+	}
+	return nil
+}
+
+func (r Regularizer) penalty(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += r.L1*math.Abs(v) + r.L2*v*v
+	}
+	return sum
+}
+
+// adds the gradient of the penalty to gradient
+func (r Regularizer) addGradient(values, gradient []float64) {
+	for i, v := range values {
+		sign := 0.0
+		if v > 0 {
+			sign = 1
+		} else if v < 0 {
+			sign = -1
+		}
+		gradient[i] += r.L1*sign + 2*r.L2*v
+	}
+}
+
+// names of the constraints
+const (
+	constraintMaxNorm  = "max_norm"
+	constraintNonNeg   = "non_neg"
+	constraintUnitNorm = "unit_norm"
+)
+
+// restriction of the weights of a layer, that is applied after each update of the optimizer
+//
+// the norms are calculated for the incoming weights of each neuron, i.e. each row of the weight matrix
+// the zero value doesn't restrict the weights
+type Constraint struct {
+	name  string
+	value float64
+}
+
+// scales the incoming weights of each neuron down, if their norm is larger than max
+// paper: https://jmlr.org/papers/v15/srivastava14a.html
+func MaxNorm(max float64) Constraint {
+	return Constraint{constraintMaxNorm, max}
+}
+
+// sets negative weights to zero
+func NonNeg() Constraint {
+	return Constraint{name: constraintNonNeg}
+}
+
+// scales the incoming weights of each neuron to norm 1
+func UnitNorm() Constraint {
+	return Constraint{name: constraintUnitNorm}
+}
+
+// creates the constraint from its name and value, see state of Dense
+func constraintFromState(name string, value float64) (Constraint, error) {
+	constraint := Constraint{name, value}
+	return constraint, constraint.validate()
+}
+
+func (c Constraint) validate() error {
+	switch c.name {
+	case "", constraintNonNeg, constraintUnitNorm:
+		return nil
+	case constraintMaxNorm:
+		if c.value <= 0 {
+			return fmt.Errorf("max norm must be greater than 0")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown constraint %q", c.name)
+	}
+}
+
+// applies the constraint to the weights in place
+func (c Constraint) apply(weights *mat.Dense) {
+	rows, _ := weights.Dims()
+	switch c.name {
+	case constraintNonNeg:
+		data := weights.RawMatrix().Data
+		for i, v := range data {
+			if v < 0 {
+				data[i] = 0
+			}
+		}
+	case constraintMaxNorm, constraintUnitNorm:
+		for i := 0; i < rows; i++ {
+			row := weights.RawRowView(i)
+			norm := 0.0
+			for _, v := range row {
+				norm += v * v
+			}
+			norm = math.Sqrt(norm)
+
+			scale := 1.0
+			if c.name == constraintUnitNorm && norm > 0 {
+				scale = 1 / norm
+			} else if c.name == constraintMaxNorm && norm > c.value {
+				scale = c.value / norm
+			}
+			for j := range row {
+				row[j] *= scale
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package nngo
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestRegularizer(t *testing.T) {
+	regularizer := ElasticNet(0.1, 0.2)
+	values := []float64{1, -2, 0}
+
+	if ans := regularizer.penalty(values); math.Abs(ans-1.3) > 1e-12 {
+		t.Errorf("Expected: %v, Got: %v", 1.3, ans)
+	}
+
+	gradient := []float64{1, 1, 1}
+	regularizer.addGradient(values, gradient)
+	expected := []float64{1.5, 0.1, 1}
+	if diff := cmp.Diff(expected, gradient, cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+		t.Errorf("Gradient mismatch (-want +got):\n%s", diff)
+	}
+
+	if L1(0.5) != (Regularizer{L1: 0.5}) || L2(0.5) != (Regularizer{L2: 0.5}) {
+		t.Errorf("Expected: %v and %v, Got: %v and %v", Regularizer{L1: 0.5}, Regularizer{L2: 0.5}, L1(0.5), L2(0.5))
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		name       string
+		constraint Constraint
+		expected   []float64
+	}{
+		{"MaxNorm", MaxNorm(1), []float64{0.6, -0.8, 0.3, 0.4}},
+		{"NonNeg", NonNeg(), []float64{3, 0, 0.3, 0.4}},
+		{"UnitNorm", UnitNorm(), []float64{0.6, -0.8, 0.6, 0.8}},
+		{"None", Constraint{}, []float64{3, -4, 0.3, 0.4}},
+	}
+
+	for _, test := range tests {
+		weights := mat.NewDense(2, 2, []float64{3, -4, 0.3, 0.4})
+		test.constraint.apply(weights)
+		if diff := cmp.Diff(test.expected, weights.RawMatrix().Data, cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+			t.Errorf("%v mismatch (-want +got):\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestNewDenseRegularizerError(t *testing.T) {
+	if _, err := NewDense(2, 2, WithRegularizer(L2(-1))); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := NewDense(2, 2, WithConstraint(MaxNorm(0))); err == nil {
+		t.Error("Expected error.")
+	}
+	if _, err := constraintFromState("unknown", 0); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestTrainRegularizer(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	set := Set{*data, *data}
+
+	newNetwork := func(opts ...Option) *Network {
+		opts = append(opts, WithRand(rand.New(rand.NewSource(1))))
+		network, err := NewNetwork([]LayerSpec{{2, 3, ActivationTanh}, {3, 2, ActivationSigmoid}}, LossMse, opts...)
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		return network
+	}
+
+	// without updates the reported losses differ exactly by the penalty
+	plain := newNetwork()
+	regularized := newNetwork(WithRegularizer(ElasticNet(0.01, 0.1)))
+	plainHistory, _ := plain.Train(&set, 1, 4, ConstantRate(0), WithValidation(&set), WithWriter(nil))
+	history, _ := regularized.Train(&set, 1, 4, ConstantRate(0), WithValidation(&set), WithWriter(nil))
+
+	penalty := 0.0
+	for _, layer := range regularized.layers {
+		if d, ok := layer.(*Dense); ok {
+			penalty += 0.01*floats.Norm(d.weights.RawMatrix().Data, 1) + 0.1*math.Pow(floats.Norm(d.weights.RawMatrix().Data, 2), 2)
+		}
+	}
+	if ans := history.Loss()[0] - plainHistory.Loss()[0]; math.Abs(ans-penalty) > 1e-9 {
+		t.Errorf("Expected: %v, Got: %v", penalty, ans)
+	}
+	if ans := history.ValidationLoss()[0] - plainHistory.ValidationLoss()[0]; math.Abs(ans-penalty) > 1e-9 {
+		t.Errorf("Expected: %v, Got: %v", penalty, ans)
+	}
+
+	// the penalty shrinks the weights
+	if _, err := plain.Train(&set, 20, 4, ConstantRate(0.5), WithWriter(nil)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if _, err := regularized.Train(&set, 20, 4, ConstantRate(0.5), WithWriter(nil)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if regularized.penalty() >= newNetwork(WithRegularizer(ElasticNet(0.01, 0.1))).penalty() {
+		t.Errorf("Expected a smaller penalty than %v, Got: %v", newNetwork(WithRegularizer(ElasticNet(0.01, 0.1))).penalty(), regularized.penalty())
+	}
+}
+
+func TestTrainConstraint(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	set := Set{*data, *data}
+
+	network, err := NewNetwork([]LayerSpec{{2, 3, ActivationTanh}, {3, 2, ActivationSigmoid}}, LossMse, WithConstraint(MaxNorm(0.5)), WithRegularizer(L2(0.001)))
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if _, err := network.Train(&set, 5, 2, ConstantRate(1), WithWorkers(2), WithWriter(nil)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	for _, layer := range network.layers {
+		d, ok := layer.(*Dense)
+		if !ok {
+			continue
+		}
+		rows, _ := d.weights.Dims()
+		for i := 0; i < rows; i++ {
+			if norm := floats.Norm(d.weights.RawRowView(i), 2); norm > 0.5+1e-12 {
+				t.Errorf("Expected norm at most %v, Got: %v", 0.5, norm)
+			}
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := network.Save(&buffer); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	loaded, err := Load(&buffer)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if d := loaded.layers[0].(*Dense); d.constraint != MaxNorm(0.5) || d.regularizer != L2(0.001) {
+		t.Errorf("Expected: %v and %v, Got: %v and %v", MaxNorm(0.5), L2(0.001), d.constraint, d.regularizer)
+	}
+}
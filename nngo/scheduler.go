@@ -0,0 +1,282 @@
+package nngo
+
+import (
+	"fmt"
+	"math"
+)
+
+// a scheduler returns the learning rate that is used for the next batch
+//
+// epoch is the current epoch and step the number of batches
+// that were already trained over all epochs, both start at 0
+type Scheduler interface {
+	LearningRate(epoch, step int) float64
+}
+
+// schedulers that implement Observer get the monitored loss at the end of each epoch
+type Observer interface {
+	Observe(loss float64)
+}
+
+// schedulers with state that changes during training implement snapshotScheduler,
+// so the state can be written to checkpoints
+type snapshotScheduler interface {
+	snapshot() []float64
+	restore(state []float64) error
+}
+
+// constant learning rate for the whole training
+type ConstantRate float64
+
+func (rate ConstantRate) LearningRate(epoch, step int) float64 {
+	return float64(rate)
+}
+
+// multiplies the learning rate with factor every few epochs
+//
+// rate = initial * factor^(epoch / every)
+type StepDecay struct {
+	initial float64
+	factor  float64
+	every   int
+}
+
+// constructor for StepDecay
+//
+// initial and every need to be positive, factor needs to be between 0 and 1
+func NewStepDecay(initial, factor float64, every int) (*StepDecay, error) {
+	if initial <= 0 || every <= 0 {
+		return nil, fmt.Errorf("initial and every must be greater than 0")
+	}
+	if factor <= 0 || factor > 1 {
+		return nil, fmt.Errorf("factor should be a value between 0 and 1")
+	}
+	return &StepDecay{initial, factor, every}, nil
+}
+
+func (s *StepDecay) LearningRate(epoch, step int) float64 {
+	return s.initial * math.Pow(s.factor, float64(epoch/s.every))
+}
+
+// decays the learning rate exponentially with each epoch
+//
+// rate = initial * decay^epoch
+type ExponentialDecay struct {
+	initial float64
+	decay   float64
+}
+
+// constructor for ExponentialDecay
+//
+// initial needs to be positive, decay needs to be between 0 and 1
+func NewExponentialDecay(initial, decay float64) (*ExponentialDecay, error) {
+	if initial <= 0 {
+		return nil, fmt.Errorf("initial must be greater than 0")
+	}
+	if decay <= 0 || decay > 1 {
+		return nil, fmt.Errorf("decay should be a value between 0 and 1")
+	}
+	return &ExponentialDecay{initial, decay}, nil
+}
+
+func (s *ExponentialDecay) LearningRate(epoch, step int) float64 {
+	return s.initial * math.Pow(s.decay, float64(epoch))
+}
+
+// cosine annealing with warm restarts
+//
+// the learning rate follows a cosine curve from maxRate to minRate over one period
+// afterwards the learning rate restarts at maxRate and the period is multiplied by multiplier
+// paper: https://arxiv.org/abs/1608.03983
+type CosineAnnealing struct {
+	maxRate    float64
+	minRate    float64
+	period     int
+	multiplier int
+}
+
+// constructor for CosineAnnealing
+//
+// period is the length of the first cycle in epochs
+// multiplier needs to be at least 1, with 1 every cycle has the same length
+func NewCosineAnnealing(maxRate, minRate float64, period, multiplier int) (*CosineAnnealing, error) {
+	if maxRate <= 0 || minRate < 0 || minRate > maxRate {
+		return nil, fmt.Errorf("maxRate must be greater than 0 and minRate between 0 and maxRate")
+	}
+	if period <= 0 || multiplier < 1 {
+		return nil, fmt.Errorf("period must be greater than 0 and multiplier at least 1")
+	}
+	return &CosineAnnealing{maxRate, minRate, period, multiplier}, nil
+}
+
+func (s *CosineAnnealing) LearningRate(epoch, step int) float64 {
+	// find the position inside the current cycle
+	current := epoch
+	period := s.period
+	for current >= period {
+		current -= period
+		period *= s.multiplier
+	}
+
+	progress := float64(current) / float64(period)
+	return s.minRate + 0.5*(s.maxRate-s.minRate)*(1+math.Cos(math.Pi*progress))
+}
+
+// increases the learning rate linearly during the first steps
+// afterwards the wrapped scheduler is used
+//
+// during the warmup the rate of the wrapped scheduler is scaled by (step + 1) / steps
+// the monitored loss and the checkpoint state are passed on to the wrapped scheduler, e.g. ReduceOnPlateau
+type LinearWarmup struct {
+	steps int
+	after Scheduler
+}
+
+// constructor for LinearWarmup
+//
+// steps is the number of batches of the warmup
+func NewLinearWarmup(steps int, after Scheduler) (*LinearWarmup, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("steps must be greater than 0")
+	}
+	if after == nil {
+		return nil, fmt.Errorf("after scheduler must not be nil")
+	}
+	return &LinearWarmup{steps, after}, nil
+}
+
+func (s *LinearWarmup) LearningRate(epoch, step int) float64 {
+	rate := s.after.LearningRate(epoch, step)
+	if step < s.steps {
+		return rate * float64(step+1) / float64(s.steps)
+	}
+	return rate
+}
+
+// passes the loss on to the wrapped scheduler, if it is an Observer
+func (s *LinearWarmup) Observe(loss float64) {
+	if observer, ok := s.after.(Observer); ok {
+		observer.Observe(loss)
+	}
+}
+
+// the warmup only depends on the step, so the state is the one of the wrapped scheduler
+func (s *LinearWarmup) snapshot() []float64 {
+	if after, ok := s.after.(snapshotScheduler); ok {
+		return after.snapshot()
+	}
+	return nil
+}
+
+func (s *LinearWarmup) restore(state []float64) error {
+	after, ok := s.after.(snapshotScheduler)
+	if !ok {
+		return fmt.Errorf("scheduler %T can't restore the state of the checkpoint", s.after)
+	}
+	return after.restore(state)
+}
+
+// one cycle policy
+//
+// the learning rate increases from maxRate / 25 to maxRate during the first 30% of the steps
+// and afterwards decreases to maxRate / 10^4 until totalSteps, both with a cosine curve
+// paper: https://arxiv.org/abs/1708.07120
+type OneCycle struct {
+	maxRate    float64
+	totalSteps int
+}
+
+// constructor for OneCycle
+//
+// totalSteps is the number of batches over all epochs
+func NewOneCycle(maxRate float64, totalSteps int) (*OneCycle, error) {
+	if maxRate <= 0 || totalSteps <= 0 {
+		return nil, fmt.Errorf("maxRate and totalSteps must be greater than 0")
+	}
+	return &OneCycle{maxRate, totalSteps}, nil
+}
+
+func (s *OneCycle) LearningRate(epoch, step int) float64 {
+	initial := s.maxRate / 25
+	final := s.maxRate / 1e4
+	warmup := int(0.3 * float64(s.totalSteps))
+
+	if step >= s.totalSteps {
+		return final
+	}
+	if step < warmup {
+		return cosineInterpolation(initial, s.maxRate, float64(step)/float64(warmup))
+	}
+	return cosineInterpolation(s.maxRate, final, float64(step-warmup)/float64(s.totalSteps-warmup))
+}
+
+// interpolates between start and end with a cosine curve
+// progress should be between 0 and 1
+func cosineInterpolation(start, end, progress float64) float64 {
+	return end + 0.5*(start-end)*(1+math.Cos(math.Pi*progress))
+}
+
+// reduces the learning rate, when the monitored loss stops improving
+//
+// if the loss didn't improve for patience epochs, the rate is multiplied by factor
+// the rate never gets smaller than minRate
+type ReduceOnPlateau struct {
+	rate     float64
+	factor   float64
+	patience int
+	minRate  float64
+	best     float64
+	wait     int
+}
+
+// constructor for ReduceOnPlateau
+//
+// initial and patience need to be positive, factor needs to be between 0 and 1
+func NewReduceOnPlateau(initial, factor float64, patience int, minRate float64) (*ReduceOnPlateau, error) {
+	if initial <= 0 || patience <= 0 {
+		return nil, fmt.Errorf("initial and patience must be greater than 0")
+	}
+	if factor <= 0 || factor >= 1 {
+		return nil, fmt.Errorf("factor should be a value between 0 and 1")
+	}
+	if minRate < 0 || minRate > initial {
+		return nil, fmt.Errorf("minRate should be a value between 0 and initial")
+	}
+	return &ReduceOnPlateau{
+		rate:     initial,
+		factor:   factor,
+		patience: patience,
+		minRate:  minRate,
+		best:     math.Inf(1),
+	}, nil
+}
+
+func (s *ReduceOnPlateau) LearningRate(epoch, step int) float64 {
+	return s.rate
+}
+
+func (s *ReduceOnPlateau) Observe(loss float64) {
+	if loss < s.best {
+		s.best = loss
+		s.wait = 0
+		return
+	}
+
+	s.wait++
+	if s.wait >= s.patience {
+		s.rate = math.Max(s.rate*s.factor, s.minRate)
+		s.wait = 0
+	}
+}
+
+func (s *ReduceOnPlateau) snapshot() []float64 {
+	return []float64{s.rate, s.best, float64(s.wait)}
+}
+
+func (s *ReduceOnPlateau) restore(state []float64) error {
+	if len(state) != 3 {
+		return fmt.Errorf("reduce on plateau needs 3 state values, got %v", len(state))
+	}
+	s.rate, s.best, s.wait = state[0], state[1], int(state[2])
+	return nil
+}
@@ -0,0 +1,215 @@
+package nngo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gonum.org/v1/gonum/mat"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestConstantRate(t *testing.T) {
+	rate := ConstantRate(0.1)
+	if rate.LearningRate(0, 0) != 0.1 || rate.LearningRate(100, 1000) != 0.1 {
+		t.Error("Expected constant learning rate")
+	}
+}
+
+func TestStepDecay(t *testing.T) {
+	scheduler, err := NewStepDecay(1, 0.5, 2)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	expected := []float64{1, 1, 0.5, 0.5, 0.25}
+	for epoch, rate := range expected {
+		if ans := scheduler.LearningRate(epoch, 0); !almostEqual(ans, rate) {
+			t.Errorf("Epoch %v: Expected: %v, Got: %v", epoch, rate, ans)
+		}
+	}
+
+	if _, err := NewStepDecay(1, 2, 2); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestExponentialDecay(t *testing.T) {
+	scheduler, err := NewExponentialDecay(1, 0.5)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	if ans := scheduler.LearningRate(3, 0); !almostEqual(ans, 0.125) {
+		t.Errorf("Expected: %v, Got: %v", 0.125, ans)
+	}
+
+	if _, err := NewExponentialDecay(0, 0.5); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestCosineAnnealing(t *testing.T) {
+	scheduler, err := NewCosineAnnealing(1, 0, 2, 2)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	// first cycle has 2 epochs, the second cycle 4 epochs
+	expected := []float64{1, 0.5, 1, 0.5 * (1 + math.Cos(math.Pi/4)), 0.5}
+	for epoch, rate := range expected {
+		if ans := scheduler.LearningRate(epoch, 0); !almostEqual(ans, rate) {
+			t.Errorf("Epoch %v: Expected: %v, Got: %v", epoch, rate, ans)
+		}
+	}
+
+	if _, err := NewCosineAnnealing(0.1, 1, 2, 2); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestLinearWarmup(t *testing.T) {
+	scheduler, err := NewLinearWarmup(4, ConstantRate(1))
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	expected := []float64{0.25, 0.5, 0.75, 1, 1}
+	for step, rate := range expected {
+		if ans := scheduler.LearningRate(0, step); !almostEqual(ans, rate) {
+			t.Errorf("Step %v: Expected: %v, Got: %v", step, rate, ans)
+		}
+	}
+
+	if _, err := NewLinearWarmup(4, nil); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestOneCycle(t *testing.T) {
+	scheduler, err := NewOneCycle(1, 100)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	if ans := scheduler.LearningRate(0, 0); !almostEqual(ans, 1.0/25) {
+		t.Errorf("Expected: %v, Got: %v", 1.0/25, ans)
+	}
+	if ans := scheduler.LearningRate(0, 30); !almostEqual(ans, 1) {
+		t.Errorf("Expected: %v, Got: %v", 1, ans)
+	}
+	if ans := scheduler.LearningRate(0, 100); !almostEqual(ans, 1e-4) {
+		t.Errorf("Expected: %v, Got: %v", 1e-4, ans)
+	}
+
+	if _, err := NewOneCycle(1, 0); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestReduceOnPlateau(t *testing.T) {
+	scheduler, err := NewReduceOnPlateau(1, 0.5, 2, 0.3)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	losses := []float64{1, 0.5, 0.6, 0.7, 0.8, 0.9, 0.9, 0.9}
+	expected := []float64{1, 1, 1, 0.5, 0.5, 0.3, 0.3, 0.3}
+	for i, loss := range losses {
+		scheduler.Observe(loss)
+		if ans := scheduler.LearningRate(i, 0); !almostEqual(ans, expected[i]) {
+			t.Errorf("Epoch %v: Expected: %v, Got: %v", i, expected[i], ans)
+		}
+	}
+
+	if _, err := NewReduceOnPlateau(1, 1, 2, 0); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestLinearWarmupObserve(t *testing.T) {
+	plateau, _ := NewReduceOnPlateau(1, 0.5, 1, 0)
+	scheduler, _ := NewLinearWarmup(2, plateau)
+
+	// the losses reach the wrapped scheduler through the warmup
+	var observer Observer = scheduler
+	observer.Observe(1)
+	observer.Observe(2)
+	if ans := scheduler.LearningRate(1, 0); !almostEqual(ans, 0.25) {
+		t.Errorf("Expected: %v, Got: %v", 0.25, ans)
+	}
+	if ans := scheduler.LearningRate(1, 2); !almostEqual(ans, 0.5) {
+		t.Errorf("Expected: %v, Got: %v", 0.5, ans)
+	}
+
+	// the state of the wrapped scheduler is restored
+	restored, _ := NewReduceOnPlateau(1, 0.5, 1, 0)
+	warmup, _ := NewLinearWarmup(2, restored)
+	if err := warmup.restore(scheduler.snapshot()); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if diff := cmp.Diff(plateau.snapshot(), restored.snapshot()); diff != "" {
+		t.Errorf("Scheduler state mismatch (-want +got):\n%s", diff)
+	}
+
+	constant, _ := NewLinearWarmup(2, ConstantRate(1))
+	if state := constant.snapshot(); state != nil {
+		t.Errorf("Expected: %v, Got: %v", nil, state)
+	}
+	if err := constant.restore([]float64{1}); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+// records the arguments of each call
+type recordingScheduler struct {
+	epochs   []int
+	steps    []int
+	observed []float64
+}
+
+func (s *recordingScheduler) LearningRate(epoch, step int) float64 {
+	s.epochs = append(s.epochs, epoch)
+	s.steps = append(s.steps, step)
+	return 0.01
+}
+
+func (s *recordingScheduler) Observe(loss float64) {
+	s.observed = append(s.observed, loss)
+}
+
+func TestTrainScheduler(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	labels := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	set := Set{*data, *labels}
+
+	network, err := NewNetwork([]LayerSpec{{2, 2, 2}}, LossMse)
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	scheduler := &recordingScheduler{}
+	if _, err := network.Train(&set, 2, 3, scheduler); err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+
+	// 2 batches per epoch
+	expectedEpochs := []int{0, 0, 1, 1}
+	expectedSteps := []int{0, 1, 2, 3}
+	for i := range expectedSteps {
+		if scheduler.epochs[i] != expectedEpochs[i] || scheduler.steps[i] != expectedSteps[i] {
+			t.Errorf("Expected: (%v, %v), Got: (%v, %v)", expectedEpochs[i], expectedSteps[i], scheduler.epochs[i], scheduler.steps[i])
+		}
+	}
+
+	if len(scheduler.observed) != 2 {
+		t.Errorf("Expected 2 observed losses, Got: %v", len(scheduler.observed))
+	}
+
+	if _, err := network.Train(&set, 2, 3, nil); err == nil {
+		t.Error("Expected error.")
+	}
+}
@@ -0,0 +1,802 @@
+package nngo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// optional setting of Train, e.g. WithCheckpoints
+type TrainOption func(config *trainConfig)
+
+// settings of one training run, collected from the options
+type trainConfig struct {
+	checkpointDir   string
+	checkpointEvery int
+	bestCheckpoint  string
+	resume          *Checkpoint
+	validation      *Set
+	validationSplit float64
+	metrics         []Metric
+	patience        int
+	restoreBest     bool
+	callbacks       []Callback
+	writer          io.Writer
+	timeBudget      time.Duration
+	maxSteps        int
+	workers         int
+	seed            int64
+	seeded          bool
+	shuffle         bool
+}
+
+// position of a training run, everything that is needed to continue it
+//
+// epoch is the number of finished epochs and step the number of finished batches
+// best is the lowest monitored loss so far and wait the number of epochs since then
+// bestParams holds the parameters and the buffers of the best epoch, if they need to be restored
+type trainProgress struct {
+	epoch      int
+	step       int
+	best       float64
+	wait       int
+	bestParams [][]float64
+}
+
+// writes a checkpoint to dir every few epochs
+//
+// the checkpoints are named after the number of finished epochs,
+// use LatestCheckpoint to find the newest one
+func WithCheckpoints(dir string, every int) TrainOption {
+	return func(config *trainConfig) {
+		config.checkpointDir = dir
+		config.checkpointEvery = every
+	}
+}
+
+// writes a checkpoint to path, whenever the monitored loss is lower than in all previous epochs
+// the file is replaced by each new best checkpoint
+func WithBestCheckpoint(path string) TrainOption {
+	return func(config *trainConfig) {
+		config.bestCheckpoint = path
+	}
+}
+
+// continues the training from a checkpoint
+//
+// the network needs to be the network of the checkpoint
+// the training starts after the last finished epoch of the checkpoint and stops at epochs,
+// the scheduler and the other options need to be the same as in the interrupted training
+func WithResume(checkpoint *Checkpoint) TrainOption {
+	return func(config *trainConfig) {
+		config.resume = checkpoint
+	}
+}
+
+// evaluates the loss and the metrics on the validation set after each epoch
+//
+// if a validation set is given, the validation loss is monitored instead of the training loss,
+// e.g. by ReduceOnPlateau, WithBestCheckpoint and WithEarlyStopping
+func WithValidation(validation *Set) TrainOption {
+	return func(config *trainConfig) {
+		config.validation = validation
+	}
+}
+
+// uses the last fraction of the training set for validation, see WithValidation
+//
+// the samples are not shuffled before, so ordered data should be shuffled first,
+// e.g. with NewSplitSetAlt
+// fraction should be between 0 and 1
+func WithValidationSplit(fraction float64) TrainOption {
+	return func(config *trainConfig) {
+		config.validationSplit = fraction
+	}
+}
+
+// evaluates the metrics after each epoch
+//
+// the metrics are evaluated on the validation set,
+// without validation set they are evaluated on the training set
+func WithMetrics(metrics ...Metric) TrainOption {
+	return func(config *trainConfig) {
+		config.metrics = append(config.metrics, metrics...)
+	}
+}
+
+// stops the training, when the monitored loss didn't improve for patience epochs
+//
+// with restoreBest the parameters of the epoch with the lowest monitored loss
+// are restored at the end of the training
+func WithEarlyStopping(patience int, restoreBest bool) TrainOption {
+	return func(config *trainConfig) {
+		config.patience = patience
+		config.restoreBest = restoreBest
+	}
+}
+
+// adds callbacks, that are notified about the progress of the training
+func WithCallbacks(callbacks ...Callback) TrainOption {
+	return func(config *trainConfig) {
+		config.callbacks = append(config.callbacks, callbacks...)
+	}
+}
+
+// writes the progress of the training to w, one line for each epoch
+// the default is os.Stdout, nil disables the output
+func WithWriter(w io.Writer) TrainOption {
+	return func(config *trainConfig) {
+		config.writer = w
+	}
+}
+
+// stops the training cleanly after the given wall clock time
+//
+// the budget is checked between batches, so the network is never left with a half applied update
+// the interrupted epoch is not added to the history
+func WithTimeBudget(budget time.Duration) TrainOption {
+	return func(config *trainConfig) {
+		config.timeBudget = budget
+	}
+}
+
+// stops the training cleanly after the given number of batches
+//
+// the steps are counted like in Scheduler,
+// so steps of a resumed training include the steps before the checkpoint
+func WithMaxSteps(steps int) TrainOption {
+	return func(config *trainConfig) {
+		config.maxSteps = steps
+	}
+}
+
+// splits each batch between the given number of goroutines
+//
+// each worker propagates its part of the batch through its own copy of the layers
+// afterwards the gradients are summed up in a fixed order and the optimizer updates the parameters once,
+// so the result doesn't depend on the scheduling of the goroutines
+// custom losses and activation functions need to be safe for concurrent use
+//
+// a good value is runtime.NumCPU(), the default is 1
+func WithWorkers(workers int) TrainOption {
+	return func(config *trainConfig) {
+		config.workers = workers
+	}
+}
+
+// seeds the random source of the network before the training
+//
+// with the same seed, the same initial weights and the same options,
+// the training is bit for bit identical on the same machine, also with multiple workers
+// when the training is resumed, the random state of the checkpoint is used instead
+func WithSeed(seed int64) TrainOption {
+	return func(config *trainConfig) {
+		config.seed = seed
+		config.seeded = true
+	}
+}
+
+// shuffles the order of the training samples before each epoch
+//
+// the permutation is drawn from the random source of the network, see WithSeed,
+// and only the samples of the current batch are copied, the training set is not changed
+// the validation set of WithValidationSplit is split off before and is not shuffled
+func WithShuffle() TrainOption {
+	return func(config *trainConfig) {
+		config.shuffle = true
+	}
+}
+
+// stops the training because of WithTimeBudget or WithMaxSteps
+var errBudgetExhausted = errors.New("training budget exhausted")
+
+// trains the network with mini-batch gradient descent
+//
+// the training data is split into batches of batchSize columns
+// the last batch of an epoch can be smaller, if the data doesn't divide evenly
+// gradients are averaged over each batch before the optimizer updates the weights
+//
+// the scheduler is queried for the learning rate before each batch
+// use ConstantRate for a fixed learning rate
+//
+// the returned history contains the results of all finished epochs,
+// also if the training stopped with an error
+func (dense *Network) Train(train *Set, epochs, batchSize int, scheduler Scheduler, options ...TrainOption) (*History, error) {
+	return dense.TrainContext(context.Background(), train, epochs, batchSize, scheduler, options...)
+}
+
+// same as Train, but the training stops, when ctx is done
+//
+// ctx is checked between batches, so the network is left in a consistent state
+// the returned error is the error of ctx, the history contains the finished epochs
+// the restoring of the best weights of WithEarlyStopping is applied anyway
+func (dense *Network) TrainContext(
+	ctx context.Context,
+	train *Set,
+	epochs, batchSize int,
+	scheduler Scheduler,
+	options ...TrainOption,
+) (*History, error) {
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("batchSize must be greater than 0")
+	}
+	if scheduler == nil {
+		return nil, fmt.Errorf("scheduler must not be nil")
+	}
+
+	config := trainConfig{writer: os.Stdout, workers: 1}
+	for _, option := range options {
+		option(&config)
+	}
+	if config.checkpointDir != "" && config.checkpointEvery <= 0 {
+		return nil, fmt.Errorf("checkpoints need an interval greater than 0")
+	}
+	if config.patience < 0 {
+		return nil, fmt.Errorf("patience must not be negative")
+	}
+	if config.timeBudget < 0 || config.maxSteps < 0 {
+		return nil, fmt.Errorf("time budget and max steps must not be negative")
+	}
+	if config.workers < 1 {
+		return nil, fmt.Errorf("workers must be at least 1")
+	}
+
+	train, validation, err := config.sets(train)
+	if err != nil {
+		return nil, err
+	}
+	if err := dense.checkInput(train.Data, true); err != nil {
+		return nil, err
+	}
+	if validation != nil {
+		if err := dense.checkInput(validation.Data, true); err != nil {
+			return nil, err
+		}
+	}
+
+	t := trainer{
+		ctx:        ctx,
+		start:      time.Now(),
+		network:    dense,
+		train:      train,
+		validation: validation,
+		batchSize:  batchSize,
+		scheduler:  scheduler,
+		config:     config,
+		callbacks:  config.callbacks,
+		progress:   trainProgress{best: math.Inf(1)},
+	}
+	if config.resume != nil {
+		if err := dense.resume(config.resume, scheduler); err != nil {
+			return nil, err
+		}
+		t.progress = config.resume.progress
+	} else if config.seeded {
+		dense.random.Seed(config.seed)
+	}
+	if config.writer != nil {
+		t.callbacks = append([]Callback{progressWriter{w: config.writer}}, t.callbacks...)
+	}
+	for i := 1; i < config.workers; i++ {
+		t.replicas = append(t.replicas, dense.replica())
+	}
+
+	for _, callback := range t.callbacks {
+		callback.OnTrainBegin(dense)
+	}
+	err = t.run(epochs)
+	for _, callback := range t.callbacks {
+		callback.OnTrainEnd(&t.history)
+	}
+	return &t.history, err
+}
+
+// returns the training and the validation set of the run
+// the validation set is nil, if no validation is configured
+func (config *trainConfig) sets(train *Set) (*Set, *Set, error) {
+	validation := config.validation
+	if config.validationSplit != 0 {
+		if validation != nil {
+			return nil, nil, fmt.Errorf("use either a validation set or a validation split")
+		}
+		if config.validationSplit < 0 || config.validationSplit >= 1 {
+			return nil, nil, fmt.Errorf("validation split should be a value between 0 and 1")
+		}
+
+		split := train.split(config.validationSplit)
+		train, validation = &split.Train, &split.Test
+	}
+
+	if validation != nil {
+		if _, cols := validation.Data.Dims(); cols == 0 {
+			return nil, nil, fmt.Errorf("validation set must not be empty")
+		}
+	}
+	return train, validation, nil
+}
+
+// state of one call of Train
+type trainer struct {
+	ctx        context.Context
+	start      time.Time
+	network    *Network
+	train      *Set
+	validation *Set
+	batchSize  int
+	scheduler  Scheduler
+	config     trainConfig
+	callbacks  []Callback
+	progress   trainProgress
+	history    History
+	// layers of the additional workers, the first worker uses the layers of the network
+	replicas [][]Layer
+}
+
+// trains until epochs are finished or the training stops early
+func (t *trainer) run(epochs int) error {
+	err := t.epochs(epochs)
+	if errors.Is(err, errBudgetExhausted) {
+		err = nil
+	}
+
+	if t.config.restoreBest && t.progress.bestParams != nil {
+		for i, values := range t.network.values() {
+			copy(values, t.progress.bestParams[i])
+		}
+	}
+	return err
+}
+
+// trains the epochs one after another
+func (t *trainer) epochs(epochs int) error {
+	for t.progress.epoch < epochs {
+		if t.config.patience > 0 && t.progress.wait >= t.config.patience {
+			break
+		}
+		if err := t.interrupted(); err != nil {
+			return err
+		}
+
+		for _, callback := range t.callbacks {
+			callback.OnEpochBegin(t.progress.epoch)
+		}
+		begin := time.Now()
+		diff, err := t.trainEpoch()
+		if err != nil {
+			return err
+		}
+		result := EpochResult{Epoch: t.progress.epoch, Loss: diff}
+		t.progress.epoch++
+
+		monitored, err := t.evaluate(&result)
+		if err != nil {
+			return err
+		}
+		result.Duration = time.Since(begin)
+		if observer, ok := t.scheduler.(Observer); ok {
+			observer.Observe(monitored)
+		}
+
+		t.history.Epochs = append(t.history.Epochs, result)
+		for _, callback := range t.callbacks {
+			callback.OnEpochEnd(result)
+		}
+
+		if err := t.monitor(monitored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checks if the training has to stop before the next batch
+func (t *trainer) interrupted() error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	if t.config.timeBudget > 0 && time.Since(t.start) >= t.config.timeBudget {
+		return errBudgetExhausted
+	}
+	if t.config.maxSteps > 0 && t.progress.step >= t.config.maxSteps {
+		return errBudgetExhausted
+	}
+	return nil
+}
+
+// trains one epoch and returns the average loss of the training samples
+// the step of the progress is increased after each batch
+func (t *trainer) trainEpoch() (float64, error) {
+	dense := t.network
+	dataRows, samples := t.train.Data.Dims()
+	labelRows, _ := t.train.Labels.Dims()
+
+	var order []int
+	if t.config.shuffle {
+		order = rand.New(dense.random).Perm(samples)
+	}
+
+	diff := 0.0
+	for batch, start := 0, 0; start < samples; batch, start = batch+1, start+t.batchSize {
+		if err := t.interrupted(); err != nil {
+			return 0, err
+		}
+		for _, callback := range t.callbacks {
+			callback.OnBatchBegin(t.progress.epoch, batch)
+		}
+
+		end := start + t.batchSize
+		if end > samples {
+			end = samples
+		}
+		var data, labels *mat.Dense
+		if order != nil {
+			data = selectCols(t.train.Data, order[start:end])
+			labels = selectCols(t.train.Labels, order[start:end])
+		} else {
+			data = t.train.Data.Slice(0, dataRows, start, end).(*mat.Dense)
+			labels = t.train.Labels.Slice(0, labelRows, start, end).(*mat.Dense)
+		}
+
+		cache, err := t.trainBatch(*data, *labels)
+		if err != nil {
+			return 0, err
+		}
+		// the penalty is counted once for each sample, so the average includes it once
+		cache += dense.regularize() * float64(end-start)
+		diff += cache
+
+		dense.optimizer.Update(dense.params(), t.scheduler.LearningRate(t.progress.epoch, t.progress.step))
+		dense.constrain()
+		t.progress.step++
+
+		for _, callback := range t.callbacks {
+			callback.OnBatchEnd(t.progress.epoch, batch, cache/float64(end-start))
+		}
+	}
+	return diff / float64(samples), nil
+}
+
+// copies the given columns of m into a new matrix in the given order
+func selectCols(m mat.Dense, cols []int) *mat.Dense {
+	rows, _ := m.Dims()
+	raw := m.RawMatrix()
+	selected := mat.NewDense(rows, len(cols), nil)
+	data := selected.RawMatrix().Data
+	for i := 0; i < rows; i++ {
+		for k, j := range cols {
+			data[i*len(cols)+k] = raw.Data[i*raw.Stride+j]
+		}
+	}
+	return selected
+}
+
+// propagates the batch forward and backward, so the gradients of the parameters are set
+// returns the summed loss of the batch
+func (t *trainer) trainBatch(data, labels mat.Dense) (float64, error) {
+	dense := t.network
+	_, size := data.Dims()
+	workers := len(t.replicas) + 1
+	if workers > size {
+		workers = size
+	}
+	if workers == 1 {
+		dense.seedLayers(dense.layers)
+		return dense.backpropagate(dense.layers, data, labels, size)
+	}
+
+	synchronizeLayers(dense.layers, t.replicas, workers)
+	defer synchronizeLayers(dense.layers, t.replicas, 1)
+
+	dataRows, _ := data.Dims()
+	labelRows, _ := labels.Dims()
+	losses := make([]float64, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		layers := dense.layers
+		if w > 0 {
+			layers = t.replicas[w-1]
+		}
+		dense.seedLayers(layers)
+		start, end := w*size/workers, (w+1)*size/workers
+		shard := data.Slice(0, dataRows, start, end).(*mat.Dense)
+		shardLabels := labels.Slice(0, labelRows, start, end).(*mat.Dense)
+
+		wg.Add(1)
+		go func(w int, layers []Layer) {
+			defer wg.Done()
+			losses[w], errs[w] = dense.backpropagate(layers, *shard, *shardLabels, size)
+		}(w, layers)
+	}
+	wg.Wait()
+
+	// the first worker wrote its gradients directly into the network
+	for w := 1; w < workers; w++ {
+		addGradients(dense.layers, t.replicas[w-1])
+	}
+
+	sum := 0.0
+	for w := 0; w < workers; w++ {
+		if errs[w] != nil {
+			return 0, errs[w]
+		}
+		sum += losses[w]
+	}
+	return sum, nil
+}
+
+// gives the synchronized layers of the given number of workers a new group for the next batch,
+// with a single worker the group is removed
+func synchronizeLayers(layers []Layer, replicas [][]Layer, workers int) {
+	for l, layer := range layers {
+		s, ok := layer.(synchronized)
+		if !ok {
+			continue
+		}
+		var group *workerGroup
+		if workers > 1 {
+			group = newWorkerGroup(workers)
+		}
+		s.synchronize(group, 0)
+		for w := 1; w < workers; w++ {
+			replicas[w-1][l].(synchronized).synchronize(group, w)
+		}
+	}
+}
+
+// adds the gradients of the replicas to the gradients of the layers
+// layers with sparse gradients add only the rows of their replica
+func addGradients(layers, replicas []Layer) {
+	for l, layer := range layers {
+		if s, ok := layer.(sparse); ok {
+			s.addGradient(replicas[l])
+		} else if tr, ok := layer.(trainable); ok {
+			params := replicas[l].(trainable).params()
+			for i, p := range tr.params() {
+				floats.Add(p.Gradient, params[i].Gradient)
+			}
+		}
+	}
+}
+
+// adds the validation loss and the metrics to the result of the epoch
+// and returns the monitored loss
+func (t *trainer) evaluate(result *EpochResult) (float64, error) {
+	evaluated := t.validation
+	if evaluated == nil && len(t.config.metrics) > 0 {
+		evaluated = t.train
+	}
+	if evaluated == nil {
+		return result.Loss, nil
+	}
+
+	loss, metrics, err := t.network.evaluate(evaluated, t.config.metrics)
+	if err != nil {
+		return 0, err
+	}
+	if len(metrics) > 0 {
+		result.Metrics = map[string]float64{}
+	}
+	for i, metric := range t.config.metrics {
+		result.Metrics[metric.Name()] = metrics[i]
+	}
+
+	if t.validation == nil {
+		return result.Loss, nil
+	}
+	result.Validation = true
+	result.ValidationLoss = loss
+	return loss, nil
+}
+
+// keeps track of the best monitored loss and writes the checkpoints
+func (t *trainer) monitor(monitored float64) error {
+	improved := monitored < t.progress.best
+	if improved {
+		t.progress.best = monitored
+		t.progress.wait = 0
+		if t.config.restoreBest {
+			t.progress.bestParams = copyValues(t.network.values())
+		}
+	} else {
+		t.progress.wait++
+	}
+
+	if improved && t.config.bestCheckpoint != "" {
+		if err := t.network.checkpoint(t.config.bestCheckpoint, t.scheduler, t.progress); err != nil {
+			return err
+		}
+	}
+	if t.config.checkpointEvery > 0 && t.progress.epoch%t.config.checkpointEvery == 0 {
+		path := filepath.Join(t.config.checkpointDir, checkpointName(t.progress.epoch))
+		if err := t.network.checkpoint(path, t.scheduler, t.progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// calculates the average loss and the metrics of the network on the set
+// the loss includes the penalties of the regularizers like the training loss
+func (dense *Network) evaluate(set *Set, metrics []Metric) (float64, []float64, error) {
+	predictions, err := dense.PredictBatch(set.Data)
+	if err != nil {
+		return 0, nil, err
+	}
+	loss, _, err := dense.batchLoss(set.Labels, predictions)
+	if err != nil {
+		return 0, nil, err
+	}
+	_, samples := set.Data.Dims()
+
+	values := make([]float64, len(metrics))
+	for i, metric := range metrics {
+		values[i] = metric.Evaluate(set.Labels, predictions)
+	}
+	return loss/float64(samples) + dense.penalty(), values, nil
+}
+
+// returns the values of all parameters followed by the buffers of all stateful layers,
+// the slices share the memory with the layers
+func (dense *Network) values() [][]float64 {
+	var values [][]float64
+	for _, p := range dense.params() {
+		values = append(values, p.Value)
+	}
+	for _, layer := range dense.layers {
+		if st, ok := layer.(stateful); ok {
+			values = append(values, st.buffers()...)
+		}
+	}
+	return values
+}
+
+// copies the values
+func copyValues(values [][]float64) [][]float64 {
+	copied := make([][]float64, len(values))
+	for i, v := range values {
+		copied[i] = append([]float64(nil), v...)
+	}
+	return copied
+}
+
+// writes the current training state to path
+func (dense *Network) checkpoint(path string, scheduler Scheduler, progress trainProgress) error {
+	state, err := dense.checkpointState(scheduler, progress)
+	if err != nil {
+		return err
+	}
+	if err := writeCheckpoint(path, state); err != nil {
+		return fmt.Errorf("unable to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// propagates a part of a batch through the layers and back,
+// the layers are the layers of the network or a replica of them
+// returns the summed loss of the samples
+//
+// the gradient of the loss is divided by batchSize,
+// which is larger than the number of samples, if the batch is split between workers
+func (dense *Network) backpropagate(layers []Layer, data, labels mat.Dense, batchSize int) (float64, error) {
+	out := forwardLayers(layers, data)
+	loss := dense.loss
+	if dense.fusedSoftmax {
+		// calculate the loss on the logits and skip the backward propagation of the softmax layer
+		out = layers[len(layers)-1].(*Softmax).base.input
+		layers = layers[:len(layers)-1]
+		loss = softmaxCrossEntropyLoss
+	}
+
+	sum, grad, err := scaledBatchLoss(labels, out, loss, batchSize)
+	if err != nil {
+		// the synchronized layers of the other workers wait for the backward propagation of this worker
+		sum = 0
+		grad.Zero()
+	}
+	for k := range layers {
+		grad = layers[len(layers)-1-k].backward(grad)
+	}
+	return sum, err
+}
+
+// seeds the layers, that draw random numbers, from the random source of the network
+func (dense *Network) seedLayers(layers []Layer) {
+	for _, layer := range layers {
+		if r, ok := layer.(randomLayer); ok {
+			r.seed(dense.random.Int63())
+		}
+	}
+}
+
+// adds the gradients of the penalties of all regularized layers to their gradients
+// and returns the sum of the penalties
+func (dense *Network) regularize() float64 {
+	sum := 0.0
+	for _, layer := range dense.layers {
+		if r, ok := layer.(regularized); ok {
+			r.addPenaltyGradient()
+			sum += r.penalty()
+		}
+	}
+	return sum
+}
+
+// returns the sum of the penalties of all regularized layers
+func (dense *Network) penalty() float64 {
+	sum := 0.0
+	for _, layer := range dense.layers {
+		if r, ok := layer.(regularized); ok {
+			sum += r.penalty()
+		}
+	}
+	return sum
+}
+
+// applies the constraints of all constrained layers
+func (dense *Network) constrain() {
+	for _, layer := range dense.layers {
+		if c, ok := layer.(constrained); ok {
+			c.constrain()
+		}
+	}
+}
+
+// returns copies of all layers for another worker, see Layer
+func (dense *Network) replica() []Layer {
+	layers := make([]Layer, len(dense.layers))
+	for i, layer := range dense.layers {
+		layers[i] = layer.replica()
+	}
+	return layers
+}
+
+// calculates the summed loss of the batch and the gradient of the loss
+// with the loss function of the network
+func (dense *Network) batchLoss(labels, out mat.Dense) (float64, mat.Dense, error) {
+	return batchLoss(labels, out, dense.loss)
+}
+
+// calculates the summed loss of the batch and the gradient of the loss
+// the gradient of each sample is divided by the batch size,
+// so that the layers get the average gradient of the batch
+func batchLoss(labels, out mat.Dense, loss Loss) (float64, mat.Dense, error) {
+	_, cols := out.Dims()
+	return scaledBatchLoss(labels, out, loss, cols)
+}
+
+// same as batchLoss, but the gradient of each sample is divided by batchSize
+func scaledBatchLoss(labels, out mat.Dense, loss Loss, batchSize int) (float64, mat.Dense, error) {
+	rows, cols := out.Dims()
+	grad := mat.NewDense(rows, cols, nil)
+	sum := 0.0
+	for j := 0; j < cols; j++ {
+		yTrue := GetColVector(labels, j)
+		yPred := GetColVector(out, j)
+
+		cache, err := loss.Loss(yTrue, yPred)
+		if err != nil {
+			return 0, *grad, err
+		}
+		sum += cache
+
+		colGrad, err := loss.Derivative(yTrue, yPred)
+		if err != nil {
+			return 0, *grad, err
+		}
+		for i := 0; i < rows; i++ {
+			grad.Set(i, j, colGrad.AtVec(i)/float64(batchSize))
+		}
+	}
+	return sum, *grad, nil
+}
@@ -0,0 +1,333 @@
+package nngo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestTrainValidation(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	labels := mat.NewDense(2, 4, []float64{1, 0, 0, 0, 0, 1, 1, 1})
+	set := Set{*data, *labels}
+
+	network, err := NewNetwork([]LayerSpec{{2, 4, 2}, {4, 2, ActivationSoftmax}}, LossCategoricalCrossEntropy)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	// the validation loss is observed instead of the training loss
+	validation := Set{*mat.NewDense(2, 1, []float64{1, 1}), *mat.NewDense(2, 1, []float64{0, 1})}
+	scheduler := &recordingScheduler{}
+	if _, err := network.Train(&set, 2, 4, scheduler, WithValidation(&validation), WithMetrics(AccuracyMetric)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	expected, _, _ := network.evaluate(&validation, nil)
+	if len(scheduler.observed) != 2 || scheduler.observed[1] != expected {
+		t.Errorf("Expected: %v, Got: %v", expected, scheduler.observed)
+	}
+}
+
+func TestTrainValidationSplit(t *testing.T) {
+	data := mat.NewDense(1, 5, []float64{1, 2, 3, 4, 5})
+	set := Set{*data, *data}
+
+	config := trainConfig{validationSplit: 0.4}
+	train, validation, err := config.sets(&set)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	expectedTrain := mat.NewDense(1, 3, []float64{1, 2, 3})
+	if !mat.Equal(&train.Data, expectedTrain) {
+		t.Errorf("Expected: %v, Got: %v", expectedTrain, train.Data)
+	}
+	expectedValidation := mat.NewDense(1, 2, []float64{4, 5})
+	if !mat.Equal(&validation.Labels, expectedValidation) {
+		t.Errorf("Expected: %v, Got: %v", expectedValidation, validation.Labels)
+	}
+
+	// the given set is not changed
+	if !mat.Equal(&set.Data, data) {
+		t.Errorf("Expected: %v, Got: %v", data, set.Data)
+	}
+}
+
+func TestTrainValidationError(t *testing.T) {
+	data := mat.NewDense(2, 2, []float64{0, 1, 1, 0})
+	set := Set{*data, *data}
+	empty := Set{}
+
+	network, _ := NewNetwork([]LayerSpec{{2, 2, ActivationSigmoid}}, LossMse)
+	tests := [][]TrainOption{
+		{WithValidation(&set), WithValidationSplit(0.5)},
+		{WithValidationSplit(1)},
+		{WithValidationSplit(-0.5)},
+		{WithValidation(&empty)},
+		{WithEarlyStopping(-1, false)},
+	}
+
+	for _, options := range tests {
+		if _, err := network.Train(&set, 1, 2, ConstantRate(0.1), options...); err == nil {
+			t.Error("Expected error.")
+		}
+	}
+}
+
+func TestEarlyStopping(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	set := Set{*data, *data}
+
+	network, err := NewNetwork([]LayerSpec{{2, 2, ActivationTanh}}, LossMse)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	// copy of the network, that is trained for one epoch only
+	var buffer bytes.Buffer
+	if err := network.Save(&buffer); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	reference, err := Load(&buffer)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	if _, err := reference.Train(&set, 1, 4, ConstantRate(-0.1)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	// a negative learning rate increases the loss in every epoch,
+	// so the first epoch is the best and the training stops after patience more epochs
+	scheduler := &recordingScheduler{}
+	options := []TrainOption{WithValidation(&set), WithEarlyStopping(2, true)}
+	if _, err := network.Train(&set, 10, 4, recordingRate{scheduler, ConstantRate(-0.1)}, options...); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	if len(scheduler.observed) != 3 {
+		t.Errorf("Expected: %v epochs, Got: %v", 3, len(scheduler.observed))
+	}
+	checkSamePredictions(t, reference, network, *data)
+}
+
+// records the observed losses, but uses the rate of another scheduler
+type recordingRate struct {
+	*recordingScheduler
+	rate Scheduler
+}
+
+func (s recordingRate) LearningRate(epoch, step int) float64 {
+	s.recordingScheduler.LearningRate(epoch, step)
+	return s.rate.LearningRate(epoch, step)
+}
+
+// cancels the context after the given batch of the given epoch
+type cancelingCallback struct {
+	BaseCallback
+	cancel       context.CancelFunc
+	epoch, batch int
+}
+
+func (c cancelingCallback) OnBatchEnd(epoch, batch int, loss float64) {
+	if epoch == c.epoch && batch == c.batch {
+		c.cancel()
+	}
+}
+
+func TestTrainContext(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	set := Set{*data, *data}
+
+	network, err := NewNetwork([]LayerSpec{{2, 2, ActivationTanh}}, LossMse)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	scheduler := &recordingScheduler{}
+	callback := cancelingCallback{cancel: cancel, epoch: 1, batch: 0}
+	history, err := network.TrainContext(ctx, &set, 10, 2, scheduler, WithCallbacks(callback))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected: %v, Got: %v", context.Canceled, err)
+	}
+
+	if len(history.Epochs) != 1 {
+		t.Errorf("Expected: %v epochs, Got: %v", 1, len(history.Epochs))
+	}
+	if len(scheduler.steps) != 3 {
+		t.Errorf("Expected: %v steps, Got: %v", 3, len(scheduler.steps))
+	}
+}
+
+func TestTrainBudget(t *testing.T) {
+	data := mat.NewDense(2, 4, []float64{0, 0, 1, 1, 0, 1, 0, 1})
+	set := Set{*data, *data}
+
+	network, err := NewNetwork([]LayerSpec{{2, 2, ActivationTanh}}, LossMse)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	scheduler := &recordingScheduler{}
+	history, err := network.Train(&set, 10, 2, scheduler, WithMaxSteps(5))
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+	if len(scheduler.steps) != 5 || len(history.Epochs) != 2 {
+		t.Errorf("Expected: 5 steps and 2 epochs, Got: %v steps and %v epochs", len(scheduler.steps), len(history.Epochs))
+	}
+
+	scheduler = &recordingScheduler{}
+	history, err = network.Train(&set, 10, 2, scheduler, WithTimeBudget(time.Nanosecond))
+	if err != nil {
+		t.Errorf("Didn't expect error. Got: %v", err)
+	}
+	if len(scheduler.steps) != 0 || len(history.Epochs) != 0 {
+		t.Errorf("Expected no steps, Got: %v steps and %v epochs", len(scheduler.steps), len(history.Epochs))
+	}
+
+	if _, err := network.Train(&set, 10, 2, scheduler, WithMaxSteps(-1)); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestTrainWorkers(t *testing.T) {
+	data := mat.NewDense(2, 7, []float64{0, 0, 1, 1, 0.5, -1, 2, 0, 1, 0, 1, 0.5, 1, -2})
+	labels := mat.NewDense(2, 7, []float64{1, 0, 0, 0, 1, 0, 1, 0, 1, 1, 1, 0, 1, 0})
+	set := Set{*data, *labels}
+
+	network, err := NewNetwork([]LayerSpec{{2, 5, ActivationPRelu}, {5, 2, ActivationSoftmax}}, LossCategoricalCrossEntropy)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	var buffer bytes.Buffer
+	if err := network.Save(&buffer); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	parallel, err := Load(&buffer)
+	if err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	if _, err := network.Train(&set, 5, 4, ConstantRate(0.1)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+	// more workers than samples in the last batch
+	if _, err := parallel.Train(&set, 5, 4, ConstantRate(0.1), WithWorkers(4)); err != nil {
+		t.Fatalf("Didn't expect error. Got: %v", err)
+	}
+
+	expected := network.forward(*data)
+	ans := parallel.forward(*data)
+	if !mat.EqualApprox(&expected, &ans, 1e-12) {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+
+	if _, err := network.Train(&set, 1, 4, ConstantRate(0.1), WithWorkers(0)); err == nil {
+		t.Error("Expected error.")
+	}
+}
+
+func TestTrainSeed(t *testing.T) {
+	data := mat.NewDense(2, 5, []float64{0, 0, 1, 1, 0.5, 0, 1, 0, 1, 0.5})
+	labels := mat.NewDense(2, 5, []float64{1, 0, 0, 0, 1, 0, 1, 1, 1, 0})
+	set := Set{*data, *labels}
+
+	var networks []*Network
+	for i := 0; i < 2; i++ {
+		random := rand.New(rand.NewSource(42))
+		network, err := NewNetwork([]LayerSpec{{2, 4, ActivationRelu}, {4, 2, ActivationSoftmax}}, LossCategoricalCrossEntropy, WithRand(random))
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		if _, err := network.Train(&set, 3, 2, ConstantRate(0.1), WithSeed(7), WithWorkers(2)); err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		networks = append(networks, network)
+	}
+
+	checkSamePredictions(t, networks[0], networks[1], *data)
+	if networks[0].random.state != networks[1].random.state {
+		t.Errorf("Expected: %v, Got: %v", networks[0].random.state, networks[1].random.state)
+	}
+
+	// a sequential network is reproducible with SetRand instead of WithSeed
+	networks = nil
+	for i := 0; i < 2; i++ {
+		random := rand.New(rand.NewSource(42))
+		dense, _ := NewDense(2, 4, WithRand(random))
+		dropout, _ := NewDropout(4, 0.5)
+		output, _ := NewDense(4, 2, WithRand(random))
+		softmax, _ := NewSoftmax(2)
+		network, err := NewSequential(CategoricalCrossEntropyLoss, dense, dropout, output, softmax)
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		network.SetRand(random)
+		if _, err := network.Train(&set, 3, 2, ConstantRate(0.1), WithShuffle(), WithWorkers(2), WithWriter(nil)); err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		networks = append(networks, network)
+	}
+
+	checkSamePredictions(t, networks[0], networks[1], *data)
+	if networks[0].random.state != networks[1].random.state {
+		t.Errorf("Expected: %v, Got: %v", networks[0].random.state, networks[1].random.state)
+	}
+}
+
+func TestTrainShuffle(t *testing.T) {
+	data := mat.NewDense(2, 5, []float64{0, 0, 1, 1, 0.5, 0, 1, 0, 1, 0.5})
+	labels := mat.NewDense(2, 5, []float64{1, 0, 0, 0, 1, 0, 1, 1, 1, 0})
+	set := Set{*data, *labels}
+	original := mat.DenseCopyOf(data)
+
+	train := func(options ...TrainOption) *Network {
+		network, err := NewNetwork([]LayerSpec{{2, 4, ActivationTanh}, {4, 2, ActivationSoftmax}}, LossCategoricalCrossEntropy, WithRand(rand.New(rand.NewSource(42))))
+		if err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		options = append(options, WithSeed(7), WithWriter(nil))
+		if _, err := network.Train(&set, 3, 1, ConstantRate(0.1), options...); err != nil {
+			t.Fatalf("Didn't expect error. Got: %v", err)
+		}
+		return network
+	}
+
+	shuffled := train(WithShuffle())
+	checkSamePredictions(t, shuffled, train(WithShuffle()), *data)
+
+	ordered := train()
+	expected := ordered.forward(*data)
+	ans := shuffled.forward(*data)
+	if mat.Equal(&expected, &ans) {
+		t.Error("Expected different predictions with shuffled samples.")
+	}
+	if !mat.Equal(original, &set.Data) {
+		t.Errorf("Expected unchanged training set: %v, Got: %v", original, set.Data)
+	}
+}
+
+func TestSelectCols(t *testing.T) {
+	m := mat.NewDense(2, 4, []float64{0, 1, 2, 3, 4, 5, 6, 7})
+	ans := selectCols(*m, []int{3, 0, 2})
+	expected := mat.NewDense(2, 3, []float64{3, 0, 2, 7, 4, 6})
+	if !mat.Equal(expected, ans) {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+
+	// a view into a larger matrix has a stride larger than the number of columns
+	view := m.Slice(0, 2, 1, 3).(*mat.Dense)
+	ans = selectCols(*view, []int{1, 0})
+	expected = mat.NewDense(2, 2, []float64{2, 1, 6, 5})
+	if !mat.Equal(expected, ans) {
+		t.Errorf("Expected: %v, Got: %v", expected, ans)
+	}
+}